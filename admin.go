@@ -0,0 +1,295 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/georgysavva/scany/pgxscan"
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v4"
+)
+
+// User roles.
+const (
+	roleUser  = "user"
+	roleAdmin = "admin"
+)
+
+// adminRequired is a middleware that rejects callers that are not admins.
+// It must be used after authRequired.
+func adminRequired() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var role string
+
+		// The role is looked up on every request, so it can be revoked immediately
+		query := "SELECT role FROM Users WHERE user_id = $1"
+		err := pgxscan.Get(c, dbPool, &role, query, authUserID(c))
+
+		if err != nil && err.Error() != ErrNoRows {
+			fmt.Println(err)
+			c.AbortWithStatus(http.StatusInternalServerError)
+
+			return
+		}
+
+		if role != roleAdmin {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "You are not an admin"})
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// checkIfAdmin is a helper function that checks if the user is an admin.
+func checkIfAdmin(c *gin.Context, userID int) bool {
+	var admin bool
+
+	query := "SELECT EXISTS (SELECT 1 FROM Users WHERE user_id = $1 AND role = $2)"
+
+	err := dbPool.QueryRow(c, query, userID, roleAdmin).Scan(&admin)
+	if err != nil {
+		fmt.Println(err)
+		return false
+	}
+
+	return admin
+}
+
+// setUserBanned bans or unbans the user in the user_id parameter. Banning a user ends all of its sessions.
+func setUserBanned(c *gin.Context, banned bool) {
+	var user User
+
+	query := "SELECT user_id, role FROM Users WHERE user_id = $1"
+	err := pgxscan.Get(c, dbPool, &user, query, c.Param("user_id"))
+
+	if err != nil {
+		if err.Error() == ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": "User does not exist"})
+			return
+		}
+
+		fmt.Println(err)
+		c.Status(http.StatusInternalServerError)
+
+		return
+	}
+
+	if user.Role == roleAdmin {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Admins can not be banned"})
+		return
+	}
+
+	query = "UPDATE Users SET banned = $2 WHERE user_id = $1"
+	_, err = dbPool.Exec(c, query, user.UserID, banned)
+
+	if err == nil && banned {
+		err = revokeUserTokens(user.UserID)
+	}
+
+	if err != nil {
+		fmt.Println(err)
+		c.Status(http.StatusInternalServerError)
+
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"user_id": user.UserID, "banned": banned})
+}
+
+// banUser bans the user from logging in and revokes its tokens.
+func banUser(c *gin.Context) {
+	setUserBanned(c, true)
+}
+
+// unbanUser lifts the ban of the user.
+func unbanUser(c *gin.Context) {
+	setUserBanned(c, false)
+}
+
+// adminDeleteProduct deletes any product.
+func adminDeleteProduct(c *gin.Context) {
+	product := c.Param("product_id")
+
+	deleted, err := deleteProductWithImages(c, product)
+	if err != nil {
+		fmt.Println(err)
+		c.Status(http.StatusInternalServerError)
+
+		return
+	}
+
+	if !deleted {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Product does not exist"})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// adminDeleteReview deletes any review and updates the rating of the reviewed user.
+func adminDeleteReview(c *gin.Context) {
+	var ownerID int
+
+	query := "SELECT fk_owner_id FROM Review WHERE review_id = $1"
+	err := pgxscan.Get(c, dbPool, &ownerID, query, c.Param("review_id"))
+
+	if err == nil {
+		err = changeReviews(c, ownerID, func(tx pgx.Tx) error {
+			_, err := tx.Exec(c, "DELETE FROM Review WHERE review_id = $1", c.Param("review_id"))
+			return err
+		})
+	}
+
+	if err != nil {
+		if err.Error() == ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Review does not exist"})
+			return
+		}
+
+		fmt.Println(err)
+		c.Status(http.StatusInternalServerError)
+
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// createCommunity creates a new community without an owner, which the admins manage as its owner.
+func createCommunity(c *gin.Context) {
+	var community Community
+
+	if err := c.Bind(&community); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if community.Visibility == "" {
+		community.Visibility = visibilityPublic
+	}
+
+	query := "INSERT INTO Community(name, visibility) VALUES($1, $2) RETURNING *"
+	err := pgxscan.Get(c, dbPool, &community, query, community.Name, community.Visibility)
+
+	if err != nil {
+		fmt.Println(err)
+		c.Status(http.StatusInternalServerError)
+
+		return
+	}
+
+	c.JSON(http.StatusCreated, community)
+}
+
+// renameCommunity changes the name of the community.
+func renameCommunity(c *gin.Context) {
+	var body struct {
+		Name string `json:"name" binding:"required"`
+	}
+
+	if err := c.Bind(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var community Community
+
+	query := "UPDATE Community SET name = $2 WHERE community_id = $1 RETURNING *"
+	err := pgxscan.Get(c, dbPool, &community, query, c.Param("community_id"), body.Name)
+
+	if err != nil {
+		if err.Error() == ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Community does not exist"})
+			return
+		}
+
+		fmt.Println(err)
+		c.Status(http.StatusInternalServerError)
+
+		return
+	}
+
+	c.JSON(http.StatusOK, community)
+}
+
+// setCommunityArchived archives or restores the community in the community_id parameter.
+// Archived communities are not listed and can not be joined, but are kept with their members.
+func setCommunityArchived(c *gin.Context, archived bool) {
+	var community Community
+
+	query := "UPDATE Community SET archived = $2 WHERE community_id = $1 RETURNING *"
+	err := pgxscan.Get(c, dbPool, &community, query, c.Param("community_id"), archived)
+
+	if err != nil {
+		if err.Error() == ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Community does not exist"})
+			return
+		}
+
+		fmt.Println(err)
+		c.Status(http.StatusInternalServerError)
+
+		return
+	}
+
+	c.JSON(http.StatusOK, community)
+}
+
+// archiveCommunity archives the community.
+func archiveCommunity(c *gin.Context) {
+	setCommunityArchived(c, true)
+}
+
+// unarchiveCommunity restores an archived community.
+func unarchiveCommunity(c *gin.Context) {
+	setCommunityArchived(c, false)
+}
+
+// getFlags returns all flags, newest first. The type query parameter limits them to flags of products or reviews.
+func getFlags(c *gin.Context) {
+	var flags []*Flag
+
+	query := "SELECT * FROM Flag"
+
+	switch c.Query("type") {
+	case "":
+	case "product":
+		query += " WHERE fk_product_id IS NOT NULL"
+	case "review":
+		query += " WHERE fk_review_id IS NOT NULL"
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "type must be product or review"})
+		return
+	}
+
+	err := pgxscan.Select(c, dbPool, &flags, query+" ORDER BY created_at DESC, flag_id DESC")
+	if err != nil {
+		fmt.Println(err)
+		c.Status(http.StatusInternalServerError)
+
+		return
+	}
+
+	c.JSON(http.StatusOK, flags)
+}
+
+// deleteFlag dismisses a flag without touching the flagged content.
+func deleteFlag(c *gin.Context) {
+	query := "DELETE FROM Flag WHERE flag_id = $1"
+	result, err := dbPool.Exec(c, query, c.Param("flag_id"))
+
+	if err != nil {
+		fmt.Println(err)
+		c.Status(http.StatusInternalServerError)
+
+		return
+	}
+
+	if result.RowsAffected() == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Flag does not exist"})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
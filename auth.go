@@ -0,0 +1,360 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/georgysavva/scany/pgxscan"
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt"
+	"github.com/google/uuid"
+)
+
+// Keys used to store the authenticated user ID and token claims in the gin context.
+const (
+	ctxKeyUserID      = "auth_user_id"
+	ctxKeyTokenClaims = "auth_token_claims"
+)
+
+// Token types and lifetimes.
+const (
+	tokenTypeAccess      = "access"
+	tokenTypeRefresh     = "refresh"
+	accessTokenLifetime  = 15 * time.Minute
+	refreshTokenLifetime = 30 * 24 * time.Hour
+)
+
+var (
+	errInvalidToken = errors.New("invalid token")
+	errRevokedToken = errors.New("token has been revoked")
+)
+
+// tokenClaims is the set of claims in the JWTs issued by the server.
+type tokenClaims struct {
+	Type string `json:"typ"`
+	// The time the token was issued in Unix nanoseconds, as the iat claim is only precise to the second
+	IssuedAtNano int64 `json:"iat_ns"`
+	jwt.StandardClaims
+}
+
+// tokenResponse is the response body of the endpoints issuing tokens.
+type tokenResponse struct {
+	ID           int
+	Token        string
+	RefreshToken string
+}
+
+// createToken creates a signed JWT of the given type with the given user ID as subject.
+func createToken(userID int, tokenType string, lifetime time.Duration) (string, error) {
+	now := time.Now()
+
+	return signJWT(tokenClaims{
+		Type:         tokenType,
+		IssuedAtNano: now.UnixNano(),
+		StandardClaims: jwt.StandardClaims{
+			Id:        uuid.NewString(),
+			Subject:   strconv.Itoa(userID),
+			IssuedAt:  now.Unix(),
+			NotBefore: now.Unix(),
+			ExpiresAt: now.Add(lifetime).Unix(),
+		},
+	})
+}
+
+// createAccessToken creates a short-lived token used to authenticate requests.
+func createAccessToken(userID int) (string, error) {
+	return createToken(userID, tokenTypeAccess, accessTokenLifetime)
+}
+
+// createRefreshToken creates a long-lived token used to get new access tokens.
+func createRefreshToken(userID int) (string, error) {
+	return createToken(userID, tokenTypeRefresh, refreshTokenLifetime)
+}
+
+// createTokenResponse creates a new access and refresh token pair for the given user.
+func createTokenResponse(userID int) (*tokenResponse, error) {
+	accessToken, err := createAccessToken(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	refreshToken, err := createRefreshToken(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &tokenResponse{
+		ID:           userID,
+		Token:        accessToken,
+		RefreshToken: refreshToken,
+	}, nil
+}
+
+// parseToken validates the given JWT and returns its claims if it is an unrevoked token of the given type.
+func parseToken(tokenString string, tokenType string) (*tokenClaims, error) {
+	var claims tokenClaims
+
+	_, err := jwt.ParseWithClaims(tokenString, &claims, jwtVerifyKey)
+	if err != nil {
+		return nil, err
+	}
+
+	if claims.Type != tokenType || claims.Id == "" || !claims.VerifyExpiresAt(time.Now().Unix(), true) {
+		return nil, errInvalidToken
+	}
+
+	if _, err = claims.userID(); err != nil {
+		return nil, err
+	}
+
+	revoked, err := claims.revoked()
+	if err != nil {
+		return nil, err
+	}
+
+	if revoked {
+		return nil, errRevokedToken
+	}
+
+	return &claims, nil
+}
+
+// tokenRevoked reports whether the given JWT has been revoked, whether or not it has expired. It is used to end the
+// websocket sessions signed in with an access token once it is revoked.
+func tokenRevoked(tokenString string) (bool, error) {
+	var claims tokenClaims
+
+	parser := jwt.Parser{SkipClaimsValidation: true}
+	if _, err := parser.ParseWithClaims(tokenString, &claims, jwtVerifyKey); err != nil {
+		return false, err
+	}
+
+	return claims.revoked()
+}
+
+// revoked reports whether the token has been revoked, by itself or along with all tokens issued to the user.
+func (claims *tokenClaims) revoked() (bool, error) {
+	revoked, err := redisCli.TokenIsRevoked(claims.Id)
+	if err != nil || revoked {
+		return revoked, err
+	}
+
+	revokedAt, err := redisCli.UserTokensRevokedAt(claims.Subject)
+	if err != nil {
+		return false, err
+	}
+
+	return claims.IssuedAtNano <= revokedAt.UnixNano(), nil
+}
+
+// userID returns the user ID in the subject claim.
+func (claims *tokenClaims) userID() (int, error) {
+	userID, err := strconv.Atoi(claims.Subject)
+	if err != nil {
+		return 0, errInvalidToken
+	}
+
+	return userID, nil
+}
+
+// revoke revokes the token until it expires.
+func (claims *tokenClaims) revoke() (bool, error) {
+	return redisCli.TokenRevoke(claims.Id, time.Until(time.Unix(claims.ExpiresAt, 0)))
+}
+
+// revokeUserTokens revokes all tokens issued to the user, ending all of its sessions.
+func revokeUserTokens(userID int) error {
+	return redisCli.UserTokensRevoke(strconv.Itoa(userID), refreshTokenLifetime)
+}
+
+// authenticateToken validates an access token and returns the ID of the user it was issued to.
+func authenticateToken(tokenString string) (string, error) {
+	claims, err := parseToken(tokenString, tokenTypeAccess)
+	if err != nil {
+		return "", err
+	}
+
+	return claims.Subject, nil
+}
+
+// bearerToken returns the token in the Authorization header of the request.
+func bearerToken(c *gin.Context) string {
+	header := c.GetHeader("Authorization")
+
+	const prefix = "Bearer "
+	if len(header) < len(prefix) || !strings.EqualFold(header[:len(prefix)], prefix) {
+		return ""
+	}
+
+	return strings.TrimSpace(header[len(prefix):])
+}
+
+// authUserID returns the ID of the authenticated user making the request.
+func authUserID(c *gin.Context) int {
+	return c.GetInt(ctxKeyUserID)
+}
+
+// authRequired is a middleware that validates the bearer token and stores the user ID of its subject in the context.
+func authRequired() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		tokenString := bearerToken(c)
+		if tokenString == "" {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Missing bearer token"})
+			return
+		}
+
+		claims, err := parseToken(tokenString, tokenTypeAccess)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
+			return
+		}
+
+		userID, _ := claims.userID()
+
+		c.Set(ctxKeyUserID, userID)
+		c.Set(ctxKeyTokenClaims, claims)
+		c.Next()
+	}
+}
+
+// authOptional is a middleware that authenticates the request like authRequired if it has a bearer token, and lets
+// requests without one through unauthenticated, with authUserID returning 0.
+func authOptional() gin.HandlerFunc {
+	required := authRequired()
+
+	return func(c *gin.Context) {
+		if bearerToken(c) == "" {
+			c.Next()
+			return
+		}
+
+		required(c)
+	}
+}
+
+// userOwnerRequired is a middleware that rejects callers that are not the user in the user_id parameter.
+// It must be used after authRequired.
+func userOwnerRequired() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.Param("user_id") != strconv.Itoa(authUserID(c)) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "You do not have access to this user"})
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// productOwnerRequired is a middleware that rejects callers that do not own the product in the product_id parameter.
+// It must be used after authRequired.
+func productOwnerRequired() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ownerID int
+
+		query := "SELECT fk_user_id FROM Product WHERE product_id = $1"
+		err := pgxscan.Get(c, dbPool, &ownerID, query, c.Param("product_id"))
+
+		if err != nil {
+			if err.Error() == ErrNoRows {
+				c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "Product does not exist"})
+				return
+			}
+
+			fmt.Println(err)
+			c.AbortWithStatus(http.StatusInternalServerError)
+
+			return
+		}
+
+		if ownerID != authUserID(c) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "You do not own this product"})
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// refreshToken exchanges a refresh token for a new token pair. The used refresh token is revoked.
+func refreshToken(c *gin.Context) {
+	var body struct {
+		RefreshToken string `json:"refresh_token" binding:"required"`
+	}
+
+	if err := c.Bind(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	claims, err := parseToken(body.RefreshToken, tokenTypeRefresh)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid refresh token"})
+		return
+	}
+
+	// Revoking fails if the token was used concurrently, so each refresh token is only exchanged once
+	revoked, err := claims.revoke()
+	if err != nil {
+		fmt.Println(err)
+		c.Status(http.StatusInternalServerError)
+
+		return
+	}
+
+	if !revoked {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid refresh token"})
+		return
+	}
+
+	userID, _ := claims.userID()
+
+	response, err := createTokenResponse(userID)
+	if err != nil {
+		fmt.Println(err)
+		c.Status(http.StatusInternalServerError)
+
+		return
+	}
+
+	c.JSON(http.StatusCreated, response)
+}
+
+// logout revokes the access token of the request and, if given, the refresh token of the same session.
+func logout(c *gin.Context) {
+	var body struct {
+		RefreshToken string `json:"refresh_token"`
+	}
+
+	if c.Request.ContentLength != 0 {
+		if err := c.BindJSON(&body); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	claims, _ := c.MustGet(ctxKeyTokenClaims).(*tokenClaims)
+
+	if _, err := claims.revoke(); err != nil {
+		fmt.Println(err)
+		c.Status(http.StatusInternalServerError)
+
+		return
+	}
+
+	if body.RefreshToken != "" {
+		refreshClaims, err := parseToken(body.RefreshToken, tokenTypeRefresh)
+		if err == nil && refreshClaims.Subject == claims.Subject {
+			_, err = refreshClaims.revoke()
+		}
+
+		if err != nil && !errors.Is(err, errRevokedToken) {
+			fmt.Println(err)
+		}
+	}
+
+	c.Status(http.StatusNoContent)
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// Path the local blob store is served at.
+const blobRoutePath = "/blobs"
+
+var errInvalidBlobKey = errors.New("invalid blob key")
+
+// BlobStore stores binary objects, such as images, by key.
+type BlobStore interface {
+	// Put stores data at key, replacing any data already stored there.
+	Put(ctx context.Context, key string, data []byte, contentType string) error
+	// Delete removes the data at key. Deleting a key that does not exist is not an error.
+	Delete(ctx context.Context, key string) error
+	// URL returns the URL clients fetch the data at key from.
+	URL(key string) string
+}
+
+// localBlobStore is a BlobStore keeping the objects as files in a directory, which is served by the server itself.
+type localBlobStore struct {
+	dir     string
+	baseURL string
+}
+
+// newLocalBlobStore creates a BlobStore storing files in dir, creating it if needed. The URLs of the objects are
+// baseURL followed by the keys.
+func newLocalBlobStore(dir string, baseURL string) (*localBlobStore, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+
+	return &localBlobStore{dir: dir, baseURL: strings.TrimSuffix(baseURL, "/")}, nil
+}
+
+// path returns the path of the file of key, making sure it is inside the directory of the store.
+func (s *localBlobStore) path(key string) (string, error) {
+	if key == "" || path.Clean("/"+key) != "/"+key {
+		return "", errInvalidBlobKey
+	}
+
+	return filepath.Join(s.dir, filepath.FromSlash(key)), nil
+}
+
+// Put writes data to the file of key. It is written to a temporary file first so that a partially written file is
+// never served.
+func (s *localBlobStore) Put(ctx context.Context, key string, data []byte, contentType string) error {
+	name, err := s.path(key)
+	if err != nil {
+		return err
+	}
+
+	if err = os.MkdirAll(filepath.Dir(name), 0o755); err != nil {
+		return err
+	}
+
+	file, err := os.CreateTemp(filepath.Dir(name), ".upload-*")
+	if err != nil {
+		return err
+	}
+
+	_, err = file.Write(data)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+
+	if err == nil {
+		err = os.Chmod(file.Name(), 0o644)
+	}
+
+	if err == nil {
+		err = os.Rename(file.Name(), name)
+	}
+
+	if err != nil {
+		_ = os.Remove(file.Name())
+	}
+
+	return err
+}
+
+// Delete removes the file of key.
+func (s *localBlobStore) Delete(ctx context.Context, key string) error {
+	name, err := s.path(key)
+	if err != nil {
+		return err
+	}
+
+	err = os.Remove(name)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+
+	return err
+}
+
+// URL returns the URL of key below the base URL.
+func (s *localBlobStore) URL(key string) string {
+	return s.baseURL + "/" + key
+}
+
+// setupBlobStore creates the BlobStore used by the server, storing the objects in dir, or in ./blobs if it is empty.
+// The URLs of the objects start with baseURL, which defaults to the path the server serves them at.
+func setupBlobStore(dir string, baseURL string) (BlobStore, error) {
+	if dir == "" {
+		dir = "blobs"
+	}
+
+	if baseURL == "" {
+		baseURL = blobRoutePath
+	}
+
+	return newLocalBlobStore(dir, baseURL)
+}
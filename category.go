@@ -0,0 +1,88 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/georgysavva/scany/pgxscan"
+	"github.com/gin-gonic/gin"
+)
+
+// getCategories returns the top level categories with their subcategories as children, sorted by name.
+func getCategories(c *gin.Context) {
+	var categories []*Category
+
+	query := "SELECT * FROM Category ORDER BY name"
+	err := pgxscan.Select(c, dbPool, &categories, query)
+
+	if err != nil {
+		fmt.Println(err)
+		c.Status(http.StatusInternalServerError)
+
+		return
+	}
+
+	byID := make(map[int]*Category, len(categories))
+	for _, category := range categories {
+		byID[category.CategoryID] = category
+	}
+
+	tree := []*Category{}
+
+	for _, category := range categories {
+		if category.ParentID == nil {
+			tree = append(tree, category)
+			continue
+		}
+
+		parent := byID[*category.ParentID]
+		parent.Children = append(parent.Children, category)
+	}
+
+	c.JSON(http.StatusOK, tree)
+}
+
+// createCategory creates a new category, as a subcategory if a parent is given. The name has to be unique among the
+// categories of the same parent.
+func createCategory(c *gin.Context) {
+	var category Category
+
+	if err := c.Bind(&category); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if category.ParentID != nil && checkIfCategoryExist(c, *category.ParentID) == false {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Parent category does not exist"})
+		return
+	}
+
+	// Category names are unique among the subcategories of a parent, regardless of case
+	query := `INSERT INTO Category(name, fk_parent_id) VALUES($1, $2)
+		ON CONFLICT (coalesce(fk_parent_id, 0), lower(name)) DO NOTHING RETURNING *`
+	err := pgxscan.Get(c, dbPool, &category, query, category.Name, category.ParentID)
+
+	if err != nil && err.Error() == ErrNoRows {
+		c.JSON(http.StatusConflict, gin.H{"error": "Category already exists"})
+		return
+	}
+
+	if err != nil {
+		fmt.Println(err)
+		c.Status(http.StatusInternalServerError)
+
+		return
+	}
+
+	c.JSON(http.StatusCreated, category)
+}
+
+// checkIfCategoryExist is a helper function that checks if a category with the given ID exists in the database.
+func checkIfCategoryExist(c *gin.Context, categoryID int) bool {
+	var exists bool
+
+	query := "SELECT EXISTS (SELECT 1 FROM Category WHERE category_id = $1)"
+	err := pgxscan.Get(c, dbPool, &exists, query, categoryID)
+
+	return err == nil && exists
+}
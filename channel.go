@@ -0,0 +1,300 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/VictorAnnell/kandidat-backend/rediscli"
+	"github.com/georgysavva/scany/pgxscan"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v4"
+)
+
+// Prefixes of the websocket channels of communities and group chats, which are named by the prefix followed by the ID
+// of the community or the group chat.
+const (
+	communityChannelPrefix = "community."
+	groupChannelPrefix     = "group."
+)
+
+// The channel of the public chat. The channel of the chat of two users is named by the chat_id of their chat.
+const publicChannel = "public"
+
+var (
+	errNotAChat    = errors.New("channel is not a chat")
+	errNoChatUsers = errors.New("users of the chat do not exist")
+)
+
+// channelChat returns the chat of a websocket channel, as the chat a message sent in the channel is tied to.
+func channelChat(channelUUID string) (ChatMessage, error) {
+	var chat ChatMessage
+
+	switch {
+	case channelUUID == publicChannel:
+		return chat, nil
+	case strings.HasPrefix(channelUUID, communityChannelPrefix):
+		id, err := strconv.Atoi(strings.TrimPrefix(channelUUID, communityChannelPrefix))
+		chat.CommunityID = &id
+
+		if err != nil {
+			return chat, errNotAChat
+		}
+	case strings.HasPrefix(channelUUID, groupChannelPrefix):
+		id, err := strconv.Atoi(strings.TrimPrefix(channelUUID, groupChannelPrefix))
+		chat.GroupChatID = &id
+
+		if err != nil {
+			return chat, errNotAChat
+		}
+	default:
+		if _, err := uuid.Parse(channelUUID); err != nil {
+			return chat, errNotAChat
+		}
+
+		chat.ChatID = &channelUUID
+	}
+
+	return chat, nil
+}
+
+// chatCondition returns the condition selecting the messages of the chat, and its arguments.
+func chatCondition(chat ChatMessage) (string, []interface{}) {
+	switch {
+	case chat.ChatID != nil:
+		return "fk_chat_id = $1", []interface{}{*chat.ChatID}
+	case chat.GroupChatID != nil:
+		return "fk_group_chat_id = $1", []interface{}{*chat.GroupChatID}
+	case chat.CommunityID != nil:
+		return "fk_community_id = $1", []interface{}{*chat.CommunityID}
+	default:
+		return "fk_chat_id IS NULL AND fk_group_chat_id IS NULL AND fk_community_id IS NULL", nil
+	}
+}
+
+// authorizeChannel reports whether the user with the given ID may use a websocket channel. Only the members of a
+// community or a group chat may use its channel, and only the two users of a chat its channel, as recorded in the
+// database when the channel is used. The public channel is open to all users, and there are no other channels.
+func authorizeChannel(userID string, channelUUID string) (bool, error) {
+	chat, err := channelChat(channelUUID)
+	if err != nil {
+		return false, nil
+	}
+
+	var query string
+
+	var id interface{}
+
+	switch {
+	case chat.CommunityID != nil:
+		query = "SELECT EXISTS (SELECT 1 FROM User_Community WHERE fk_community_id = $1 AND fk_user_id::text = $2)"
+		id = *chat.CommunityID
+	case chat.GroupChatID != nil:
+		query = "SELECT EXISTS (SELECT 1 FROM Group_Chat_Member WHERE fk_group_chat_id = $1 AND fk_user_id::text = $2)"
+		id = *chat.GroupChatID
+	case chat.ChatID != nil:
+		query = "SELECT EXISTS (SELECT 1 FROM Chats WHERE chat_id = $1 AND $2 IN (fk_user_id_1::text, fk_user_id_2::text))"
+		id = *chat.ChatID
+	default:
+		return true, nil
+	}
+
+	var member bool
+
+	err = dbPool.QueryRow(context.Background(), query, id, userID).Scan(&member)
+
+	return member, err
+}
+
+// directChat returns the chat_id of the chat of two users. A chat without a chat_id is given channelUUID. Users
+// without a chat are not given one until they send a message, see openDirectChat, and channelUUID is returned for
+// them. It returns an empty string if the recipient does not exist.
+func directChat(ctx context.Context, senderID, recipientID, channelUUID string) (string, error) {
+	var chatID string
+
+	query := `UPDATE Chats SET chat_id = coalesce(chat_id, $3)
+		WHERE (fk_user_id_1::text = $1 AND fk_user_id_2::text = $2) OR (fk_user_id_1::text = $2 AND fk_user_id_2::text = $1)
+		RETURNING chat_id::text`
+
+	err := dbPool.QueryRow(ctx, query, senderID, recipientID, channelUUID).Scan(&chatID)
+	if err == nil || err.Error() != ErrNoRows {
+		return chatID, err
+	}
+
+	var exists bool
+
+	query = "SELECT EXISTS (SELECT 1 FROM Users WHERE user_id::text = $1)"
+	if err := dbPool.QueryRow(ctx, query, recipientID).Scan(&exists); err != nil || !exists {
+		return "", err
+	}
+
+	return channelUUID, nil
+}
+
+// openDirectChat returns the chat_id of the chat of two users like directChat, giving users without a chat a chat
+// with channelUUID. The chat is shown again to the users who hid it. It returns an empty string if either user does
+// not exist.
+func openDirectChat(ctx context.Context, senderID, recipientID, channelUUID string) (string, error) {
+	var chatID string
+
+	err := dbPool.BeginFunc(ctx, func(tx pgx.Tx) error {
+		query := `UPDATE Chats SET chat_id = coalesce(chat_id, $3), hidden_by_1 = false, hidden_by_2 = false
+			WHERE (fk_user_id_1::text = $1 AND fk_user_id_2::text = $2) OR (fk_user_id_1::text = $2 AND fk_user_id_2::text = $1)
+			RETURNING chat_id::text`
+
+		err := tx.QueryRow(ctx, query, senderID, recipientID, channelUUID).Scan(&chatID)
+		if err == nil || err.Error() != ErrNoRows {
+			return err
+		}
+
+		query = `INSERT INTO Chats(fk_user_id_1, fk_user_id_2, chat_id)
+			SELECT sender.user_id, recipient.user_id, $3 FROM Users sender, Users recipient
+			WHERE sender.user_id::text = $1 AND recipient.user_id::text = $2
+			RETURNING chat_id::text`
+
+		err = tx.QueryRow(ctx, query, senderID, recipientID, channelUUID).Scan(&chatID)
+		if err != nil && err.Error() == ErrNoRows {
+			return nil
+		}
+
+		return err
+	})
+
+	return chatID, err
+}
+
+// messageStore stores the messages of the websocket chat in the database, where they are kept, while Redis only keeps
+// the most recent messages of each channel.
+type messageStore struct{}
+
+// MessageSave stores a message sent in the channel. The chat of two users is created with their first message.
+func (messageStore) MessageSave(channelUUID string, message *rediscli.Message) error {
+	ctx := context.Background()
+
+	// Messages to a user are sent in the chat with the user
+	if _, err := strconv.Atoi(message.RecipientUUID); err == nil {
+		chatID, err := openDirectChat(ctx, message.SenderID, message.RecipientUUID, channelUUID)
+		if err != nil {
+			return err
+		}
+
+		if chatID == "" {
+			return errNoChatUsers
+		}
+
+		channelUUID = chatID
+	}
+
+	_, err := saveChatMessage(ctx, channelUUID, message)
+
+	return err
+}
+
+// DirectChat returns the chat_id of the chat of two users, see directChat.
+func (messageStore) DirectChat(senderID, recipientID, channelUUID string) (string, error) {
+	return directChat(context.Background(), senderID, recipientID, channelUUID)
+}
+
+// Messages returns the messages of the channel from the newest, skipping offset messages, along with the number of
+// messages in the channel.
+func (messageStore) Messages(channelUUID string, offset, limit int64) ([]*rediscli.Message, int64, error) {
+	ctx := context.Background()
+
+	chat, err := channelChat(channelUUID)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	condition, args := chatCondition(chat)
+
+	var count int64
+
+	err = dbPool.QueryRow(ctx, "SELECT count(*) FROM Message WHERE "+condition, args...).Scan(&count)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var stored []*ChatMessage
+
+	query := fmt.Sprintf(`SELECT message_id::text AS message_id, fk_chat_id::text AS fk_chat_id, fk_group_chat_id,
+		fk_community_id, fk_sender_id, recipient, content, created_at FROM Message
+		WHERE %s ORDER BY created_at DESC, message_id DESC OFFSET %d LIMIT %d`, condition, offset, limit)
+
+	err = pgxscan.Select(ctx, dbPool, &stored, query, args...)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	messages := make([]*rediscli.Message, len(stored))
+
+	for i, message := range stored {
+		messages[i] = &rediscli.Message{
+			UUID:          message.MessageID,
+			RecipientUUID: message.Recipient,
+			Message:       message.Content,
+			CreatedAt:     message.CreatedAt,
+		}
+
+		if message.SenderID != nil {
+			messages[i].SenderID = strconv.Itoa(*message.SenderID)
+		}
+	}
+
+	return messages, count, nil
+}
+
+// saveChatMessage stores a message sent in the channel, and reports whether it was stored. A message with the same
+// UUID as a stored message is not stored again.
+func saveChatMessage(ctx context.Context, channelUUID string, message *rediscli.Message) (bool, error) {
+	chat, err := channelChat(channelUUID)
+	if err != nil {
+		return false, err
+	}
+
+	// Senders that are not users are not kept
+	query := `INSERT INTO Message(message_id, fk_chat_id, fk_group_chat_id, fk_community_id, fk_sender_id, recipient, content, created_at)
+		VALUES($1, $2, $3, $4, (SELECT user_id FROM Users WHERE user_id::text = $5), $6, $7, $8)
+		ON CONFLICT (message_id) DO NOTHING`
+
+	result, err := dbPool.Exec(ctx, query, message.UUID, chat.ChatID, chat.GroupChatID, chat.CommunityID, message.SenderID,
+		message.RecipientUUID, message.Message, message.CreatedAt)
+	if err != nil {
+		return false, err
+	}
+
+	return result.RowsAffected() > 0, nil
+}
+
+// checkIfChannelChatExist is a helper function that checks if the chat of a channel exists.
+func checkIfChannelChatExist(ctx context.Context, channelUUID string) bool {
+	chat, err := channelChat(channelUUID)
+	if err != nil {
+		return false
+	}
+
+	var query string
+
+	var id interface{}
+
+	switch {
+	case chat.ChatID != nil:
+		query, id = "SELECT EXISTS (SELECT 1 FROM Chats WHERE chat_id = $1)", *chat.ChatID
+	case chat.GroupChatID != nil:
+		query, id = "SELECT EXISTS (SELECT 1 FROM Group_Chat WHERE group_chat_id = $1)", *chat.GroupChatID
+	case chat.CommunityID != nil:
+		query, id = "SELECT EXISTS (SELECT 1 FROM Community WHERE community_id = $1)", *chat.CommunityID
+	default:
+		return true
+	}
+
+	var exists bool
+
+	if err := dbPool.QueryRow(ctx, query, id).Scan(&exists); err != nil {
+		fmt.Println(err)
+		return false
+	}
+
+	return exists
+}
@@ -0,0 +1,277 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/VictorAnnell/kandidat-backend/db"
+	"github.com/VictorAnnell/kandidat-backend/rediscli"
+	"github.com/georgysavva/scany/pgxscan"
+	"github.com/gin-gonic/autotls"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const usage = `Usage: kandidat-backend [command]
+
+Commands:
+  serve                   Start the server (default)
+  migrate up              Apply all new migrations
+  migrate down [steps]    Revert the latest migration, or the given number of them
+  migrate status          List the migrations and whether they are applied
+  seed                    Load the seed data into an empty database
+  create-admin            Create an admin user, or make an existing user an admin
+  resync-redis            Rebuild the users in Redis from the database
+  import-messages         Store the chat messages kept in Redis in the database
+`
+
+// errUsage is returned by commands called with invalid arguments.
+var errUsage = errors.New("invalid arguments")
+
+// runCommand runs the command given by the arguments, starting the server if there is none.
+func runCommand(ctx context.Context, args []string) error {
+	if len(args) == 0 {
+		return serve(ctx)
+	}
+
+	switch args[0] {
+	case "serve":
+		return serve(ctx)
+	case "migrate":
+		return runMigrate(ctx, args[1:])
+	case "seed":
+		return seed(ctx)
+	case "create-admin":
+		return createAdmin(ctx, args[1:])
+	case "resync-redis":
+		return resyncRedisUsers(ctx)
+	case "import-messages":
+		return importRedisMessages(ctx)
+	case "help", "-h", "-help", "--help":
+		fmt.Print(usage)
+		return nil
+	default:
+		return fmt.Errorf("%w: unknown command %s", errUsage, args[0])
+	}
+}
+
+// serve starts the server, applying new migrations first if autoMigrate is set.
+func serve(ctx context.Context) error {
+	if autoMigrate {
+		if err := migrateDatabase(ctx); err != nil {
+			return fmt.Errorf("unable to migrate database: %w", err)
+		}
+	}
+
+	router := setupRouter()
+
+	switch {
+	case autoTLSDomain != "":
+		fmt.Println("Auto TLS enabled")
+
+		return autotls.Run(router, autoTLSDomain)
+	case tlsCertFile != "" && tlsKeyFile != "":
+		fmt.Println("TLS enabled")
+
+		return router.RunTLS(serverURL, tlsCertFile, tlsKeyFile)
+	default:
+		fmt.Println("No TLS enabled")
+
+		return router.Run(serverURL)
+	}
+}
+
+// seed loads the seed data into the database if it has no users.
+func seed(ctx context.Context) error {
+	seeded, err := db.Seed(ctx, dbPool)
+	if err != nil {
+		return err
+	}
+
+	if seeded {
+		fmt.Println("Seeded the database")
+	} else {
+		fmt.Println("The database already has users, not seeding")
+	}
+
+	return moveLegacyPictures(ctx)
+}
+
+// createAdmin creates an admin user with the name, phone number and password given as flags. If a user with the
+// phone number exists it is made an admin instead, keeping its name and password.
+// The password is read from stdin if it is not given.
+func createAdmin(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("create-admin", flag.ContinueOnError)
+	name := flags.String("name", "Admin", "name of the admin")
+	phoneNumber := flags.String("phone", "", "phone number of the admin, in E.164 format")
+	password := flags.String("password", "", "password of the admin, read from stdin if not given")
+
+	if err := flags.Parse(args); err != nil {
+		return fmt.Errorf("%w: %v", errUsage, err)
+	}
+
+	phone := struct {
+		Number string `binding:"required,e164"`
+	}{*phoneNumber}
+
+	if err := binding.Validator.ValidateStruct(phone); err != nil {
+		return fmt.Errorf("%w: -phone is required in E.164 format", errUsage)
+	}
+
+	var userID int
+
+	query := "UPDATE Users SET role = $2 WHERE phone_number = $1 RETURNING user_id"
+	err := pgxscan.Get(ctx, dbPool, &userID, query, *phoneNumber, roleAdmin)
+
+	if err == nil {
+		fmt.Printf("Made user %d an admin\n", userID)
+		return nil
+	} else if err.Error() != ErrNoRows {
+		return err
+	}
+
+	if *password == "" {
+		fmt.Print("Password: ")
+
+		line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+		if err != nil && line == "" {
+			return fmt.Errorf("unable to read password: %w", err)
+		}
+
+		*password = strings.TrimRight(line, "\r\n")
+		if *password == "" {
+			return fmt.Errorf("%w: the password can not be empty", errUsage)
+		}
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(*password), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+
+	// The phone number is trusted, as it is given by whoever runs the command
+	query = "INSERT INTO Users(name, phone_number, password, business, verified, role) VALUES($1, $2, $3, false, true, $4) RETURNING user_id"
+
+	err = pgxscan.Get(ctx, dbPool, &userID, query, *name, *phoneNumber, hashedPassword, roleAdmin)
+	if err != nil {
+		return err
+	}
+
+	if _, err = redisCli.UserCreate(strconv.Itoa(userID), *name); err != nil {
+		return err
+	}
+
+	fmt.Printf("Created admin user %d\n", userID)
+
+	return nil
+}
+
+// resyncRedisUsers replaces the users in the Redis database with the users in the PostgreSQL database.
+func resyncRedisUsers(ctx context.Context) error {
+	var userlist []User
+
+	query := "SELECT user_id, name FROM Users ORDER BY user_id"
+	if err := pgxscan.Select(ctx, dbPool, &userlist, query); err != nil {
+		return err
+	}
+
+	users := make([]*rediscli.User, 0, len(userlist))
+	for _, user := range userlist {
+		users = append(users, &rediscli.User{ID: strconv.Itoa(user.UserID), Name: user.Name})
+	}
+
+	if err := redisCli.UsersReplace(users); err != nil {
+		return err
+	}
+
+	fmt.Printf("Synced %d users to Redis\n", len(users))
+
+	return nil
+}
+
+// importRedisMessages stores the chat messages kept in Redis, from before messages were stored in the database, in the
+// database. Redis then only keeps the most recent messages of each channel. Messages that are already stored are
+// skipped, so the import can be run again, and so are messages of channels that are not chats, or whose chat has been
+// deleted. The channel of the chat of two users is recorded on their chat.
+func importRedisMessages(ctx context.Context) error {
+	channelUUIDs, err := redisCli.ChannelHistories()
+	if err != nil {
+		return err
+	}
+
+	directChannels, err := redisCli.DirectChannels()
+	if err != nil {
+		return err
+	}
+
+	imported, skipped := 0, 0
+
+	for _, channelUUID := range channelUUIDs {
+		messages, err := redisCli.ChannelMessages(channelUUID, 0, -1)
+		if err != nil {
+			return err
+		}
+
+		chatUUID := channelUUID
+
+		// Users who sent each other messages are given a chat, like when they send a message
+		if users, ok := directChannels[channelUUID]; ok && len(messages) > 0 {
+			chatUUID, err = openDirectChat(ctx, users[0], users[1], channelUUID)
+			if err != nil {
+				return err
+			}
+		}
+
+		if chatUUID == "" || !checkIfChannelChatExist(ctx, chatUUID) {
+			skipped += len(messages)
+			continue
+		}
+
+		for _, message := range messages {
+			// The UUID identifies the message, so that it is only stored once. Messages without a valid UUID get one
+			// derived from what they have, which stays the same if the import is run again.
+			if _, err := uuid.Parse(message.UUID); err != nil {
+				message.UUID = uuid.NewSHA1(uuid.NameSpaceURL, []byte(channelUUID+"/"+message.UUID)).String()
+			}
+
+			stored, err := saveChatMessage(ctx, chatUUID, message)
+			if err != nil {
+				return err
+			}
+
+			if stored {
+				imported++
+			}
+		}
+
+		// The users already have a chat with another channel, which they use from now on. Its recent messages are
+		// read from the database until new messages are sent.
+		if chatUUID != channelUUID {
+			users := directChannels[channelUUID]
+			if err := redisCli.SetChannelUUID(users[0], users[1], chatUUID); err != nil {
+				return err
+			}
+
+			if err := redisCli.ChannelMessagesDelete(channelUUID); err != nil {
+				return err
+			}
+
+			continue
+		}
+
+		if err := redisCli.ChannelMessagesTrim(channelUUID); err != nil {
+			return err
+		}
+	}
+
+	fmt.Printf("Imported %d messages of %d channels from Redis, skipped %d messages\n", imported, len(channelUUIDs), skipped)
+
+	return nil
+}
@@ -0,0 +1,493 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/georgysavva/scany/pgxscan"
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v4"
+)
+
+// Number of items in a page of a community feed by default and at most.
+const (
+	feedPageDefaultLimit = 20
+	feedPageMaxLimit     = 100
+)
+
+// postColumns are the columns of the Post table along with the number of comments on the post.
+const postColumns = "Post.*, (SELECT count(*) FROM Comment WHERE fk_post_id = post_id) AS comment_count"
+
+// getCommunity returns the community in the community_id parameter. It responds with an error and returns false if
+// there is no such community.
+func getCommunity(c *gin.Context) (Community, bool) {
+	var community Community
+
+	err := pgxscan.Get(c, dbPool, &community, "SELECT * FROM Community WHERE community_id = $1", c.Param("community_id"))
+	if err != nil {
+		if err.Error() == ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Community does not exist"})
+			return community, false
+		}
+
+		fmt.Println(err)
+		c.Status(http.StatusInternalServerError)
+
+		return community, false
+	}
+
+	return community, true
+}
+
+// checkIfMember is a helper function that checks if the user is a member of the community.
+func checkIfMember(c *gin.Context, communityID int, userID int) bool {
+	var member bool
+
+	query := "SELECT EXISTS (SELECT 1 FROM User_Community WHERE fk_community_id = $1 AND fk_user_id = $2)"
+
+	err := dbPool.QueryRow(c, query, communityID, userID).Scan(&member)
+	if err != nil {
+		fmt.Println(err)
+		return false
+	}
+
+	return member
+}
+
+// getMemberCommunity returns the community in the community_id parameter like getCommunity, if the logged in user is
+// a member who can post in it.
+func getMemberCommunity(c *gin.Context) (Community, bool) {
+	community, ok := getCommunity(c)
+	if !ok {
+		return community, false
+	}
+
+	if community.Archived {
+		c.JSON(http.StatusConflict, gin.H{"error": "Community is archived"})
+		return community, false
+	}
+
+	if !checkIfMember(c, community.CommunityID, authUserID(c)) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You have to be a member of the community"})
+		return community, false
+	}
+
+	return community, true
+}
+
+// getCommunityPost returns the post in the post_id parameter, which must be a post in the community in the
+// community_id parameter. It responds with an error and returns false if there is no such post.
+func getCommunityPost(c *gin.Context) (Post, bool) {
+	var post Post
+
+	query := "SELECT " + postColumns + " FROM Post WHERE post_id = $1 AND fk_community_id = $2"
+
+	err := pgxscan.Get(c, dbPool, &post, query, c.Param("post_id"), c.Param("community_id"))
+	if err != nil {
+		if err.Error() == ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Post does not exist"})
+			return post, false
+		}
+
+		fmt.Println(err)
+		c.Status(http.StatusInternalServerError)
+
+		return post, false
+	}
+
+	return post, true
+}
+
+// createPost creates a post by the logged in user in a community the user is a member of.
+func createPost(c *gin.Context) {
+	var post Post
+
+	if err := c.Bind(&post); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	community, ok := getMemberCommunity(c)
+	if !ok {
+		return
+	}
+
+	query := "INSERT INTO Post(fk_community_id, fk_user_id, title, content) VALUES($1, $2, $3, $4) RETURNING *"
+
+	err := pgxscan.Get(c, dbPool, &post, query, community.CommunityID, authUserID(c), post.Title, post.Content)
+	if err != nil {
+		fmt.Println(err)
+		c.Status(http.StatusInternalServerError)
+
+		return
+	}
+
+	post.CommentCount = 0
+
+	c.JSON(http.StatusCreated, post)
+}
+
+// getPost returns a post in a community.
+func getPost(c *gin.Context) {
+	if _, ok := getVisibleCommunity(c); !ok {
+		return
+	}
+
+	post, ok := getCommunityPost(c)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, post)
+}
+
+// deletePost deletes a post along with its comments. Posts are deleted by their authors, the moderators of the
+// community and the admins.
+func deletePost(c *gin.Context) {
+	post, ok := getCommunityPost(c)
+	if !ok {
+		return
+	}
+
+	userID := authUserID(c)
+
+	if post.UserID != userID && !checkIfModerator(c, post.CommunityID, userID) && !checkIfAdmin(c, userID) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You can only delete your own posts"})
+		return
+	}
+
+	if _, err := dbPool.Exec(c, "DELETE FROM Post WHERE post_id = $1", post.PostID); err != nil {
+		fmt.Println(err)
+		c.Status(http.StatusInternalServerError)
+
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// getComments returns the comments on a post as threads, where each comment lists its replies. Comments are listed
+// oldest first.
+func getComments(c *gin.Context) {
+	if _, ok := getVisibleCommunity(c); !ok {
+		return
+	}
+
+	post, ok := getCommunityPost(c)
+	if !ok {
+		return
+	}
+
+	var comments []*Comment
+
+	query := "SELECT * FROM Comment WHERE fk_post_id = $1 ORDER BY created_at, comment_id"
+
+	err := pgxscan.Select(c, dbPool, &comments, query, post.PostID)
+	if err != nil {
+		fmt.Println(err)
+		c.Status(http.StatusInternalServerError)
+
+		return
+	}
+
+	threads := []*Comment{}
+	byID := make(map[int]*Comment, len(comments))
+
+	for _, comment := range comments {
+		comment.Replies = []*Comment{}
+		byID[comment.CommentID] = comment
+	}
+
+	for _, comment := range comments {
+		if comment.ParentID == nil {
+			threads = append(threads, comment)
+		} else {
+			parent := byID[*comment.ParentID]
+			parent.Replies = append(parent.Replies, comment)
+		}
+	}
+
+	c.JSON(http.StatusOK, threads)
+}
+
+// createComment creates a comment by the logged in user on a post, replying to the comment given by parent_id if
+// any. The user has to be a member of the community of the post.
+func createComment(c *gin.Context) {
+	var comment Comment
+
+	if err := c.Bind(&comment); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if _, ok := getMemberCommunity(c); !ok {
+		return
+	}
+
+	post, ok := getCommunityPost(c)
+	if !ok {
+		return
+	}
+
+	// Replies are on the same post as the comment they reply to
+	query := `INSERT INTO Comment(fk_post_id, fk_parent_id, fk_user_id, content)
+		SELECT $1, $2, $3, $4 WHERE $2::int IS NULL OR EXISTS (SELECT 1 FROM Comment WHERE comment_id = $2 AND fk_post_id = $1)
+		RETURNING *`
+
+	err := pgxscan.Get(c, dbPool, &comment, query, post.PostID, comment.ParentID, authUserID(c), comment.Content)
+	if err != nil {
+		if err.Error() == ErrNoRows {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "parent_id must be a comment on the post"})
+			return
+		}
+
+		fmt.Println(err)
+		c.Status(http.StatusInternalServerError)
+
+		return
+	}
+
+	comment.Replies = []*Comment{}
+
+	c.JSON(http.StatusCreated, comment)
+}
+
+// feedCursor is the position after the last item of a page of a feed, encoded like productCursor.
+type feedCursor struct {
+	CreatedAt time.Time `json:"t"`
+	Type      string    `json:"k"`
+	ID        int       `json:"id"`
+}
+
+func (cursor feedCursor) encode() string {
+	data, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeFeedCursor(encoded string) (feedCursor, error) {
+	var cursor feedCursor
+
+	data, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err == nil {
+		err = json.Unmarshal(data, &cursor)
+	}
+
+	return cursor, err
+}
+
+// getCommunityFeed returns a page of the posts of a community mixed with the products listed in it, newest first.
+// Products are placed by when they were listed in the community. Withdrawn products are left out.
+func getCommunityFeed(c *gin.Context) {
+	community, ok := getVisibleCommunity(c)
+	if !ok {
+		return
+	}
+
+	limit := feedPageDefaultLimit
+
+	if limitParam := c.Query("limit"); limitParam != "" {
+		var err error
+
+		limit, err = strconv.Atoi(limitParam)
+		if err != nil || limit < 1 || limit > feedPageMaxLimit {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("limit must be between 1 and %d", feedPageMaxLimit)})
+			return
+		}
+	}
+
+	// The cursor arguments are null on the first page
+	var after []interface{}
+
+	if cursorParam := c.Query("cursor"); cursorParam != "" {
+		cursor, err := decodeFeedCursor(cursorParam)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid cursor"})
+			return
+		}
+
+		after = []interface{}{cursor.CreatedAt, cursor.Type, cursor.ID}
+	} else {
+		after = []interface{}{nil, nil, nil}
+	}
+
+	// One more item than the limit is fetched to know whether there is a next page
+	query := `SELECT type, id, created_at FROM (
+			SELECT 'post' AS type, post_id AS id, created_at FROM Post WHERE fk_community_id = $1
+			UNION ALL
+			SELECT 'product', product_id, Product_Community.created_at FROM Product_Community
+				JOIN Product ON product_id = fk_product_id
+				WHERE fk_community_id = $1 AND state <> 'withdrawn'
+		) feed
+		WHERE $2::timestamptz IS NULL OR (created_at, type, id) < ($2, $3::text, $4::int)
+		ORDER BY created_at DESC, type DESC, id DESC LIMIT $5`
+
+	var entries []*struct {
+		Type      string
+		ID        int
+		CreatedAt time.Time
+	}
+
+	err := pgxscan.Select(c, dbPool, &entries, query, community.CommunityID, after[0], after[1], after[2], limit+1)
+	if err != nil {
+		fmt.Println(err)
+		c.Status(http.StatusInternalServerError)
+
+		return
+	}
+
+	page := FeedPage{Items: []*FeedItem{}}
+
+	if len(entries) > limit {
+		entries = entries[:limit]
+		last := entries[limit-1]
+
+		nextCursor := feedCursor{CreatedAt: last.CreatedAt, Type: last.Type, ID: last.ID}.encode()
+		page.NextCursor = &nextCursor
+	}
+
+	var postIDs, productIDs []int
+
+	for _, entry := range entries {
+		if entry.Type == "post" {
+			postIDs = append(postIDs, entry.ID)
+		} else {
+			productIDs = append(productIDs, entry.ID)
+		}
+	}
+
+	var posts []*Post
+
+	var products []*Product
+
+	err = pgxscan.Select(c, dbPool, &posts, "SELECT "+postColumns+" FROM Post WHERE post_id = ANY($1)", postIDs)
+	if err == nil {
+		err = pgxscan.Select(c, dbPool, &products, "SELECT * FROM Product WHERE product_id = ANY($1)", productIDs)
+	}
+
+	if err == nil {
+		err = attachProductImages(c, products...)
+	}
+
+	if err != nil {
+		fmt.Println(err)
+		c.Status(http.StatusInternalServerError)
+
+		return
+	}
+
+	postsByID := make(map[int]*Post, len(posts))
+	for _, post := range posts {
+		postsByID[post.PostID] = post
+	}
+
+	productsByID := make(map[int]*Product, len(products))
+	for _, product := range products {
+		productsByID[product.ProductID] = product
+	}
+
+	for _, entry := range entries {
+		item := &FeedItem{Type: entry.Type, CreatedAt: entry.CreatedAt}
+		if entry.Type == "post" {
+			item.Post = postsByID[entry.ID]
+		} else {
+			item.Product = productsByID[entry.ID]
+		}
+
+		page.Items = append(page.Items, item)
+	}
+
+	c.JSON(http.StatusOK, page)
+}
+
+// getProductCommunities returns the communities a product is listed in, leaving out invite-only communities the logged
+// in user is not a member of.
+func getProductCommunities(c *gin.Context) {
+	productID := c.Param("product_id")
+
+	if checkIfProductExist(c, productID) == false {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Product does not exist"})
+		return
+	}
+
+	communities := []*Community{}
+
+	// Invite-only communities are only shown to their members, like their content
+	query := `SELECT Community.* FROM Community JOIN Product_Community ON community_id = fk_community_id
+		WHERE fk_product_id = $1 AND (visibility <> $2 OR EXISTS (SELECT 1 FROM User_Community
+			WHERE User_Community.fk_community_id = community_id AND fk_user_id = $3))
+		ORDER BY community_id`
+
+	err := pgxscan.Select(c, dbPool, &communities, query, productID, visibilityInviteOnly, authUserID(c))
+	if err != nil {
+		fmt.Println(err)
+		c.Status(http.StatusInternalServerError)
+
+		return
+	}
+
+	c.JSON(http.StatusOK, communities)
+}
+
+// setProductCommunities lists a product in the communities in the request, and removes it from any other
+// communities. The seller has to be a member of the communities. Communities the product already is listed in keep
+// its place in their feeds.
+func setProductCommunities(c *gin.Context) {
+	var body struct {
+		CommunityIDs []int `json:"community_ids" binding:"required"`
+	}
+
+	if err := c.Bind(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	productID := c.Param("product_id")
+	notMember := false
+
+	err := dbPool.BeginFunc(c, func(tx pgx.Tx) error {
+		var invalid bool
+
+		query := `SELECT EXISTS (SELECT 1 FROM unnest($1::int[]) AS listed(community_id)
+			WHERE community_id NOT IN (
+				SELECT community_id FROM Community JOIN User_Community ON community_id = fk_community_id
+				WHERE fk_user_id = $2 AND NOT archived
+			))`
+		if err := tx.QueryRow(c, query, body.CommunityIDs, authUserID(c)).Scan(&invalid); err != nil {
+			return err
+		}
+
+		if invalid {
+			notMember = true
+			return nil
+		}
+
+		query = "DELETE FROM Product_Community WHERE fk_product_id = $1 AND NOT fk_community_id = ANY($2)"
+		if _, err := tx.Exec(c, query, productID, body.CommunityIDs); err != nil {
+			return err
+		}
+
+		query = `INSERT INTO Product_Community(fk_product_id, fk_community_id) SELECT $1, unnest($2::int[])
+			ON CONFLICT DO NOTHING`
+		_, err := tx.Exec(c, query, productID, body.CommunityIDs)
+
+		return err
+	})
+
+	if err != nil {
+		fmt.Println(err)
+		c.Status(http.StatusInternalServerError)
+
+		return
+	}
+
+	if notMember {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Products can only be listed in communities you are a member of"})
+		return
+	}
+
+	getProductCommunities(c)
+}
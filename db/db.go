@@ -0,0 +1,339 @@
+// Package db contains the database schema as versioned migrations, along with the seed data used for development
+// and tests.
+package db
+
+import (
+	"context"
+	"embed"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+)
+
+// Migrations are named <version>_<name>.up.sql and <version>_<name>.down.sql.
+//
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+//go:embed seed.sql
+var seedSQL string
+
+//go:embed victorkill.jpeg
+var seedPicture []byte
+
+// Key of the advisory lock held while migrating, so concurrently started servers do not migrate at the same time.
+const migrationLockKey = 7283926
+
+const createMigrationsTable = `CREATE TABLE IF NOT EXISTS schema_migrations (
+    version INT PRIMARY KEY,
+    name VARCHAR NOT NULL,
+    applied_at TIMESTAMPTZ NOT NULL DEFAULT now()
+)`
+
+// Migration is a versioned change of the schema.
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+// MigrationStatus is a migration along with the time it was applied, which is nil if it has not been applied.
+type MigrationStatus struct {
+	Migration
+	AppliedAt *time.Time
+}
+
+// Migrations returns the embedded migrations ordered by version.
+func Migrations() ([]Migration, error) {
+	files, err := fs.ReadDir(migrationFiles, "migrations")
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := make(map[int]*Migration)
+
+	for _, file := range files {
+		base, direction := strings.TrimSuffix(file.Name(), ".sql"), ""
+
+		switch {
+		case strings.HasSuffix(base, ".up"):
+			base, direction = strings.TrimSuffix(base, ".up"), "up"
+		case strings.HasSuffix(base, ".down"):
+			base, direction = strings.TrimSuffix(base, ".down"), "down"
+		default:
+			return nil, fmt.Errorf("migration %s is neither an up nor a down migration", file.Name())
+		}
+
+		versionString, name, _ := strings.Cut(base, "_")
+
+		version, err := strconv.Atoi(versionString)
+		if err != nil || version <= 0 {
+			return nil, fmt.Errorf("migration %s does not start with a version number", file.Name())
+		}
+
+		data, err := fs.ReadFile(migrationFiles, path.Join("migrations", file.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		migration, ok := byVersion[version]
+		if !ok {
+			migration = &Migration{Version: version, Name: name}
+			byVersion[version] = migration
+		} else if migration.Name != name {
+			return nil, fmt.Errorf("migrations %s and %s have the same version", migration.Name, name)
+		}
+
+		if direction == "up" {
+			migration.Up = string(data)
+		} else {
+			migration.Down = string(data)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+
+	for _, migration := range byVersion {
+		if migration.Up == "" || migration.Down == "" {
+			return nil, fmt.Errorf("migration %d_%s needs both an up and a down migration", migration.Version, migration.Name)
+		}
+
+		migrations = append(migrations, *migration)
+	}
+
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+
+	return migrations, nil
+}
+
+// withMigrationLock runs f on a connection holding the migration lock, after making sure the migrations table exists.
+func withMigrationLock(ctx context.Context, pool *pgxpool.Pool, f func(conn *pgxpool.Conn) error) error {
+	conn, err := pool.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Release()
+
+	if _, err = conn.Exec(ctx, "SELECT pg_advisory_lock($1)", migrationLockKey); err != nil {
+		return err
+	}
+
+	defer func() {
+		_, _ = conn.Exec(context.Background(), "SELECT pg_advisory_unlock($1)", migrationLockKey)
+	}()
+
+	if err = baseline(ctx, conn); err != nil {
+		return err
+	}
+
+	return f(conn)
+}
+
+// baseline creates the migrations table. A database created from the init.sql that preceded the migrations already
+// has the initial schema, which is then recorded as applied.
+func baseline(ctx context.Context, conn *pgxpool.Conn) error {
+	var exists bool
+
+	err := conn.QueryRow(ctx, "SELECT to_regclass('schema_migrations') IS NOT NULL").Scan(&exists)
+	if err != nil || exists {
+		return err
+	}
+
+	if _, err = conn.Exec(ctx, createMigrationsTable); err != nil {
+		return err
+	}
+
+	var hasSchema bool
+
+	err = conn.QueryRow(ctx, "SELECT to_regclass('users') IS NOT NULL").Scan(&hasSchema)
+	if err != nil || !hasSchema {
+		return err
+	}
+
+	_, err = conn.Exec(ctx, "INSERT INTO schema_migrations(version, name) VALUES(1, 'init')")
+
+	return err
+}
+
+// appliedVersions returns the times the applied migrations were applied, by version.
+func appliedVersions(ctx context.Context, conn *pgxpool.Conn) (map[int]time.Time, error) {
+	rows, err := conn.Query(ctx, "SELECT version, applied_at FROM schema_migrations")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	applied := make(map[int]time.Time)
+
+	for rows.Next() {
+		var version int
+
+		var appliedAt time.Time
+
+		if err = rows.Scan(&version, &appliedAt); err != nil {
+			return nil, err
+		}
+
+		applied[version] = appliedAt
+	}
+
+	return applied, rows.Err()
+}
+
+// runMigration runs the SQL of a migration and records the change of version in the same transaction.
+func runMigration(ctx context.Context, conn *pgxpool.Conn, sql string, record string, args ...interface{}) error {
+	return conn.BeginFunc(ctx, func(tx pgx.Tx) error {
+		if _, err := tx.Exec(ctx, sql); err != nil {
+			return err
+		}
+
+		_, err := tx.Exec(ctx, record, args...)
+
+		return err
+	})
+}
+
+// Up applies all migrations that have not been applied yet, in order, and returns the applied migrations.
+func Up(ctx context.Context, pool *pgxpool.Pool) ([]Migration, error) {
+	migrations, err := Migrations()
+	if err != nil {
+		return nil, err
+	}
+
+	var done []Migration
+
+	err = withMigrationLock(ctx, pool, func(conn *pgxpool.Conn) error {
+		applied, err := appliedVersions(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		for _, migration := range migrations {
+			if _, ok := applied[migration.Version]; ok {
+				continue
+			}
+
+			record := "INSERT INTO schema_migrations(version, name) VALUES($1, $2)"
+
+			err = runMigration(ctx, conn, migration.Up, record, migration.Version, migration.Name)
+			if err != nil {
+				return fmt.Errorf("migration %d_%s: %w", migration.Version, migration.Name, err)
+			}
+
+			done = append(done, migration)
+		}
+
+		return nil
+	})
+
+	return done, err
+}
+
+// Down reverts the latest steps applied migrations, newest first, and returns the reverted migrations.
+func Down(ctx context.Context, pool *pgxpool.Pool, steps int) ([]Migration, error) {
+	migrations, err := Migrations()
+	if err != nil {
+		return nil, err
+	}
+
+	var done []Migration
+
+	err = withMigrationLock(ctx, pool, func(conn *pgxpool.Conn) error {
+		applied, err := appliedVersions(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		for i := len(migrations) - 1; i >= 0 && len(done) < steps; i-- {
+			migration := migrations[i]
+			if _, ok := applied[migration.Version]; !ok {
+				continue
+			}
+
+			record := "DELETE FROM schema_migrations WHERE version = $1"
+
+			err = runMigration(ctx, conn, migration.Down, record, migration.Version)
+			if err != nil {
+				return fmt.Errorf("migration %d_%s: %w", migration.Version, migration.Name, err)
+			}
+
+			done = append(done, migration)
+		}
+
+		return nil
+	})
+
+	return done, err
+}
+
+// Status returns all migrations along with when they were applied.
+func Status(ctx context.Context, pool *pgxpool.Pool) ([]MigrationStatus, error) {
+	migrations, err := Migrations()
+	if err != nil {
+		return nil, err
+	}
+
+	var statuses []MigrationStatus
+
+	err = withMigrationLock(ctx, pool, func(conn *pgxpool.Conn) error {
+		applied, err := appliedVersions(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		for _, migration := range migrations {
+			status := MigrationStatus{Migration: migration}
+			if appliedAt, ok := applied[migration.Version]; ok {
+				status.AppliedAt = &appliedAt
+			}
+
+			statuses = append(statuses, status)
+		}
+
+		return nil
+	})
+
+	return statuses, err
+}
+
+// Seed inserts the seed data used for development and tests. It does nothing and returns false if there already are
+// users in the database. The seed picture is inserted into Legacy_Picture.
+func Seed(ctx context.Context, pool *pgxpool.Pool) (bool, error) {
+	seeded := false
+
+	err := pool.BeginFunc(ctx, func(tx pgx.Tx) error {
+		// Keeps concurrent seeds from both inserting the data
+		if _, err := tx.Exec(ctx, "LOCK TABLE Users IN EXCLUSIVE MODE"); err != nil {
+			return err
+		}
+
+		var hasUsers bool
+
+		err := tx.QueryRow(ctx, "SELECT EXISTS (SELECT 1 FROM Users)").Scan(&hasUsers)
+		if err != nil || hasUsers {
+			return err
+		}
+
+		if _, err = tx.Exec(ctx, seedSQL); err != nil {
+			return err
+		}
+
+		// The server moves the picture to the blob store along with the pictures stored before there was one
+		_, err = tx.Exec(ctx, "INSERT INTO Legacy_Picture(fk_user_id, picture) VALUES(1, $1)", seedPicture)
+		seeded = err == nil
+
+		return err
+	})
+
+	return seeded, err
+}
@@ -0,0 +1,24 @@
+package db
+
+import "testing"
+
+func TestMigrations(t *testing.T) {
+	migrations, err := Migrations()
+	if err != nil {
+		t.Fatal("migrations", err)
+	}
+
+	if len(migrations) == 0 {
+		t.Fatal("expected embedded migrations")
+	}
+
+	for i, migration := range migrations {
+		if migration.Version != i+1 {
+			t.Errorf("expected migration %s to have version %d, got %d", migration.Name, i+1, migration.Version)
+		}
+
+		if migration.Name == "" {
+			t.Errorf("expected migration %d to have a name", migration.Version)
+		}
+	}
+}
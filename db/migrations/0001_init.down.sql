@@ -0,0 +1,9 @@
+DROP TABLE User_Community;
+DROP TABLE Community;
+DROP TABLE Chats;
+DROP TABLE Buying_Product;
+DROP TABLE Pinned_Product;
+DROP TABLE Review;
+DROP TABLE Product;
+DROP TABLE User_Followers;
+DROP TABLE Users;
@@ -0,0 +1,2 @@
+/* Databases created from the old init.sql may already have the column */
+ALTER TABLE Users ADD COLUMN IF NOT EXISTS verified BOOLEAN NOT NULL DEFAULT false;
@@ -0,0 +1,6 @@
+DROP TABLE Flag;
+
+ALTER TABLE Community DROP COLUMN archived;
+
+ALTER TABLE Users DROP COLUMN banned;
+ALTER TABLE Users DROP COLUMN role;
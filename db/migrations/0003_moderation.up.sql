@@ -0,0 +1,16 @@
+/* Databases created from the old init.sql may already have the columns and table */
+ALTER TABLE Users ADD COLUMN IF NOT EXISTS role VARCHAR NOT NULL DEFAULT 'user' CHECK (role IN ('user', 'admin'));
+ALTER TABLE Users ADD COLUMN IF NOT EXISTS banned BOOLEAN NOT NULL DEFAULT false;
+
+ALTER TABLE Community ADD COLUMN IF NOT EXISTS archived BOOLEAN NOT NULL DEFAULT false;
+
+CREATE TABLE IF NOT EXISTS Flag (
+    flag_id SERIAL PRIMARY KEY,
+    reason VARCHAR,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    fk_reporter_id INT REFERENCES Users(user_id) ON DELETE CASCADE NOT NULL,
+    fk_product_id INT REFERENCES Product(product_id) ON DELETE CASCADE,
+    fk_review_id INT REFERENCES Review(review_id) ON DELETE CASCADE,
+    /* A flag is either for a product or a review */
+    CHECK ((fk_product_id IS NULL) <> (fk_review_id IS NULL))
+);
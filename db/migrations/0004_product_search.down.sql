@@ -0,0 +1,4 @@
+DROP TRIGGER product_search_update ON Product;
+DROP FUNCTION product_search_update();
+DROP TABLE Product_Search;
+DROP FUNCTION product_search_document(regconfig, VARCHAR, VARCHAR, VARCHAR);
@@ -0,0 +1,41 @@
+/* Search documents of the products, kept in their own table so they are not part of the product rows */
+CREATE TABLE Product_Search (
+    fk_product_id INT PRIMARY KEY REFERENCES Product(product_id) ON DELETE CASCADE,
+    swedish tsvector NOT NULL,
+    english tsvector NOT NULL
+);
+
+CREATE INDEX product_search_swedish_idx ON Product_Search USING GIN (swedish);
+CREATE INDEX product_search_english_idx ON Product_Search USING GIN (english);
+
+/* The name weighs more than the category, which weighs more than the description */
+CREATE FUNCTION product_search_document(config regconfig, name VARCHAR, category VARCHAR, description VARCHAR)
+RETURNS tsvector AS $$
+    SELECT setweight(to_tsvector(config, coalesce(name, '')), 'A') ||
+        setweight(to_tsvector(config, coalesce(category, '')), 'B') ||
+        setweight(to_tsvector(config, coalesce(description, '')), 'C')
+$$ LANGUAGE SQL IMMUTABLE;
+
+CREATE FUNCTION product_search_update() RETURNS trigger AS $$
+BEGIN
+    INSERT INTO Product_Search (fk_product_id, swedish, english)
+    VALUES (
+        NEW.product_id,
+        product_search_document('swedish', NEW.name, NEW.category, NEW.description),
+        product_search_document('english', NEW.name, NEW.category, NEW.description)
+    )
+    ON CONFLICT (fk_product_id) DO UPDATE SET swedish = EXCLUDED.swedish, english = EXCLUDED.english;
+
+    RETURN NULL;
+END
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER product_search_update AFTER INSERT OR UPDATE OF name, category, description ON Product
+    FOR EACH ROW EXECUTE FUNCTION product_search_update();
+
+INSERT INTO Product_Search (fk_product_id, swedish, english)
+SELECT
+    product_id,
+    product_search_document('swedish', name, category, description),
+    product_search_document('english', name, category, description)
+FROM Product;
@@ -0,0 +1,31 @@
+DROP TRIGGER category_search_update ON Category;
+DROP FUNCTION category_search_update();
+
+DROP TRIGGER product_search_update ON Product;
+
+ALTER TABLE Product ADD COLUMN category VARCHAR;
+
+UPDATE Product SET category = Category.name
+FROM Category
+WHERE Category.category_id = Product.fk_category_id;
+
+ALTER TABLE Product DROP COLUMN fk_category_id;
+
+DROP TABLE Category;
+
+CREATE OR REPLACE FUNCTION product_search_update() RETURNS trigger AS $$
+BEGIN
+    INSERT INTO Product_Search (fk_product_id, swedish, english)
+    VALUES (
+        NEW.product_id,
+        product_search_document('swedish', NEW.name, NEW.category, NEW.description),
+        product_search_document('english', NEW.name, NEW.category, NEW.description)
+    )
+    ON CONFLICT (fk_product_id) DO UPDATE SET swedish = EXCLUDED.swedish, english = EXCLUDED.english;
+
+    RETURN NULL;
+END
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER product_search_update AFTER INSERT OR UPDATE OF name, category, description ON Product
+    FOR EACH ROW EXECUTE FUNCTION product_search_update();
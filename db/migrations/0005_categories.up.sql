@@ -0,0 +1,58 @@
+CREATE TABLE Category (
+    category_id SERIAL PRIMARY KEY,
+    name VARCHAR NOT NULL,
+    fk_parent_id INT REFERENCES Category(category_id) ON DELETE RESTRICT,
+    CHECK (fk_parent_id <> category_id)
+);
+
+/* Category names are unique regardless of case, so the same category can not be added twice */
+CREATE UNIQUE INDEX category_name_idx ON Category (lower(name));
+
+/* The free-form categories of the products become top level categories */
+INSERT INTO Category (name)
+SELECT DISTINCT ON (lower(trim(category))) trim(category)
+FROM Product
+WHERE trim(coalesce(category, '')) <> ''
+ORDER BY lower(trim(category)), trim(category);
+
+ALTER TABLE Product ADD COLUMN fk_category_id INT REFERENCES Category(category_id);
+
+UPDATE Product SET fk_category_id = Category.category_id
+FROM Category
+WHERE lower(Category.name) = lower(trim(Product.category));
+
+/* The search documents now get the category name from the Category table */
+DROP TRIGGER product_search_update ON Product;
+
+ALTER TABLE Product DROP COLUMN category;
+
+CREATE OR REPLACE FUNCTION product_search_update() RETURNS trigger AS $$
+DECLARE
+    category_name VARCHAR := (SELECT name FROM Category WHERE category_id = NEW.fk_category_id);
+BEGIN
+    INSERT INTO Product_Search (fk_product_id, swedish, english)
+    VALUES (
+        NEW.product_id,
+        product_search_document('swedish', NEW.name, category_name, NEW.description),
+        product_search_document('english', NEW.name, category_name, NEW.description)
+    )
+    ON CONFLICT (fk_product_id) DO UPDATE SET swedish = EXCLUDED.swedish, english = EXCLUDED.english;
+
+    RETURN NULL;
+END
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER product_search_update AFTER INSERT OR UPDATE OF name, fk_category_id, description ON Product
+    FOR EACH ROW EXECUTE FUNCTION product_search_update();
+
+/* Renaming a category updates the search documents of its products */
+CREATE FUNCTION category_search_update() RETURNS trigger AS $$
+BEGIN
+    UPDATE Product SET fk_category_id = fk_category_id WHERE fk_category_id = NEW.category_id;
+
+    RETURN NULL;
+END
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER category_search_update AFTER UPDATE OF name ON Category
+    FOR EACH ROW EXECUTE FUNCTION category_search_update();
@@ -0,0 +1,16 @@
+/* Only the pictures not yet moved to the blob store are restored */
+ALTER TABLE Product DROP COLUMN picture_key;
+ALTER TABLE Product ADD COLUMN picture bytea;
+
+ALTER TABLE Users DROP COLUMN picture_key;
+ALTER TABLE Users ADD COLUMN picture bytea;
+
+UPDATE Users SET picture = Legacy_Picture.picture
+FROM Legacy_Picture
+WHERE Legacy_Picture.fk_user_id = Users.user_id;
+
+UPDATE Product SET picture = Legacy_Picture.picture
+FROM Legacy_Picture
+WHERE Legacy_Picture.fk_product_id = Product.product_id;
+
+DROP TABLE Legacy_Picture;
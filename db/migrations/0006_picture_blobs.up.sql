@@ -0,0 +1,22 @@
+/* Pictures are stored in the blob store and referenced by key. The pictures stored in the database are moved to
+   Legacy_Picture, from which the server moves them to the blob store. */
+CREATE TABLE Legacy_Picture (
+    legacy_picture_id SERIAL PRIMARY KEY,
+    fk_user_id INT REFERENCES Users(user_id) ON DELETE CASCADE,
+    fk_product_id INT REFERENCES Product(product_id) ON DELETE CASCADE,
+    picture bytea NOT NULL,
+    /* A picture is either of a user or a product */
+    CHECK ((fk_user_id IS NULL) <> (fk_product_id IS NULL))
+);
+
+INSERT INTO Legacy_Picture (fk_user_id, picture)
+SELECT user_id, picture FROM Users WHERE length(picture) > 0;
+
+INSERT INTO Legacy_Picture (fk_product_id, picture)
+SELECT product_id, picture FROM Product WHERE length(picture) > 0;
+
+ALTER TABLE Users DROP COLUMN picture;
+ALTER TABLE Users ADD COLUMN picture_key VARCHAR;
+
+ALTER TABLE Product DROP COLUMN picture;
+ALTER TABLE Product ADD COLUMN picture_key VARCHAR;
@@ -0,0 +1,9 @@
+DROP TRIGGER product_image_update ON Product_Image;
+DROP FUNCTION product_image_update();
+
+/* Only the primary images are kept, as the pictures of the products */
+UPDATE Product SET picture_key = (
+    SELECT image_key FROM Product_Image WHERE fk_product_id = Product.product_id ORDER BY position LIMIT 1
+);
+
+DROP TABLE Product_Image;
@@ -0,0 +1,35 @@
+/* The images of a product in the order they are shown, the first being the primary image */
+CREATE TABLE Product_Image (
+    product_image_id SERIAL PRIMARY KEY,
+    fk_product_id INT REFERENCES Product(product_id) ON DELETE CASCADE NOT NULL,
+    image_key VARCHAR NOT NULL,
+    position INT NOT NULL CHECK (position >= 0),
+    /* Deferred so that images can be reordered within a transaction */
+    UNIQUE (fk_product_id, position) DEFERRABLE INITIALLY DEFERRED
+);
+
+INSERT INTO Product_Image (fk_product_id, image_key, position)
+SELECT product_id, picture_key, 0 FROM Product WHERE picture_key IS NOT NULL;
+
+/* The picture of a product is its primary image, kept for listings and older clients */
+CREATE FUNCTION product_image_update() RETURNS trigger AS $$
+DECLARE
+    product INT;
+BEGIN
+    IF TG_OP = 'DELETE' THEN
+        product := OLD.fk_product_id;
+    ELSE
+        product := NEW.fk_product_id;
+    END IF;
+
+    UPDATE Product SET picture_key = (
+        SELECT image_key FROM Product_Image WHERE fk_product_id = product ORDER BY position LIMIT 1
+    )
+    WHERE product_id = product;
+
+    RETURN NULL;
+END
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER product_image_update AFTER INSERT OR UPDATE OR DELETE ON Product_Image
+FOR EACH ROW EXECUTE FUNCTION product_image_update();
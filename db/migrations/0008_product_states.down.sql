@@ -0,0 +1,8 @@
+DROP TABLE Product_State_Change;
+
+DROP INDEX buying_product_accepted_idx;
+ALTER TABLE Buying_Product DROP COLUMN created_at;
+ALTER TABLE Buying_Product DROP COLUMN status;
+
+ALTER TABLE Product DROP CONSTRAINT product_sold_buyer;
+ALTER TABLE Product DROP COLUMN state;
@@ -0,0 +1,29 @@
+/* Products are available, reserved for a buyer whose purchase request was accepted, sold to that buyer or withdrawn
+   by the seller */
+ALTER TABLE Product ADD COLUMN state VARCHAR NOT NULL DEFAULT 'available'
+    CHECK (state IN ('available', 'reserved', 'sold', 'withdrawn'));
+
+UPDATE Product SET state = 'sold' WHERE fk_buyer_id IS NOT NULL;
+
+/* Only sold products have a buyer */
+ALTER TABLE Product ADD CONSTRAINT product_sold_buyer CHECK ((state = 'sold') = (fk_buyer_id IS NOT NULL));
+
+/* Buying_Product holds the purchase requests of products */
+ALTER TABLE Buying_Product ADD COLUMN status VARCHAR NOT NULL DEFAULT 'pending'
+    CHECK (status IN ('pending', 'accepted', 'rejected', 'cancelled'));
+ALTER TABLE Buying_Product ADD COLUMN created_at TIMESTAMPTZ NOT NULL DEFAULT now();
+
+/* A product is reserved for at most one buyer */
+CREATE UNIQUE INDEX buying_product_accepted_idx ON Buying_Product (fk_product_id) WHERE status = 'accepted';
+
+CREATE TABLE Product_State_Change (
+    product_state_change_id SERIAL PRIMARY KEY,
+    fk_product_id INT REFERENCES Product(product_id) ON DELETE CASCADE NOT NULL,
+    from_state VARCHAR NOT NULL,
+    to_state VARCHAR NOT NULL,
+    /* The user making the change */
+    fk_user_id INT REFERENCES Users(user_id) ON DELETE SET NULL,
+    changed_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX product_state_change_product_idx ON Product_State_Change (fk_product_id);
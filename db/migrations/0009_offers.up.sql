@@ -0,0 +1,16 @@
+/* Price negotiations between the seller of a product and a buyer. The buyer makes the first offer, and the seller
+   and buyer take turns countering until one of them accepts or rejects the pending offer. */
+CREATE TABLE Offer (
+    offer_id SERIAL PRIMARY KEY,
+    fk_product_id INT REFERENCES Product(product_id) ON DELETE CASCADE NOT NULL,
+    fk_buyer_id INT REFERENCES Users(user_id) ON DELETE CASCADE NOT NULL,
+    /* The user making the offer, the buyer or the seller */
+    fk_proposer_id INT REFERENCES Users(user_id) ON DELETE CASCADE NOT NULL,
+    price INT NOT NULL CHECK (price >= 0),
+    status VARCHAR NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'accepted', 'rejected', 'countered')),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    responded_at TIMESTAMPTZ
+);
+
+/* A negotiation has at most one pending offer */
+CREATE UNIQUE INDEX offer_pending_idx ON Offer (fk_product_id, fk_buyer_id) WHERE status = 'pending';
@@ -0,0 +1,2 @@
+DROP INDEX review_sale_idx;
+ALTER TABLE Review DROP COLUMN fk_product_id;
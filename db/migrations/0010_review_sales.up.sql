@@ -0,0 +1,14 @@
+/* Reviews are made by the buyer and the seller of a sold product of each other. Reviews made before this have no
+   product, unless a sale between the reviewer and the reviewed user is found. */
+ALTER TABLE Review ADD COLUMN fk_product_id INT REFERENCES Product(product_id) ON DELETE SET NULL;
+
+UPDATE Review SET fk_product_id = sale.product_id FROM (
+    SELECT DISTINCT ON (Review.fk_reviewer_id, Review.fk_owner_id) Review.review_id, Product.product_id
+    FROM Review JOIN Product ON Product.fk_buyer_id IS NOT NULL
+        AND ((Product.fk_user_id = Review.fk_owner_id AND Product.fk_buyer_id = Review.fk_reviewer_id)
+            OR (Product.fk_user_id = Review.fk_reviewer_id AND Product.fk_buyer_id = Review.fk_owner_id))
+    ORDER BY Review.fk_reviewer_id, Review.fk_owner_id, Review.review_id, Product.product_id
+) sale WHERE Review.review_id = sale.review_id;
+
+/* Each party reviews a sale once */
+CREATE UNIQUE INDEX review_sale_idx ON Review (fk_product_id, fk_reviewer_id);
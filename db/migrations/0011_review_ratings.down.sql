@@ -0,0 +1,2 @@
+DROP INDEX review_owner_idx;
+ALTER TABLE Review DROP CONSTRAINT review_rating;
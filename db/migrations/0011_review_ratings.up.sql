@@ -0,0 +1,7 @@
+/* The rating of a user is the average of the ratings of the reviews of the user, from one to five stars */
+ALTER TABLE Review ADD CONSTRAINT review_rating CHECK (rating BETWEEN 1 AND 5);
+
+CREATE INDEX review_owner_idx ON Review (fk_owner_id);
+
+/* Ratings could be set directly or left stale before, and are recomputed */
+UPDATE Users SET rating = (SELECT AVG(rating) FROM Review WHERE fk_owner_id = user_id);
@@ -0,0 +1,5 @@
+DROP TABLE Review_Reply;
+
+ALTER TABLE Review DROP COLUMN edited;
+ALTER TABLE Review DROP COLUMN edited_at;
+ALTER TABLE Review DROP COLUMN created_at;
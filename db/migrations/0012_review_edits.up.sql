@@ -0,0 +1,14 @@
+/* Reviews and replies are marked as edited once they have been changed. Existing reviews get the time of the migration
+   as their creation time. */
+ALTER TABLE Review ADD COLUMN created_at TIMESTAMPTZ NOT NULL DEFAULT now();
+ALTER TABLE Review ADD COLUMN edited_at TIMESTAMPTZ;
+ALTER TABLE Review ADD COLUMN edited BOOLEAN GENERATED ALWAYS AS (edited_at IS NOT NULL) STORED;
+
+/* The public reply of the reviewed user, at most one per review */
+CREATE TABLE Review_Reply (
+    fk_review_id INT PRIMARY KEY REFERENCES Review(review_id) ON DELETE CASCADE,
+    content VARCHAR NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    edited_at TIMESTAMPTZ,
+    edited BOOLEAN GENERATED ALWAYS AS (edited_at IS NOT NULL) STORED
+);
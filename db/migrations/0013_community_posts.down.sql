@@ -0,0 +1,3 @@
+DROP TABLE Product_Community;
+DROP TABLE Comment;
+DROP TABLE Post;
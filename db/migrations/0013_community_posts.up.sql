@@ -0,0 +1,33 @@
+/* Posts of the members of a community */
+CREATE TABLE Post (
+    post_id SERIAL PRIMARY KEY,
+    fk_community_id INT REFERENCES Community(community_id) ON DELETE CASCADE NOT NULL,
+    fk_user_id INT REFERENCES Users(user_id) ON DELETE CASCADE NOT NULL,
+    title VARCHAR NOT NULL,
+    content VARCHAR NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX post_community_idx ON Post (fk_community_id, created_at);
+
+/* Comments on posts, which reply to the post or to another comment on it */
+CREATE TABLE Comment (
+    comment_id SERIAL PRIMARY KEY,
+    fk_post_id INT REFERENCES Post(post_id) ON DELETE CASCADE NOT NULL,
+    fk_parent_id INT REFERENCES Comment(comment_id) ON DELETE CASCADE,
+    fk_user_id INT REFERENCES Users(user_id) ON DELETE CASCADE NOT NULL,
+    content VARCHAR NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX comment_post_idx ON Comment (fk_post_id);
+
+/* The communities a product is listed in, since created_at */
+CREATE TABLE Product_Community (
+    fk_product_id INT REFERENCES Product(product_id) ON DELETE CASCADE NOT NULL,
+    fk_community_id INT REFERENCES Community(community_id) ON DELETE CASCADE NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    PRIMARY KEY(fk_product_id, fk_community_id)
+);
+
+CREATE INDEX product_community_community_idx ON Product_Community (fk_community_id, created_at);
@@ -0,0 +1,8 @@
+DROP TABLE Community_Join_Request;
+DROP TABLE Community_Invite;
+
+ALTER TABLE User_Community DROP COLUMN role;
+ALTER TABLE User_Community DROP CONSTRAINT user_community_member;
+
+ALTER TABLE Community DROP COLUMN fk_owner_id;
+ALTER TABLE Community DROP COLUMN visibility;
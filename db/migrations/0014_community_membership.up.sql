@@ -0,0 +1,28 @@
+/* Communities are created by users, who own them, or by admins. Invite-only communities are joined with an invite or
+   a join request approved by a moderator. */
+ALTER TABLE Community ADD COLUMN visibility VARCHAR NOT NULL DEFAULT 'public' CHECK (visibility IN ('public', 'invite_only'));
+ALTER TABLE Community ADD COLUMN fk_owner_id INT REFERENCES Users(user_id) ON DELETE SET NULL;
+
+/* Users joined communities more than once before */
+DELETE FROM User_Community a USING User_Community b
+WHERE a.fk_user_id = b.fk_user_id AND a.fk_community_id = b.fk_community_id AND a.user_community_id > b.user_community_id;
+
+ALTER TABLE User_Community ADD CONSTRAINT user_community_member UNIQUE (fk_user_id, fk_community_id);
+ALTER TABLE User_Community ADD COLUMN role VARCHAR NOT NULL DEFAULT 'member' CHECK (role IN ('member', 'moderator'));
+
+CREATE TABLE Community_Invite (
+    code VARCHAR PRIMARY KEY,
+    fk_community_id INT REFERENCES Community(community_id) ON DELETE CASCADE NOT NULL,
+    /* The moderator creating the invite */
+    fk_user_id INT REFERENCES Users(user_id) ON DELETE CASCADE NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    expires_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE TABLE Community_Join_Request (
+    fk_community_id INT REFERENCES Community(community_id) ON DELETE CASCADE NOT NULL,
+    fk_user_id INT REFERENCES Users(user_id) ON DELETE CASCADE NOT NULL,
+    status VARCHAR NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'approved', 'rejected')),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    PRIMARY KEY(fk_community_id, fk_user_id)
+);
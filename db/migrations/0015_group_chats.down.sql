@@ -0,0 +1,2 @@
+DROP TABLE Group_Chat_Member;
+DROP TABLE Group_Chat;
@@ -0,0 +1,16 @@
+/* Group chats have a title and any number of members, which are added and removed by the owner */
+CREATE TABLE Group_Chat (
+    group_chat_id SERIAL PRIMARY KEY,
+    title VARCHAR NOT NULL,
+    fk_owner_id INT REFERENCES Users(user_id) ON DELETE SET NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE TABLE Group_Chat_Member (
+    fk_group_chat_id INT REFERENCES Group_Chat(group_chat_id) ON DELETE CASCADE NOT NULL,
+    fk_user_id INT REFERENCES Users(user_id) ON DELETE CASCADE NOT NULL,
+    added_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    PRIMARY KEY(fk_group_chat_id, fk_user_id)
+);
+
+CREATE INDEX group_chat_member_user_idx ON Group_Chat_Member(fk_user_id);
@@ -0,0 +1,13 @@
+/* The messages of the websocket chat, which were only kept in Redis before. The chat_id is the channel of the chat:
+   public, community.<community_id>, group.<group_chat_id> or the UUID of the channel of two users. */
+CREATE TABLE Message (
+    message_id UUID PRIMARY KEY,
+    chat_id VARCHAR NOT NULL,
+    fk_sender_id INT REFERENCES Users(user_id) ON DELETE SET NULL,
+    /* Whom the sender addressed the message to, a user ID or the chat_id */
+    recipient VARCHAR NOT NULL,
+    content VARCHAR NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX message_chat_idx ON Message(chat_id, created_at);
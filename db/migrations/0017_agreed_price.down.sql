@@ -0,0 +1 @@
+ALTER TABLE Buying_Product DROP COLUMN price;
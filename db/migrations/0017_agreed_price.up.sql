@@ -0,0 +1,11 @@
+/* The price agreed on when a purchase request is accepted: the price of the accepted offer, or otherwise the price of
+   the product at the time */
+ALTER TABLE Buying_Product ADD COLUMN price INT CHECK (price >= 0);
+
+UPDATE Buying_Product SET price = coalesce(
+    (SELECT Offer.price FROM Offer
+        WHERE Offer.fk_product_id = Buying_Product.fk_product_id AND Offer.fk_buyer_id = Buying_Product.fk_user_id
+            AND Offer.status = 'accepted'
+        ORDER BY Offer.responded_at DESC LIMIT 1),
+    (SELECT Product.price FROM Product WHERE Product.product_id = Buying_Product.fk_product_id))
+WHERE status = 'accepted';
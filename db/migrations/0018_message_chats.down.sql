@@ -0,0 +1,14 @@
+ALTER TABLE Message ADD COLUMN chat_id VARCHAR;
+
+UPDATE Message SET chat_id = coalesce(fk_chat_id::text, 'group.' || fk_group_chat_id, 'community.' || fk_community_id, 'public');
+
+ALTER TABLE Message ALTER COLUMN chat_id SET NOT NULL;
+ALTER TABLE Message DROP COLUMN fk_chat_id;
+ALTER TABLE Message DROP COLUMN fk_group_chat_id;
+ALTER TABLE Message DROP COLUMN fk_community_id;
+
+CREATE INDEX message_chat_idx ON Message(chat_id, created_at);
+
+ALTER TABLE Chats DROP COLUMN hidden_by_2;
+ALTER TABLE Chats DROP COLUMN hidden_by_1;
+ALTER TABLE Chats DROP COLUMN chat_id;
@@ -0,0 +1,58 @@
+/* The chat of two users is tied to the channel of the websocket chat with its chat_id, which is set when they first
+   chat. Each user can hide the chat, which is deleted once both have hidden it. */
+ALTER TABLE Chats ADD COLUMN chat_id UUID UNIQUE;
+ALTER TABLE Chats ADD COLUMN hidden_by_1 BOOLEAN NOT NULL DEFAULT false;
+ALTER TABLE Chats ADD COLUMN hidden_by_2 BOOLEAN NOT NULL DEFAULT false;
+
+/* A message is sent in the chat of two users, a group chat or a community, or in the public chat if none is set */
+ALTER TABLE Message ADD COLUMN fk_chat_id UUID REFERENCES Chats(chat_id) ON DELETE CASCADE;
+ALTER TABLE Message ADD COLUMN fk_group_chat_id INT REFERENCES Group_Chat(group_chat_id) ON DELETE CASCADE;
+ALTER TABLE Message ADD COLUMN fk_community_id INT REFERENCES Community(community_id) ON DELETE CASCADE;
+
+UPDATE Message SET fk_community_id = community_id
+FROM (SELECT community_id, 'community.' || community_id AS channel FROM Community) c
+WHERE chat_id = c.channel;
+
+UPDATE Message SET fk_group_chat_id = group_chat_id
+FROM (SELECT group_chat_id, 'group.' || group_chat_id AS channel FROM Group_Chat) g
+WHERE chat_id = g.channel;
+
+/* The channel of two users is found from the messages they sent each other, whose recipient is the other user */
+CREATE TEMPORARY TABLE Direct_Channel ON COMMIT DROP AS
+SELECT DISTINCT ON (least(sender, recipient), greatest(sender, recipient)) channel, sender, recipient
+FROM (
+    SELECT chat_id::uuid AS channel, fk_sender_id AS sender, recipient::int AS recipient, created_at
+    FROM Message
+    WHERE chat_id ~ '^[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}$' AND recipient ~ '^[0-9]{1,9}$'
+        AND fk_sender_id IS NOT NULL
+) m
+WHERE EXISTS (SELECT 1 FROM Users WHERE user_id = recipient)
+ORDER BY least(sender, recipient), greatest(sender, recipient), created_at DESC;
+
+UPDATE Chats SET chat_id = d.channel
+FROM Direct_Channel d
+WHERE (fk_user_id_1 = d.sender AND fk_user_id_2 = d.recipient) OR (fk_user_id_1 = d.recipient AND fk_user_id_2 = d.sender);
+
+INSERT INTO Chats(fk_user_id_1, fk_user_id_2, chat_id)
+SELECT d.sender, d.recipient, d.channel FROM Direct_Channel d
+WHERE NOT EXISTS (
+    SELECT 1 FROM Chats
+    WHERE (fk_user_id_1 = d.sender AND fk_user_id_2 = d.recipient) OR (fk_user_id_1 = d.recipient AND fk_user_id_2 = d.sender)
+);
+
+UPDATE Message SET fk_chat_id = chat_id::uuid
+WHERE chat_id ~ '^[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}$'
+    AND EXISTS (SELECT 1 FROM Chats WHERE Chats.chat_id = Message.chat_id::uuid);
+
+/* Messages whose chat is gone would otherwise end up in the public chat */
+DELETE FROM Message
+WHERE chat_id <> 'public' AND fk_chat_id IS NULL AND fk_group_chat_id IS NULL AND fk_community_id IS NULL;
+
+ALTER TABLE Message DROP COLUMN chat_id;
+ALTER TABLE Message ADD CONSTRAINT message_one_chat CHECK (num_nonnulls(fk_chat_id, fk_group_chat_id, fk_community_id) <= 1);
+
+CREATE INDEX message_chat_idx ON Message(fk_chat_id, created_at);
+CREATE INDEX message_group_chat_idx ON Message(fk_group_chat_id, created_at);
+CREATE INDEX message_community_idx ON Message(fk_community_id, created_at);
+CREATE INDEX message_public_idx ON Message(created_at)
+    WHERE fk_chat_id IS NULL AND fk_group_chat_id IS NULL AND fk_community_id IS NULL;
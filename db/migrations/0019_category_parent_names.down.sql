@@ -0,0 +1,3 @@
+DROP INDEX category_name_idx;
+
+CREATE UNIQUE INDEX category_name_idx ON Category (lower(name));
@@ -0,0 +1,5 @@
+/* Category names are unique among the subcategories of a parent, so subcategories of different parents can share a
+   name. Top level categories have no parent, which counts as the same parent. */
+DROP INDEX category_name_idx;
+
+CREATE UNIQUE INDEX category_name_idx ON Category (coalesce(fk_parent_id, 0), lower(name));
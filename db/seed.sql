@@ -0,0 +1,39 @@
+/* test users user_id = 1 & 2, rated by the reviews below */
+/* The picture of user_id = 1 is inserted from victorkill.jpeg when seeding */
+INSERT INTO Users (name, phone_number, password, business) VALUES ('Gustav', '+12029182132', '$2a$12$IDEtMuDeOB/m4e.BVwEJ0O/FdUXKNF3sq8BnNHFIQpdf8h/NJCJHi','true');
+
+INSERT INTO USERS (name, phone_number, password, rating,business) VALUES ('Victor', '+12027455483', '$2a$12$IDEtMuDeOB/m4e.BVwEJ0O/FdUXKNF3sq8BnNHFIQpdf8h/NJCJHi', 2,'true');
+
+/* test categories category_id = 1 & 2 & 3 & 4 & 5 */
+INSERT INTO Category (name) VALUES ('Furniture'), ('Vehicles');
+INSERT INTO Category (name, fk_parent_id) VALUES ('Sofas', 1), ('Beds', 1), ('Cars', 2);
+
+/* test products product_id = 1 */
+INSERT INTO Product (name,service,price,description, fk_user_id, fk_category_id ) VALUES ('Soffa','true',1,'Hej',1,3);
+/* test products product_id = 1 & 2*/
+INSERT INTO Product (name,service,price,description, fk_user_id, fk_category_id ) VALUES ('Couch','true',1,'Couch description',1,3);
+
+INSERT INTO Product (name,service,price,description, fk_user_id, fk_category_id ) VALUES ('Bed','true',1,'Bed description',1,4);
+
+/* test products product_id = 4 for user_id 2, sold to user_id 1 */
+INSERT INTO Product (name,service,price,description, fk_user_id, fk_category_id, state, fk_buyer_id ) VALUES ('Car','true',1,'Car description',2,5,'sold',1);
+
+/* test review review_id = 1 of the sale of product_id = 4 */
+INSERT INTO Review (rating,content, fk_reviewer_id, fk_owner_id, fk_product_id) VALUES (2,'SÄMST',1,2,4);
+
+/* test communities community_id = 1 & 2 & 3 */
+INSERT INTO Community (name) VALUES ('Clothes'), ('Politics'), ('Memes');
+
+/* test pinned_product pinnedproduct_id = 1 */
+INSERT INTO Pinned_Product (fk_product_id, fk_user_id) VALUES (1,1);
+
+INSERT INTO User_Community(fk_user_id, fk_community_id) VALUES (1,2);
+
+/* test user_followers user_follower_id = 1 */
+INSERT INTO User_Followers(fk_user_id, fk_followed_id) VALUES (2, 1);
+
+/* test buying_product */
+INSERT INTO Buying_Product (fk_product_id, fk_user_id) VALUES (1,2);
+
+/* test chats */
+INSERT INTO Chats (fk_user_id_1, fk_user_id_2) VALUES (1,2);
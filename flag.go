@@ -0,0 +1,71 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/georgysavva/scany/pgxscan"
+	"github.com/gin-gonic/gin"
+)
+
+// createFlag flags the product or review for review by an admin. Exactly one of productID and reviewID is set.
+func createFlag(c *gin.Context, productID, reviewID *int) {
+	var body struct {
+		Reason *string `json:"reason"`
+	}
+
+	if c.Request.ContentLength != 0 {
+		if err := c.BindJSON(&body); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	var flag Flag
+
+	query := "INSERT INTO Flag(reason, fk_reporter_id, fk_product_id, fk_review_id) VALUES($1, $2, $3, $4) RETURNING *"
+	err := pgxscan.Get(c, dbPool, &flag, query, body.Reason, authUserID(c), productID, reviewID)
+
+	if err != nil {
+		fmt.Println(err)
+		c.Status(http.StatusInternalServerError)
+
+		return
+	}
+
+	c.JSON(http.StatusCreated, flag)
+}
+
+// flagProduct flags the product in the product_id parameter.
+func flagProduct(c *gin.Context) {
+	productID, err := strconv.Atoi(c.Param("product_id"))
+	if err != nil || !checkIfProductExist(c, c.Param("product_id")) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Product does not exist"})
+		return
+	}
+
+	createFlag(c, &productID, nil)
+}
+
+// flagReview flags the review in the review_id parameter, which must be a review of the user in the user_id parameter.
+func flagReview(c *gin.Context) {
+	var reviewID int
+
+	query := "SELECT review_id FROM Review WHERE review_id = $1 AND fk_owner_id = $2"
+	err := pgxscan.Get(c, dbPool, &reviewID, query, c.Param("review_id"), c.Param("user_id"))
+
+	if err != nil {
+		if err.Error() == ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Review does not exist"})
+			return
+		}
+
+		fmt.Println(err)
+		c.Status(http.StatusInternalServerError)
+
+		return
+	}
+
+	createFlag(c, nil, &reviewID)
+}
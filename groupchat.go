@@ -0,0 +1,313 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/georgysavva/scany/pgxscan"
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v4"
+)
+
+var errUnknownMember = errors.New("no such user")
+
+// attachGroupChatMembers sets the channels and the members of the group chats, with one query for all of them.
+func attachGroupChatMembers(ctx context.Context, chats ...*GroupChat) error {
+	chatIDs := make([]int, len(chats))
+	byID := make(map[int]*GroupChat, len(chats))
+
+	for i, chat := range chats {
+		chat.Channel = groupChannelPrefix + strconv.Itoa(chat.GroupChatID)
+		chat.Members = []*GroupChatMember{}
+		chatIDs[i] = chat.GroupChatID
+		byID[chat.GroupChatID] = chat
+	}
+
+	if len(chats) == 0 {
+		return nil
+	}
+
+	var members []*GroupChatMember
+
+	query := "SELECT * FROM Group_Chat_Member WHERE fk_group_chat_id = ANY($1) ORDER BY fk_group_chat_id, added_at, fk_user_id"
+
+	err := pgxscan.Select(ctx, dbPool, &members, query, chatIDs)
+	if err != nil {
+		return err
+	}
+
+	for _, member := range members {
+		chat := byID[member.GroupChatID]
+		chat.Members = append(chat.Members, member)
+	}
+
+	return nil
+}
+
+// getUserGroupChat returns the group chat in the group_id parameter with its members, if the logged in user is a
+// member of it. Otherwise it responds with an error and returns false.
+func getUserGroupChat(c *gin.Context) (GroupChat, bool) {
+	var chat GroupChat
+
+	query := `SELECT * FROM Group_Chat WHERE group_chat_id = $1
+		AND EXISTS (SELECT 1 FROM Group_Chat_Member WHERE fk_group_chat_id = group_chat_id AND fk_user_id = $2)`
+
+	err := pgxscan.Get(c, dbPool, &chat, query, c.Param("group_id"), authUserID(c))
+	if err == nil {
+		err = attachGroupChatMembers(c, &chat)
+	}
+
+	if err != nil {
+		if err.Error() == ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Chat does not exist"})
+			return chat, false
+		}
+
+		fmt.Println(err)
+		c.Status(http.StatusInternalServerError)
+
+		return chat, false
+	}
+
+	return chat, true
+}
+
+// getOwnedGroupChat returns the group chat in the group_id parameter like getUserGroupChat, if the logged in user owns
+// it.
+func getOwnedGroupChat(c *gin.Context) (GroupChat, bool) {
+	chat, ok := getUserGroupChat(c)
+	if ok && (chat.OwnerID == nil || *chat.OwnerID != authUserID(c)) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only the owner of the chat can change it"})
+		return chat, false
+	}
+
+	return chat, ok
+}
+
+// getGroupChats returns the group chats of the user with their members, newest first.
+func getGroupChats(c *gin.Context) {
+	chats := []*GroupChat{}
+
+	query := `SELECT * FROM Group_Chat
+		WHERE group_chat_id IN (SELECT fk_group_chat_id FROM Group_Chat_Member WHERE fk_user_id = $1)
+		ORDER BY created_at DESC, group_chat_id DESC`
+
+	err := pgxscan.Select(c, dbPool, &chats, query, authUserID(c))
+	if err == nil {
+		err = attachGroupChatMembers(c, chats...)
+	}
+
+	if err != nil {
+		fmt.Println(err)
+		c.Status(http.StatusInternalServerError)
+
+		return
+	}
+
+	c.JSON(http.StatusOK, chats)
+}
+
+// getGroupChat returns a group chat of the user with its members.
+func getGroupChat(c *gin.Context) {
+	chat, ok := getUserGroupChat(c)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, chat)
+}
+
+// createGroupChat creates a group chat with the title and the members in the request. The user creating it owns it
+// and is a member too.
+func createGroupChat(c *gin.Context) {
+	var body struct {
+		Title     string `json:"title" binding:"required"`
+		MemberIDs []int  `json:"member_ids"`
+	}
+
+	if err := c.Bind(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID := authUserID(c)
+
+	memberIDs := []int{userID}
+	listed := map[int]bool{userID: true}
+
+	for _, memberID := range body.MemberIDs {
+		if !listed[memberID] {
+			memberIDs = append(memberIDs, memberID)
+			listed[memberID] = true
+		}
+	}
+
+	var chat GroupChat
+
+	err := dbPool.BeginFunc(c, func(tx pgx.Tx) error {
+		query := "INSERT INTO Group_Chat(title, fk_owner_id) VALUES($1, $2) RETURNING *"
+		if err := pgxscan.Get(c, tx, &chat, query, body.Title, userID); err != nil {
+			return err
+		}
+
+		query = `INSERT INTO Group_Chat_Member(fk_group_chat_id, fk_user_id)
+			SELECT $1, user_id FROM Users WHERE user_id = ANY($2)`
+
+		result, err := tx.Exec(c, query, chat.GroupChatID, memberIDs)
+		if err != nil {
+			return err
+		}
+
+		// Returning an error rolls back the chat
+		if int(result.RowsAffected()) != len(memberIDs) {
+			return errUnknownMember
+		}
+
+		return nil
+	})
+
+	if err == nil {
+		err = attachGroupChatMembers(c, &chat)
+	}
+
+	if err != nil {
+		if errors.Is(err, errUnknownMember) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "All members have to be existing users"})
+			return
+		}
+
+		fmt.Println(err)
+		c.Status(http.StatusInternalServerError)
+
+		return
+	}
+
+	c.JSON(http.StatusCreated, chat)
+}
+
+// updateGroupChat changes the title of a group chat to the title in the request.
+func updateGroupChat(c *gin.Context) {
+	var body struct {
+		Title string `json:"title" binding:"required"`
+	}
+
+	if err := c.Bind(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	chat, ok := getOwnedGroupChat(c)
+	if !ok {
+		return
+	}
+
+	_, err := dbPool.Exec(c, "UPDATE Group_Chat SET title = $2 WHERE group_chat_id = $1", chat.GroupChatID, body.Title)
+	if err != nil {
+		fmt.Println(err)
+		c.Status(http.StatusInternalServerError)
+
+		return
+	}
+
+	chat.Title = body.Title
+
+	c.JSON(http.StatusOK, chat)
+}
+
+// deleteGroupChat deletes a group chat and its messages for all its members.
+func deleteGroupChat(c *gin.Context) {
+	chat, ok := getOwnedGroupChat(c)
+	if !ok {
+		return
+	}
+
+	_, err := dbPool.Exec(c, "DELETE FROM Group_Chat WHERE group_chat_id = $1", chat.GroupChatID)
+	if err != nil {
+		fmt.Println(err)
+		c.Status(http.StatusInternalServerError)
+
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// addGroupChatMember adds the user in the request to a group chat.
+func addGroupChatMember(c *gin.Context) {
+	var member GroupChatMember
+
+	if err := c.Bind(&member); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	chat, ok := getOwnedGroupChat(c)
+	if !ok {
+		return
+	}
+
+	if !checkIfUserExist(c, strconv.Itoa(member.UserID)) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User does not exist"})
+		return
+	}
+
+	query := `INSERT INTO Group_Chat_Member(fk_group_chat_id, fk_user_id) VALUES($1, $2)
+		ON CONFLICT DO NOTHING RETURNING *`
+
+	err := pgxscan.Get(c, dbPool, &member, query, chat.GroupChatID, member.UserID)
+	if err != nil {
+		if err.Error() == ErrNoRows {
+			c.JSON(http.StatusConflict, gin.H{"error": "User is already a member of the chat"})
+			return
+		}
+
+		fmt.Println(err)
+		c.Status(http.StatusInternalServerError)
+
+		return
+	}
+
+	c.JSON(http.StatusCreated, member)
+}
+
+// removeGroupChatMember removes the member in the member_id parameter from a group chat. The owner removes members,
+// and the other members can only remove themselves, which leaves the chat. The owner can not leave the chat.
+func removeGroupChatMember(c *gin.Context) {
+	chat, ok := getUserGroupChat(c)
+	if !ok {
+		return
+	}
+
+	userID := authUserID(c)
+	owner := chat.OwnerID != nil && *chat.OwnerID == userID
+	self := c.Param("member_id") == strconv.Itoa(userID)
+
+	switch {
+	case owner && self:
+		c.JSON(http.StatusConflict, gin.H{"error": "The owner can not leave the chat"})
+		return
+	case !owner && !self:
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only the owner of the chat can remove other members"})
+		return
+	}
+
+	query := "DELETE FROM Group_Chat_Member WHERE fk_group_chat_id = $1 AND fk_user_id::text = $2"
+
+	result, err := dbPool.Exec(c, query, chat.GroupChatID, c.Param("member_id"))
+	if err != nil {
+		fmt.Println(err)
+		c.Status(http.StatusInternalServerError)
+
+		return
+	}
+
+	if result.RowsAffected() == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User is not a member of the chat"})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
@@ -1,15 +1,13 @@
 package main
 
 import (
-	"encoding/base64"
 	"fmt"
 	"net/http"
 	"strconv"
-	"time"
 
 	"github.com/georgysavva/scany/pgxscan"
 	"github.com/gin-gonic/gin"
-	"github.com/golang-jwt/jwt"
+	"github.com/jackc/pgx/v4"
 	"golang.org/x/crypto/bcrypt"
 )
 
@@ -64,19 +62,7 @@ func getPinnedProducts(c *gin.Context) {
 		return
 	}
 
-	var pinnedProducts []*Product
-
-	query := "SELECT * from Product WHERE product_id IN (SELECT fk_product_id FROM Pinned_Product WHERE fk_user_id = $1)"
-	err := pgxscan.Select(c, dbPool, &pinnedProducts, query, user)
-
-	if err != nil {
-		fmt.Println(err)
-		c.Status(http.StatusInternalServerError)
-
-		return
-	}
-
-	c.JSON(http.StatusOK, pinnedProducts)
+	listProducts(c, "product_id IN (SELECT fk_product_id FROM Pinned_Product WHERE fk_user_id = $1)", user)
 }
 
 func deletePinnedProduct(c *gin.Context) {
@@ -134,25 +120,11 @@ func getUserProducts(c *gin.Context) {
 		return
 	}
 
-	var products []*Product
-
-	var query string
 	if owned == "false" {
-		query = "SELECT * from Product WHERE fk_user_id != $1"
+		listProducts(c, "fk_user_id != $1", user)
 	} else {
-		query = "SELECT * from Product WHERE fk_user_id = $1"
+		listProducts(c, "fk_user_id = $1", user)
 	}
-
-	err := pgxscan.Select(c, dbPool, &products, query, user)
-
-	if err != nil {
-		fmt.Println(err)
-		c.Status(http.StatusInternalServerError)
-
-		return
-	}
-
-	c.JSON(http.StatusOK, products)
 }
 
 // Adds a product to the userID
@@ -171,11 +143,14 @@ func createProduct(c *gin.Context) {
 		return
 	}
 
-	// Encode picture to base64
-	product.Picture = []byte(base64.StdEncoding.EncodeToString(product.Picture))
+	if product.CategoryID != nil && checkIfCategoryExist(c, *product.CategoryID) == false {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Category does not exist"})
+		return
+	}
 
-	query := "INSERT INTO Product(name,service,price,description,picture,category,fk_user_id) VALUES($1,$2,$3,$4,$5,$6,$7) RETURNING *"
-	err = pgxscan.Get(c, dbPool, &product, query, product.Name, product.Service, product.Price, product.Description, product.Picture, product.Category, userID)
+	// The images are uploaded separately
+	query := "INSERT INTO Product(name,service,price,description,fk_category_id,fk_user_id) VALUES($1,$2,$3,$4,$5,$6) RETURNING *"
+	err = pgxscan.Get(c, dbPool, &product, query, product.Name, product.Service, product.Price, product.Description, product.CategoryID, userID)
 
 	if err != nil {
 		fmt.Println(err)
@@ -184,9 +159,13 @@ func createProduct(c *gin.Context) {
 		return
 	}
 
+	product.Images = []*ProductImage{}
+
 	c.JSON(http.StatusCreated, product)
 }
 
+// createReview creates a review of a user by the other party of the completed sale of the product in the request. The
+// buyer reviews the seller and the seller the buyer, once per sale.
 func createReview(c *gin.Context) {
 	var review Review
 
@@ -202,20 +181,60 @@ func createReview(c *gin.Context) {
 		return
 	}
 
+	if review.ReviewerID != authUserID(c) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You can only leave reviews as yourself"})
+		return
+	}
+
 	if checkIfUserExist(c, strconv.Itoa(review.ReviewerID)) == false {
 		c.JSON(http.StatusNotFound, gin.H{"error": "User does not exist"})
 		return
 	}
 
-	if checkForDupReview(c, review.ReviewerID, owner) == true {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "You have already left a review on this user"})
+	var sellerID int
+
+	var buyerID *int
+
+	query := "SELECT fk_user_id, fk_buyer_id FROM Product WHERE product_id = $1"
+
+	err = dbPool.QueryRow(c, query, *review.ProductID).Scan(&sellerID, &buyerID)
+	if err != nil {
+		if err.Error() == ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Product does not exist"})
+			return
+		}
+
+		fmt.Println(err)
+		c.Status(http.StatusInternalServerError)
+
+		return
+	}
 
+	if buyerID == nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "Only sold products can be reviewed"})
 		return
 	}
 
-	query := "INSERT INTO Review(rating,content, fk_reviewer_id, fk_owner_id) VALUES($1,$2, $3, $4) RETURNING *"
+	ownerID, _ := strconv.Atoi(owner)
+	seller := review.ReviewerID == sellerID && ownerID == *buyerID
+	buyer := review.ReviewerID == *buyerID && ownerID == sellerID
 
-	err = pgxscan.Get(c, dbPool, &review, query, review.Rating, review.Content, review.ReviewerID, owner)
+	if !seller && !buyer {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You can only review the other party of a sale you took part in"})
+		return
+	}
+
+	if checkForDupReview(c, review.ReviewerID, *review.ProductID) == true {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "You have already reviewed this sale"})
+
+		return
+	}
+
+	// The rating of the reviewed user is updated along with the review
+	err = changeReviews(c, ownerID, func(tx pgx.Tx) error {
+		query := "INSERT INTO Review(rating,content, fk_reviewer_id, fk_owner_id, fk_product_id) VALUES($1,$2, $3, $4, $5) RETURNING *"
+		return pgxscan.Get(c, tx, &review, query, review.Rating, review.Content, review.ReviewerID, ownerID, review.ProductID)
+	})
 
 	if err != nil {
 		fmt.Println(err)
@@ -224,17 +243,13 @@ func createReview(c *gin.Context) {
 		return
 	}
 
-	c.JSON(http.StatusCreated, review)
-
-	query = "UPDATE Users SET rating = (SELECT AVG(rating) FROM Review WHERE fk_owner_id = $1) WHERE user_id = $1"
+	review.Reply = nil
 
-	_, er := dbPool.Exec(c, query, review.OwnerID)
-	if er != nil {
-		fmt.Println(er)
-		c.JSON(http.StatusInternalServerError, review)
-	}
+	c.JSON(http.StatusCreated, review)
 }
 
+// joinCommunity makes the user a member of a community. Invite-only communities are joined with an invite code, or
+// by a moderator approving a join request.
 func joinCommunity(c *gin.Context) {
 	var userCommunity UserCommunity
 
@@ -250,16 +265,51 @@ func joinCommunity(c *gin.Context) {
 		return
 	}
 
-	query := "INSERT INTO User_Community(fk_user_id, fk_community_id) VALUES($1, $2) RETURNING fk_user_id, fk_community_id"
+	var community Community
+
+	query := "SELECT * FROM Community WHERE community_id = $1"
+	err = pgxscan.Get(c, dbPool, &community, query, userCommunity.CommunityID)
+
+	if err != nil {
+		if err.Error() == ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Community does not exist"})
+			return
+		}
+
+		fmt.Println(err)
+		c.Status(http.StatusInternalServerError)
+
+		return
+	}
+
+	if community.Archived {
+		c.JSON(http.StatusConflict, gin.H{"error": "Community is archived"})
+		return
+	}
+
+	if community.Visibility == visibilityInviteOnly && !checkIfInviteValid(c, community.CommunityID, userCommunity.InviteCode) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Community is invite-only, and can only be joined with a valid invite"})
+		return
+	}
+
+	query = `INSERT INTO User_Community(fk_user_id, fk_community_id) VALUES($1, $2) ON CONFLICT DO NOTHING
+		RETURNING fk_user_id, fk_community_id, role`
 	err = pgxscan.Get(c, dbPool, &userCommunity, query, user, userCommunity.CommunityID)
 
 	if err != nil {
+		if err.Error() == ErrNoRows {
+			c.JSON(http.StatusConflict, gin.H{"error": "You are already a member of the community"})
+			return
+		}
+
 		fmt.Println(err)
 		c.Status(http.StatusInternalServerError)
 
 		return
 	}
 
+	userCommunity.InviteCode = ""
+
 	c.JSON(http.StatusCreated, userCommunity)
 }
 
@@ -276,6 +326,9 @@ func getUserReviews(c *gin.Context) {
 	var reviews []*Review
 
 	err := pgxscan.Select(c, dbPool, &reviews, query, user)
+	if err == nil {
+		err = attachReviewReplies(c, reviews...)
+	}
 
 	if err != nil {
 		fmt.Println(err)
@@ -290,7 +343,7 @@ func getUserReviews(c *gin.Context) {
 func getCommunities(c *gin.Context) {
 	var communities []*Community
 
-	query := "SELECT * FROM Community"
+	query := "SELECT * FROM Community WHERE NOT archived"
 	err := pgxscan.Select(c, dbPool, &communities, query)
 
 	if err != nil {
@@ -304,19 +357,7 @@ func getCommunities(c *gin.Context) {
 }
 
 func getProducts(c *gin.Context) {
-	var products []*Product
-
-	query := "SELECT * FROM Product"
-	err := pgxscan.Select(c, dbPool, &products, query)
-
-	if err != nil {
-		fmt.Println(err)
-		c.Status(http.StatusInternalServerError)
-
-		return
-	}
-
-	c.JSON(http.StatusOK, products)
+	listProducts(c, "true")
 }
 
 func getUsers(c *gin.Context) {
@@ -352,7 +393,7 @@ func getUserCommunities(c *gin.Context) {
 	var communities []*Community
 
 	if joined == "false" {
-		query = " SELECT * from Community WHERE community_id NOT IN (SELECT fk_community_id FROM User_Community WHERE fk_user_id = $1)"
+		query = " SELECT * from Community WHERE NOT archived AND community_id NOT IN (SELECT fk_community_id FROM User_Community WHERE fk_user_id = $1)"
 	} else {
 		query = " SELECT * from Community WHERE community_id IN (SELECT fk_community_id FROM User_Community WHERE fk_user_id = $1)"
 	}
@@ -399,6 +440,10 @@ func getProduct(c *gin.Context) {
 	query := "SELECT * FROM Product WHERE product_id = $1"
 
 	err := pgxscan.Get(c, dbPool, &result, query, productID)
+	if err == nil {
+		err = attachProductImages(c, &result)
+	}
+
 	if err != nil {
 		if err.Error() == ErrNoRows {
 			c.Status(http.StatusNotFound)
@@ -470,19 +515,7 @@ func getFollowingUsersProducts(c *gin.Context) {
 		return
 	}
 
-	var products []*Product
-
-	query := `SELECT * FROM Product WHERE fk_user_id in (SELECT user_id FROM Users WHERE user_id IN (SELECT fk_user_id FROM User_Followers WHERE fk_followed_id=$1))`
-	err := pgxscan.Select(c, dbPool, &products, query, user)
-
-	if err != nil {
-		fmt.Println(err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-
-		return
-	}
-
-	c.JSON(http.StatusOK, products)
+	listProducts(c, "fk_user_id IN (SELECT fk_user_id FROM User_Followers WHERE fk_followed_id = $1)", user)
 }
 
 // createUser creates a new user.
@@ -495,9 +528,6 @@ func createUser(c *gin.Context) {
 		return
 	}
 
-	// Encode picture to base64
-	user.Picture = []byte(base64.StdEncoding.EncodeToString(user.Picture))
-
 	// Hash password
 	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(user.Password), bcrypt.DefaultCost)
 	if err != nil {
@@ -506,8 +536,9 @@ func createUser(c *gin.Context) {
 	}
 
 	user.Password = string(hashedPassword)
-	query := "INSERT INTO Users(name, phone_number, password, picture, business) VALUES($1,$2, $3, $4, $5) RETURNING *"
-	err = pgxscan.Get(c, dbPool, &user, query, user.Name, user.PhoneNumber, user.Password, user.Picture, user.Business)
+	// The picture is uploaded separately
+	query := "INSERT INTO Users(name, phone_number, password, business) VALUES($1, $2, $3, $4) RETURNING *"
+	err = pgxscan.Get(c, dbPool, &user, query, user.Name, user.PhoneNumber, user.Password, user.Business)
 
 	if err != nil {
 		fmt.Println(err)
@@ -516,6 +547,12 @@ func createUser(c *gin.Context) {
 		return
 	}
 
+	// Add the user to the Redis database used by the websocket chat
+	_, err = redisCli.UserCreate(strconv.Itoa(user.UserID), user.Name)
+	if err != nil {
+		fmt.Println(err)
+	}
+
 	c.JSON(http.StatusCreated, user)
 }
 
@@ -536,8 +573,10 @@ func deleteUser(c *gin.Context) {
 		return
 	}
 
-	query = "DELETE FROM Users where user_id = $1"
-	_, err = dbPool.Exec(c, query, user)
+	var picture *Image
+
+	query = "DELETE FROM Users where user_id = $1 RETURNING picture_key"
+	err = dbPool.QueryRow(c, query, user).Scan(&picture)
 
 	if err != nil {
 		fmt.Println(err)
@@ -546,6 +585,10 @@ func deleteUser(c *gin.Context) {
 		return
 	}
 
+	if picture != nil {
+		deleteImages(c, *picture)
+	}
+
 	c.JSON(http.StatusNoContent, gin.H{"deleted": user})
 }
 
@@ -556,9 +599,7 @@ func deleteProduct(c *gin.Context) {
 		return
 	}
 
-	query := "DELETE FROM Product where product_id = $1"
-	_, err := dbPool.Exec(c, query, product)
-
+	_, err := deleteProductWithImages(c, product)
 	if err != nil {
 		fmt.Println(err)
 		c.Status(http.StatusInternalServerError)
@@ -578,11 +619,6 @@ func login(c *gin.Context) {
 
 	var loginUser LoginUser
 
-	var response struct {
-		ID    int
-		Token string
-	}
-
 	var user User
 
 	if err := c.Bind(&loginUser); err != nil {
@@ -590,33 +626,59 @@ func login(c *gin.Context) {
 		return
 	}
 
-	query := "SELECT password, user_id FROM Users where phone_number = $1"
-	err := pgxscan.Get(c, dbPool, &user, query, loginUser.PhoneNumber)
-
+	lockout, err := redisCli.LoginLockedOut(loginUser.PhoneNumber)
 	if err != nil {
-		if err.Error() == "no rows in result set" {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Incorrect phone number"})
-			return
-		}
+		fmt.Println(err)
+		c.Status(http.StatusInternalServerError)
+
+		return
+	}
+
+	if lockout > 0 {
+		setRetryAfter(c, lockout)
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": "Too many failed logins, try again later"})
 
+		return
+	}
+
+	query := "SELECT password, user_id, banned FROM Users where phone_number = $1"
+	err = pgxscan.Get(c, dbPool, &user, query, loginUser.PhoneNumber)
+
+	if err != nil && err.Error() != ErrNoRows {
 		fmt.Println(err)
 		c.Status(http.StatusInternalServerError)
 
 		return
 	}
 
+	// Compare against a dummy hash if the user does not exist, so the response time does not reveal it
+	hashedPassword := dummyPasswordHash
+	if err == nil {
+		hashedPassword = []byte(user.Password)
+	}
+
 	// Check if password is correct
-	err = bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(loginUser.Password))
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Incorrect password"})
+	err = bcrypt.CompareHashAndPassword(hashedPassword, []byte(loginUser.Password))
+	if err != nil || user.UserID == 0 {
+		if err = loginFailed(loginUser.PhoneNumber); err != nil {
+			fmt.Println(err)
+		}
+
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Incorrect phone number or password"})
+
 		return
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
-		"nbf": time.Date(2015, 10, 10, 12, 0, 0, 0, time.UTC).Unix(),
-	})
+	if err = redisCli.LoginFailuresClear(loginUser.PhoneNumber); err != nil {
+		fmt.Println(err)
+	}
+
+	if user.Banned {
+		c.JSON(http.StatusForbidden, gin.H{"error": "User is banned"})
+		return
+	}
 
-	tokenString, err := token.SignedString(jwtKey)
+	response, err := createTokenResponse(user.UserID)
 	if err != nil {
 		fmt.Println(err)
 		c.Status(http.StatusInternalServerError)
@@ -624,9 +686,6 @@ func login(c *gin.Context) {
 		return
 	}
 
-	response.ID = user.UserID
-	response.Token = tokenString
-
 	c.JSON(http.StatusCreated, response)
 }
 
@@ -654,11 +713,10 @@ func updateUser(c *gin.Context) {
 
 	user.Password = string(hashedPassword)
 
-	// Encode picture to base64
-	user.Picture = []byte(base64.StdEncoding.EncodeToString(user.Picture))
-
-	query := "UPDATE Users SET name = $2, phone_number = $3, password = $4, picture = $5, rating = $6 WHERE user_id = $1 RETURNING *"
-	err = pgxscan.Get(c, dbPool, &user, query, userid, user.Name, user.PhoneNumber, user.Password, user.Picture, user.Rating)
+	// A changed phone number has to be verified again
+	// The rating is kept, as it follows from the reviews of the user
+	query := "UPDATE Users SET name = $2, phone_number = $3, password = $4, verified = verified AND phone_number = $3 WHERE user_id = $1 RETURNING *"
+	err = pgxscan.Get(c, dbPool, &user, query, userid, user.Name, user.PhoneNumber, user.Password)
 
 	if err != nil {
 		c.Status(http.StatusInternalServerError)
@@ -685,8 +743,17 @@ func updateProduct(c *gin.Context) {
 		return
 	}
 
-	query := "UPDATE Product SET name = $2, service = $3, price = $4, description = $5, picture = $6, category = $7,fk_buyer_id = $8 where product_id = $1 RETURNING *"
-	err := pgxscan.Get(c, dbPool, &product, query, productid, product.Name, product.Service, product.Price, product.Description, product.Picture, product.Category, product.BuyerID)
+	if product.CategoryID != nil && checkIfCategoryExist(c, *product.CategoryID) == false {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Category does not exist"})
+		return
+	}
+
+	// The state and buyer are changed through the purchase flow
+	query := "UPDATE Product SET name = $2, service = $3, price = $4, description = $5, fk_category_id = $6 where product_id = $1 RETURNING *"
+	err := pgxscan.Get(c, dbPool, &product, query, productid, product.Name, product.Service, product.Price, product.Description, product.CategoryID)
+	if err == nil {
+		err = attachProductImages(c, &product)
+	}
 
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
@@ -724,13 +791,13 @@ func checkIfProductExist(c *gin.Context, productID string) bool {
 	return true
 }
 
-// checkForDupReview is a helper function that checks if the given reviewer already has a review for the given owner
-func checkForDupReview(c *gin.Context, reviewer int, owner string) bool {
-	query := "SELECT review_id FROM Review WHERE fk_reviewer_id = $1 AND fk_owner_id = $2"
+// checkForDupReview is a helper function that checks if the given reviewer already has reviewed the given sale
+func checkForDupReview(c *gin.Context, reviewer int, productID int) bool {
+	query := "SELECT review_id FROM Review WHERE fk_reviewer_id = $1 AND fk_product_id = $2"
 
-	var result Review
+	var result int
 
-	err := pgxscan.Get(c, dbPool, &result, query, reviewer, owner)
+	err := pgxscan.Get(c, dbPool, &result, query, reviewer, productID)
 	if err != nil {
 		return false
 	}
@@ -802,7 +869,25 @@ func createChat(c *gin.Context) {
 	}
 
 	if checkIfChatExist(c, userID, strconv.Itoa(userToChat.UserID)) == true {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "You are already chatting with this person"})
+		// A chat that either user has hidden is shown to both again
+		query := `UPDATE Chats SET hidden_by_1 = false, hidden_by_2 = false
+			WHERE ((fk_user_id_1 = $1 AND fk_user_id_2 = $2) OR (fk_user_id_1 = $2 AND fk_user_id_2 = $1))
+			AND (hidden_by_1 OR hidden_by_2)`
+
+		result, err := dbPool.Exec(c, query, userID, userToChat.UserID)
+		if err != nil {
+			fmt.Println(err)
+			c.Status(http.StatusInternalServerError)
+
+			return
+		}
+
+		if result.RowsAffected() == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "You are already chatting with this person"})
+			return
+		}
+
+		c.JSON(http.StatusCreated, userToChat)
 
 		return
 	}
@@ -843,9 +928,10 @@ func getUserChats(c *gin.Context) {
 
 	var chatters []*User
 
-	query := `SELECT * FROM Users WHERE user_id IN (SELECT fk_user_id_2 FROM Chats WHERE fk_user_id_1=$1)
-						UNION
-						SELECT * FROM Users WHERE user_id IN (SELECT fk_user_id_1 FROM Chats WHERE fk_user_id_2=$1)`
+	// Only the public details of the users are selected. Chats the user has hidden are left out.
+	query := `SELECT user_id, name, picture_key, rating, business, verified, role, banned FROM Users
+						WHERE user_id IN (SELECT fk_user_id_2 FROM Chats WHERE fk_user_id_1=$1 AND NOT hidden_by_1)
+							OR user_id IN (SELECT fk_user_id_1 FROM Chats WHERE fk_user_id_2=$1 AND NOT hidden_by_2)`
 
 	err := pgxscan.Select(c, dbPool, &chatters, query, user)
 	if err != nil {
@@ -873,21 +959,44 @@ func deleteChat(c *gin.Context) {
 		return
 	}
 
-	if checkIfChatExist(c, userID, chatID) == false {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "You are not chatting with this person"})
+	// The chat is hidden from the user, and kept for the other user. Once both have hidden it, it is deleted along with
+	// its messages.
+	var channelUUID *string
 
-		return
-	}
+	var deleted bool
+
+	err := dbPool.BeginFunc(c, func(tx pgx.Tx) error {
+		query := `UPDATE Chats SET hidden_by_1 = hidden_by_1 OR fk_user_id_1 = $1, hidden_by_2 = hidden_by_2 OR fk_user_id_2 = $1
+			WHERE (fk_user_id_1 = $1 AND fk_user_id_2 = $2 AND NOT hidden_by_1) OR (fk_user_id_1 = $2 AND fk_user_id_2 = $1 AND NOT hidden_by_2)
+			RETURNING chat_id::text, hidden_by_1 AND hidden_by_2`
+
+		if err := tx.QueryRow(c, query, userID, chatID).Scan(&channelUUID, &deleted); err != nil || !deleted {
+			return err
+		}
+
+		query = "DELETE FROM Chats WHERE (fk_user_id_1 = $1 AND fk_user_id_2 = $2) OR (fk_user_id_1 = $2 AND fk_user_id_2 = $1)"
+		_, err := tx.Exec(c, query, userID, chatID)
 
-	query := "DELETE FROM Chats WHERE (fk_user_id_1 = $1 AND fk_user_id_2 = $2) OR (fk_user_id_1 = $2 AND fk_user_id_2 = $1)"
-	_, err := dbPool.Exec(c, query, userID, chatID)
+		return err
+	})
 
 	if err != nil {
+		if err.Error() == ErrNoRows {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "You are not chatting with this person"})
+			return
+		}
+
 		fmt.Println(err)
 		c.Status(http.StatusInternalServerError)
 
 		return
 	}
 
+	if deleted && channelUUID != nil {
+		if err := redisCli.ChannelMessagesDelete(*channelUUID); err != nil {
+			fmt.Println(err)
+		}
+	}
+
 	c.JSON(http.StatusNoContent, gin.H{"deleted": chatID})
 }
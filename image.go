@@ -0,0 +1,312 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	"image/draw"
+	_ "image/gif" // Register the GIF decoder
+	"image/jpeg"
+	_ "image/png" // Register the PNG decoder
+
+	"github.com/google/uuid"
+)
+
+// Limits and sizes of uploaded images. Images are scaled down to fit within imageMaxSize pixels and thumbnails
+// within thumbnailMaxSize pixels, in both width and height.
+const (
+	maxImageUploadSize = 10 << 20
+	maxImagePixels     = 40_000_000
+	imageMaxSize       = 1600
+	thumbnailMaxSize   = 320
+	imageJPEGQuality   = 85
+)
+
+var (
+	errInvalidImage  = errors.New("picture must be a JPEG, PNG or GIF image")
+	errImageTooLarge = fmt.Errorf("picture must be at most %d MB and %d megapixels", maxImageUploadSize>>20, maxImagePixels/1_000_000)
+)
+
+// Image is the blob store key of an uploaded image, which is stored at the key followed by .jpg and its thumbnail at
+// the key followed by _thumb.jpg. It is encoded in JSON as the URLs of the image and the thumbnail.
+type Image string
+
+func (img Image) imageKey() string {
+	return string(img) + ".jpg"
+}
+
+func (img Image) thumbnailKey() string {
+	return string(img) + "_thumb.jpg"
+}
+
+// MarshalJSON encodes the image as its URLs.
+func (img Image) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		URL          string `json:"url"`
+		ThumbnailURL string `json:"thumbnail_url"`
+	}{blobStore.URL(img.imageKey()), blobStore.URL(img.thumbnailKey())})
+}
+
+// UnmarshalJSON ignores images in request bodies, as images are only changed by uploading them.
+func (img *Image) UnmarshalJSON([]byte) error {
+	return nil
+}
+
+// processImage decodes an uploaded image and returns it and its thumbnail encoded as JPEG. Re-encoding the image
+// strips any metadata, such as the EXIF data of photos, after the EXIF orientation has been applied.
+func processImage(data []byte) (full []byte, thumbnail []byte, err error) {
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, nil, errInvalidImage
+	}
+
+	if config.Width*config.Height > maxImagePixels {
+		return nil, nil, errImageTooLarge
+	}
+
+	decoded, format, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, nil, errInvalidImage
+	}
+
+	img := toRGBA(decoded)
+	if format == "jpeg" {
+		img = orient(img, exifOrientation(data))
+	}
+
+	full, err = encodeJPEG(downscale(img, imageMaxSize))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	thumbnail, err = encodeJPEG(downscale(img, thumbnailMaxSize))
+
+	return full, thumbnail, err
+}
+
+// toRGBA draws img on a white background, as JPEG has no transparency.
+func toRGBA(img image.Image) *image.RGBA {
+	bounds := img.Bounds()
+	rgba := image.NewRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+
+	draw.Draw(rgba, rgba.Bounds(), image.White, image.Point{}, draw.Src)
+	draw.Draw(rgba, rgba.Bounds(), img, bounds.Min, draw.Over)
+
+	return rgba
+}
+
+func encodeJPEG(img image.Image) ([]byte, error) {
+	var buf bytes.Buffer
+
+	err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: imageJPEGQuality})
+
+	return buf.Bytes(), err
+}
+
+// downscale scales img down to fit within maxSize pixels in width and height, averaging the pixels covered by each
+// pixel of the scaled image. Images that already fit are returned as is.
+func downscale(img *image.RGBA, maxSize int) *image.RGBA {
+	width, height := img.Bounds().Dx(), img.Bounds().Dy()
+	if width <= maxSize && height <= maxSize {
+		return img
+	}
+
+	dstWidth, dstHeight := maxSize, height*maxSize/width
+	if height > width {
+		dstWidth, dstHeight = width*maxSize/height, maxSize
+	}
+
+	if dstWidth < 1 {
+		dstWidth = 1
+	}
+
+	if dstHeight < 1 {
+		dstHeight = 1
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, dstWidth, dstHeight))
+
+	for y := 0; y < dstHeight; y++ {
+		y0, y1 := y*height/dstHeight, (y+1)*height/dstHeight
+
+		for x := 0; x < dstWidth; x++ {
+			x0, x1 := x*width/dstWidth, (x+1)*width/dstWidth
+
+			var sum [4]int
+
+			for sy := y0; sy < y1; sy++ {
+				row := img.Pix[sy*img.Stride:]
+
+				for sx := x0; sx < x1; sx++ {
+					for i := range sum {
+						sum[i] += int(row[sx*4+i])
+					}
+				}
+			}
+
+			count := (y1 - y0) * (x1 - x0)
+			pixel := dst.Pix[y*dst.Stride+x*4:]
+
+			for i := range sum {
+				pixel[i] = uint8(sum[i] / count)
+			}
+		}
+	}
+
+	return dst
+}
+
+// orient transforms img so that it is displayed upright, given its EXIF orientation.
+func orient(img *image.RGBA, orientation int) *image.RGBA {
+	width, height := img.Bounds().Dx(), img.Bounds().Dy()
+
+	// source returns the coordinates of the pixel of img displayed at x, y
+	var source func(x, y int) (int, int)
+
+	switch orientation {
+	case 2: // Mirrored horizontally
+		source = func(x, y int) (int, int) { return width - 1 - x, y }
+	case 3: // Rotated 180°
+		source = func(x, y int) (int, int) { return width - 1 - x, height - 1 - y }
+	case 4: // Mirrored vertically
+		source = func(x, y int) (int, int) { return x, height - 1 - y }
+	case 5: // Transposed
+		source = func(x, y int) (int, int) { return y, x }
+	case 6: // Rotated 90° counterclockwise
+		source = func(x, y int) (int, int) { return y, height - 1 - x }
+	case 7: // Transversed
+		source = func(x, y int) (int, int) { return width - 1 - y, height - 1 - x }
+	case 8: // Rotated 90° clockwise
+		source = func(x, y int) (int, int) { return width - 1 - y, x }
+	default:
+		return img
+	}
+
+	bounds := image.Rect(0, 0, width, height)
+	if orientation >= 5 {
+		bounds = image.Rect(0, 0, height, width)
+	}
+
+	dst := image.NewRGBA(bounds)
+
+	for y := 0; y < bounds.Dy(); y++ {
+		for x := 0; x < bounds.Dx(); x++ {
+			sx, sy := source(x, y)
+			copy(dst.Pix[y*dst.Stride+x*4:y*dst.Stride+x*4+4], img.Pix[sy*img.Stride+sx*4:])
+		}
+	}
+
+	return dst
+}
+
+// exifOrientation returns the orientation in the EXIF data of a JPEG image, or 1 (upright) if it has none.
+func exifOrientation(data []byte) int {
+	if len(data) < 2 || data[0] != 0xFF || data[1] != 0xD8 {
+		return 1
+	}
+
+	// The EXIF data is in an APP1 segment before the image data
+	for i := 2; i+4 <= len(data) && data[i] == 0xFF; {
+		marker := data[i+1]
+		if marker == 0xDA || marker == 0xD9 {
+			break
+		}
+
+		length := int(binary.BigEndian.Uint16(data[i+2:]))
+		if length < 2 || i+2+length > len(data) {
+			break
+		}
+
+		segment := data[i+4 : i+2+length]
+		if marker == 0xE1 && bytes.HasPrefix(segment, []byte("Exif\x00\x00")) {
+			return tiffOrientation(segment[6:])
+		}
+
+		i += 2 + length
+	}
+
+	return 1
+}
+
+// tiffOrientation returns the orientation tag of the first image file directory of the TIFF structure EXIF data
+// is stored in.
+func tiffOrientation(tiff []byte) int {
+	const orientationTag = 0x0112
+
+	if len(tiff) < 8 {
+		return 1
+	}
+
+	var order binary.ByteOrder
+
+	switch string(tiff[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return 1
+	}
+
+	ifd := int(order.Uint32(tiff[4:]))
+	if ifd < 8 || ifd+2 > len(tiff) {
+		return 1
+	}
+
+	entries := int(order.Uint16(tiff[ifd:]))
+
+	for n := 0; n < entries; n++ {
+		entry := ifd + 2 + n*12
+		if entry+12 > len(tiff) {
+			break
+		}
+
+		if order.Uint16(tiff[entry:]) == orientationTag {
+			orientation := int(order.Uint16(tiff[entry+8:]))
+			if orientation < 1 || orientation > 8 {
+				return 1
+			}
+
+			return orientation
+		}
+	}
+
+	return 1
+}
+
+// storeImage processes an uploaded image and stores it and its thumbnail in the blob store, below prefix.
+func storeImage(ctx context.Context, prefix string, data []byte) (Image, error) {
+	full, thumbnail, err := processImage(data)
+	if err != nil {
+		return "", err
+	}
+
+	img := Image(prefix + "/" + uuid.NewString())
+
+	if err = blobStore.Put(ctx, img.imageKey(), full, "image/jpeg"); err != nil {
+		return "", err
+	}
+
+	if err = blobStore.Put(ctx, img.thumbnailKey(), thumbnail, "image/jpeg"); err != nil {
+		deleteImages(ctx, img)
+		return "", err
+	}
+
+	return img, nil
+}
+
+// deleteImages removes images and their thumbnails from the blob store. Failures are only logged, as the images are
+// no longer referenced.
+func deleteImages(ctx context.Context, images ...Image) {
+	for _, img := range images {
+		for _, key := range []string{img.imageKey(), img.thumbnailKey()} {
+			if err := blobStore.Delete(ctx, key); err != nil {
+				fmt.Println(err)
+			}
+		}
+	}
+}
@@ -0,0 +1,195 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt"
+)
+
+// Key used when no signing keys are configured outside release mode. Only suitable for development.
+const defaultJWTSecret = "my_secret_key"
+
+var errNoJWTKeys = errors.New("no JWT signing keys configured, which is required in release mode")
+
+// jwtSigningKey is a key used to sign and verify JWTs, identified by the kid header of the tokens.
+type jwtSigningKey struct {
+	id        string
+	method    jwt.SigningMethod
+	signKey   interface{}
+	verifyKey interface{}
+}
+
+var (
+	// All keys accepted when verifying tokens, by key ID
+	jwtKeys map[string]*jwtSigningKey
+	// The key used to sign new tokens
+	jwtActiveKey *jwtSigningKey
+)
+
+// loadJWTKeys loads the JWT signing keys.
+// secrets is a comma separated list of kid:secret pairs used with HS256.
+// keyFiles is a comma separated list of kid:path pairs of PEM encoded RSA (RS256) or Ed25519 (EdDSA) private keys.
+// activeID is the ID of the key used to sign new tokens, defaulting to the first listed key.
+// Without keys the insecure default key is used, except in release mode, where keys must be configured.
+// Keys that are listed but not active are still accepted when verifying, which allows rotating keys without
+// invalidating issued tokens.
+func loadJWTKeys(secrets, keyFiles, activeID string) error {
+	keys := make(map[string]*jwtSigningKey)
+
+	var order []string
+
+	addKey := func(key *jwtSigningKey) error {
+		if _, ok := keys[key.id]; ok {
+			return fmt.Errorf("duplicate JWT key ID %q", key.id)
+		}
+
+		keys[key.id] = key
+		order = append(order, key.id)
+
+		return nil
+	}
+
+	for _, pair := range splitList(secrets) {
+		id, secret, err := splitKeyPair(pair)
+		if err != nil {
+			return err
+		}
+
+		err = addKey(&jwtSigningKey{
+			id:        id,
+			method:    jwt.SigningMethodHS256,
+			signKey:   []byte(secret),
+			verifyKey: []byte(secret),
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, pair := range splitList(keyFiles) {
+		id, path, err := splitKeyPair(pair)
+		if err != nil {
+			return err
+		}
+
+		key, err := readJWTKeyFile(id, path)
+		if err != nil {
+			return err
+		}
+
+		if err = addKey(key); err != nil {
+			return err
+		}
+	}
+
+	if len(order) == 0 {
+		if gin.Mode() == gin.ReleaseMode {
+			return errNoJWTKeys
+		}
+
+		fmt.Println("No JWT signing keys configured, using insecure default key")
+
+		return loadJWTKeys("default:"+defaultJWTSecret, "", "")
+	}
+
+	if activeID == "" {
+		activeID = order[0]
+	}
+
+	activeKey, ok := keys[activeID]
+	if !ok {
+		return fmt.Errorf("JWT signing key %q is not configured", activeID)
+	}
+
+	jwtKeys = keys
+	jwtActiveKey = activeKey
+
+	return nil
+}
+
+// readJWTKeyFile reads a PEM encoded RSA or Ed25519 private key.
+func readJWTKeyFile(id, path string) (*jwtSigningKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if rsaKey, err := jwt.ParseRSAPrivateKeyFromPEM(data); err == nil {
+		return &jwtSigningKey{
+			id:        id,
+			method:    jwt.SigningMethodRS256,
+			signKey:   rsaKey,
+			verifyKey: &rsaKey.PublicKey,
+		}, nil
+	}
+
+	edKey, err := jwt.ParseEdPrivateKeyFromPEM(data)
+	if err != nil {
+		return nil, fmt.Errorf("JWT key file %s is not a RSA or Ed25519 private key", path)
+	}
+
+	privateKey, ok := edKey.(ed25519.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("JWT key file %s is not a RSA or Ed25519 private key", path)
+	}
+
+	return &jwtSigningKey{
+		id:        id,
+		method:    jwt.SigningMethodEdDSA,
+		signKey:   privateKey,
+		verifyKey: privateKey.Public(),
+	}, nil
+}
+
+// signJWT signs the token with the active key.
+func signJWT(claims jwt.Claims) (string, error) {
+	token := jwt.NewWithClaims(jwtActiveKey.method, claims)
+	token.Header["kid"] = jwtActiveKey.id
+
+	return token.SignedString(jwtActiveKey.signKey)
+}
+
+// jwtVerifyKey returns the key used to verify the token, based on its kid header.
+func jwtVerifyKey(token *jwt.Token) (interface{}, error) {
+	id, _ := token.Header["kid"].(string)
+
+	key, ok := jwtKeys[id]
+	if !ok {
+		return nil, fmt.Errorf("unknown signing key: %q", id)
+	}
+
+	// Only accept the algorithm of the key, to prevent algorithm confusion
+	if token.Method.Alg() != key.method.Alg() {
+		return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+	}
+
+	return key.verifyKey, nil
+}
+
+// splitList splits a comma separated list, ignoring empty elements.
+func splitList(list string) []string {
+	var result []string
+
+	for _, element := range strings.Split(list, ",") {
+		if element = strings.TrimSpace(element); element != "" {
+			result = append(result, element)
+		}
+	}
+
+	return result
+}
+
+// splitKeyPair splits a kid:value pair.
+func splitKeyPair(pair string) (string, string, error) {
+	id, value, ok := strings.Cut(pair, ":")
+	if !ok || id == "" || value == "" {
+		return "", "", errors.New("JWT keys must be given as kid:value pairs")
+	}
+
+	return id, value, nil
+}
@@ -2,24 +2,20 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"os"
-	"strconv"
+	"time"
 
 	"github.com/VictorAnnell/kandidat-backend/message"
 	"github.com/VictorAnnell/kandidat-backend/rediscli"
 	"github.com/VictorAnnell/kandidat-backend/websocket"
-	"github.com/georgysavva/scany/pgxscan"
-	"github.com/gin-gonic/autotls"
 	"github.com/gin-gonic/gin"
 	"github.com/jackc/pgtype"
 	"github.com/jackc/pgx/v4/pgxpool"
 	"github.com/joho/godotenv"
 )
 
-// Create the JWT key used to create the signature
-var jwtKey = []byte("my_secret_key")
-
 var (
 	dbPool            *pgxpool.Pool
 	serverURL         string
@@ -31,6 +27,10 @@ var (
 	redisPassword     string
 	redisCli          *rediscli.Redis
 	messageController *message.Controller
+	smsSender         SMSSender
+	blobStore         BlobStore
+	autoMigrate       bool
+	seedData          bool
 )
 
 // Reused constants
@@ -38,10 +38,71 @@ const (
 	ErrNoRows = "no rows in result set"
 )
 
-// Community struct for the database table Community.
+// Community struct for the database table Community. The visibility is public or invite_only, and communities created
+// by admins have no owner, and are managed by the admins.
 type Community struct {
 	CommunityID int    `json:"community_id"`
-	Name        string `json:"name"`
+	Name        string `json:"name" binding:"required"`
+	Archived    bool   `json:"archived"`
+	Visibility  string `json:"visibility" binding:"omitempty,oneof=public invite_only"`
+	OwnerID     *int   `json:"owner_id" db:"fk_owner_id"`
+}
+
+// CommunityInvite struct for the database table Community_Invite, an invite to join a community with the code until
+// it expires.
+type CommunityInvite struct {
+	Code        string    `json:"code"`
+	CommunityID int       `json:"community_id" db:"fk_community_id"`
+	UserID      int       `json:"user_id" db:"fk_user_id"`
+	CreatedAt   time.Time `json:"created_at"`
+	ExpiresAt   time.Time `json:"expires_at"`
+}
+
+// JoinRequest struct for the database table Community_Join_Request, a request of a user to join an invite-only
+// community. The status is pending, approved or rejected.
+type JoinRequest struct {
+	CommunityID int       `json:"community_id" db:"fk_community_id"`
+	UserID      int       `json:"user_id" db:"fk_user_id"`
+	Status      string    `json:"status"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// Post struct for the database table Post, a post of a member of a community.
+type Post struct {
+	PostID       int       `json:"post_id"`
+	CommunityID  int       `json:"community_id" db:"fk_community_id"`
+	UserID       int       `json:"user_id" db:"fk_user_id"`
+	Title        string    `json:"title" binding:"required"`
+	Content      string    `json:"content"`
+	CreatedAt    time.Time `json:"created_at"`
+	CommentCount int       `json:"comment_count"`
+}
+
+// Comment struct for the database table Comment, a comment on a post. Comments replying to another comment have its
+// ID as ParentID, and are listed as its Replies.
+type Comment struct {
+	CommentID int        `json:"comment_id"`
+	PostID    int        `json:"post_id" db:"fk_post_id"`
+	ParentID  *int       `json:"parent_id" db:"fk_parent_id"`
+	UserID    int        `json:"user_id" db:"fk_user_id"`
+	Content   string     `json:"content" binding:"required"`
+	CreatedAt time.Time  `json:"created_at"`
+	Replies   []*Comment `json:"replies" db:"-"`
+}
+
+// FeedItem is a post or a product listed in a community, as given by Type.
+type FeedItem struct {
+	Type      string    `json:"type"`
+	CreatedAt time.Time `json:"created_at"`
+	Post      *Post     `json:"post,omitempty"`
+	Product   *Product  `json:"product,omitempty"`
+}
+
+// FeedPage is a page of the feed of a community, newest first. NextCursor is given as the cursor query parameter to
+// get the next page, and is nil on the last page.
+type FeedPage struct {
+	Items      []*FeedItem `json:"items"`
+	NextCursor *string     `json:"next_cursor"`
 }
 
 // User struct for the database table User.
@@ -50,14 +111,21 @@ type User struct {
 	Name        string   `json:"name" binding:"required"`
 	PhoneNumber string   `json:"phone_number" db:"phone_number" binding:"required,e164"`
 	Password    string   `json:"password" binding:"required"`
-	Picture     []byte   `json:"picture"`
+	Picture     *Image   `json:"picture" db:"picture_key"`
 	Rating      *float32 `json:"rating"`
 	Business    *bool    `json:"business" binding:"required"`
+	Verified    bool     `json:"verified"`
+	Role        string   `json:"role"`
+	Banned      bool     `json:"banned"`
 }
 
+// UserCommunity struct for the database table User_Community, the membership of a user in a community. The role is
+// member or moderator. InviteCode is the invite used to join an invite-only community.
 type UserCommunity struct {
-	CommunityID int `json:"community_id" binding:"required" db:"fk_community_id"`
-	UserID      int `json:"user_id" db:"fk_user_id"`
+	CommunityID int    `json:"community_id" binding:"required" db:"fk_community_id"`
+	UserID      int    `json:"user_id" db:"fk_user_id"`
+	Role        string `json:"role"`
+	InviteCode  string `json:"invite_code,omitempty" db:"-"`
 }
 
 type Follow struct {
@@ -65,33 +133,150 @@ type Follow struct {
 	Followed        int `json:"followed_id" bindning:"required" db:"fk_user_id"`
 }
 
-// Review struct for the database table Review.
+// Review struct for the database table Review. A review is made by the buyer or the seller of the sold product with
+// ProductID, of the other party. Reviews made before reviews were tied to sales may have no product.
 type Review struct {
-	ReviewID   int    `json:"review_id"`
-	Rating     int    `json:"rating" binding:"required,min=1,max=5"`
-	Content    string `json:"content"`
-	ReviewerID int    `json:"reviewer_id" binding:"required" db:"fk_reviewer_id"`
-	OwnerID    int    `json:"owner_id" db:"fk_owner_id"`
+	ReviewID   int          `json:"review_id"`
+	Rating     int          `json:"rating" binding:"required,min=1,max=5"`
+	Content    string       `json:"content"`
+	ReviewerID int          `json:"reviewer_id" binding:"required" db:"fk_reviewer_id"`
+	OwnerID    int          `json:"owner_id" db:"fk_owner_id"`
+	ProductID  *int         `json:"product_id" binding:"required" db:"fk_product_id"`
+	CreatedAt  time.Time    `json:"created_at"`
+	EditedAt   *time.Time   `json:"edited_at"`
+	Edited     bool         `json:"edited"`
+	Reply      *ReviewReply `json:"reply" db:"-"`
+}
+
+// ReviewReply struct for the database table Review_Reply, the public reply of the reviewed user to a review.
+type ReviewReply struct {
+	ReviewID  int        `json:"review_id" db:"fk_review_id"`
+	Content   string     `json:"content" binding:"required"`
+	CreatedAt time.Time  `json:"created_at"`
+	EditedAt  *time.Time `json:"edited_at"`
+	Edited    bool       `json:"edited"`
+}
+
+// ReviewSummary is the average rating of a user, which is null if the user has no reviews, along with the number of
+// reviews and the number of reviews with each rating from one to five stars.
+type ReviewSummary struct {
+	Average   *float64    `json:"average"`
+	Count     int         `json:"count"`
+	Histogram map[int]int `json:"histogram"`
 }
 
 // Product struct for the database table Product.
 type Product struct {
-	ProductID   int         `json:"product_id"`
-	Name        string      `json:"name" binding:"required"`
-	Service     *bool       `json:"service" binding:"required"`
-	Price       int         `json:"price" binding:"required"`
-	UploadDate  pgtype.Date `json:"upload_date"`
-	Description string      `json:"description"`
-	Picture     []byte      `json:"picture"`
-	Category    *string     `json:"category"`
-	UserID      int         `json:"user_id" db:"fk_user_id"`
-	BuyerID     *int        `json:"buyer_id" db:"fk_buyer_id"`
+	ProductID   int             `json:"product_id"`
+	Name        string          `json:"name" binding:"required"`
+	Service     *bool           `json:"service" binding:"required"`
+	Price       int             `json:"price" binding:"required"`
+	UploadDate  pgtype.Date     `json:"upload_date"`
+	Description string          `json:"description"`
+	Picture     *Image          `json:"picture" db:"picture_key"`
+	CategoryID  *int            `json:"category_id" db:"fk_category_id"`
+	UserID      int             `json:"user_id" db:"fk_user_id"`
+	BuyerID     *int            `json:"buyer_id" db:"fk_buyer_id"`
+	State       string          `json:"state"`
+	Images      []*ProductImage `json:"images" db:"-"`
+}
+
+// ProductImage struct for the database table Product_Image. The images of a product are ordered by position,
+// starting at 0, and the first is the primary image, which is also the picture of the product.
+type ProductImage struct {
+	ProductImageID int   `json:"product_image_id"`
+	ProductID      int   `json:"product_id" db:"fk_product_id"`
+	Image          Image `json:"image" db:"image_key"`
+	Position       int   `json:"position"`
+}
+
+// PurchaseRequest struct for the database table Buying_Product, a request of a user to buy a product. The status is
+// pending, accepted, rejected or cancelled. Price is the price agreed on when the request was accepted.
+type PurchaseRequest struct {
+	ProductID int       `json:"product_id" db:"fk_product_id"`
+	UserID    int       `json:"user_id" db:"fk_user_id"`
+	Status    string    `json:"status"`
+	CreatedAt time.Time `json:"created_at"`
+	Price     *int      `json:"price"`
+}
+
+// Offer struct for the database table Offer, an offered price in the negotiation between the seller of a product and
+// a buyer. The proposer is the buyer or the seller, and the status is pending, accepted, rejected or countered.
+type Offer struct {
+	OfferID     int        `json:"offer_id"`
+	ProductID   int        `json:"product_id" db:"fk_product_id"`
+	BuyerID     int        `json:"buyer_id" db:"fk_buyer_id"`
+	ProposerID  int        `json:"proposer_id" db:"fk_proposer_id"`
+	Price       int        `json:"price"`
+	Status      string     `json:"status"`
+	CreatedAt   time.Time  `json:"created_at"`
+	RespondedAt *time.Time `json:"responded_at"`
+}
+
+// ProductStateChange struct for the database table Product_State_Change, a recorded change of the state of a product.
+type ProductStateChange struct {
+	ProductStateChangeID int       `json:"product_state_change_id"`
+	ProductID            int       `json:"product_id" db:"fk_product_id"`
+	FromState            string    `json:"from_state"`
+	ToState              string    `json:"to_state"`
+	UserID               *int      `json:"user_id" db:"fk_user_id"`
+	ChangedAt            time.Time `json:"changed_at"`
+}
+
+// Category struct for the database table Category. Children is only set when returning the category tree.
+type Category struct {
+	CategoryID int         `json:"category_id"`
+	Name       string      `json:"name" binding:"required"`
+	ParentID   *int        `json:"parent_id" db:"fk_parent_id"`
+	Children   []*Category `json:"children,omitempty" db:"-"`
+}
+
+// Flag struct for the database table Flag, a report of a product or review.
+type Flag struct {
+	FlagID     int       `json:"flag_id"`
+	Reason     *string   `json:"reason"`
+	CreatedAt  time.Time `json:"created_at"`
+	ReporterID int       `json:"reporter_id" db:"fk_reporter_id"`
+	ProductID  *int      `json:"product_id" db:"fk_product_id"`
+	ReviewID   *int      `json:"review_id" db:"fk_review_id"`
 }
 
 type Chat struct {
 	UserID int `json:"user_id" binding:"required"`
 }
 
+// GroupChat struct for the database table Group_Chat, a chat between its members, whom the owner adds and removes.
+// Channel is the websocket channel of the chat.
+type GroupChat struct {
+	GroupChatID int                `json:"group_chat_id"`
+	Title       string             `json:"title" binding:"required"`
+	OwnerID     *int               `json:"owner_id" db:"fk_owner_id"`
+	CreatedAt   time.Time          `json:"created_at"`
+	Channel     string             `json:"channel" db:"-"`
+	Members     []*GroupChatMember `json:"members" db:"-"`
+}
+
+// ChatMessage struct for the database table Message, a message sent in the websocket chat. It is sent in the chat of
+// two users with ChatID, the group chat with GroupChatID or the community with CommunityID, or in the public chat if
+// none is set. The sender is nil if the user has been deleted.
+type ChatMessage struct {
+	MessageID   string    `json:"message_id"`
+	ChatID      *string   `json:"chat_id" db:"fk_chat_id"`
+	GroupChatID *int      `json:"group_chat_id" db:"fk_group_chat_id"`
+	CommunityID *int      `json:"community_id" db:"fk_community_id"`
+	SenderID    *int      `json:"sender_id" db:"fk_sender_id"`
+	Recipient   string    `json:"recipient"`
+	Content     string    `json:"content"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// GroupChatMember struct for the database table Group_Chat_Member, the membership of a user in a group chat.
+type GroupChatMember struct {
+	GroupChatID int       `json:"group_chat_id" db:"fk_group_chat_id"`
+	UserID      int       `json:"user_id" binding:"required" db:"fk_user_id"`
+	AddedAt     time.Time `json:"added_at"`
+}
+
 // setupConfig reads in .env file and ENV variables if set, otherwise use default values.
 func setupConfig() {
 	// Load environment variables from .env file
@@ -112,6 +297,14 @@ func setupConfig() {
 	tlsCertFile = os.Getenv("TLS_CERT_FILE")
 	redisURL = os.Getenv("REDIS_URL")
 	redisPassword = os.Getenv("REDIS_PASSWORD")
+	jwtSecrets := os.Getenv("JWT_SECRETS")
+	jwtKeyFiles := os.Getenv("JWT_KEY_FILES")
+	jwtSigningKeyID := os.Getenv("JWT_SIGNING_KEY_ID")
+	smsLogFile := os.Getenv("SMS_LOG_FILE")
+	blobDir := os.Getenv("BLOB_DIR")
+	blobBaseURL := os.Getenv("BLOB_BASE_URL")
+	autoMigrate = os.Getenv("AUTO_MIGRATE") != "false"
+	seedData = os.Getenv("SEED_DATA") == "true"
 
 	// Change empty config values to default values
 	if serverHost == "" {
@@ -146,11 +339,29 @@ func setupConfig() {
 		redisURL = "localhost:6379"
 	}
 
+	err = loadJWTKeys(jwtSecrets, jwtKeyFiles, jwtSigningKeyID)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Unable to load JWT signing keys: %v\n", err)
+		os.Exit(1)
+	}
+
+	smsSender, err = setupSMSSender(smsLogFile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Unable to set up SMS sender: %v\n", err)
+		os.Exit(1)
+	}
+
+	blobStore, err = setupBlobStore(blobDir, blobBaseURL)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Unable to set up blob store: %v\n", err)
+		os.Exit(1)
+	}
+
 	serverURL = serverHost + ":" + serverPort
 	databaseURL = "postgres://" + databaseUser + ":" + databasePassword + "@" + databaseHost + ":" + databasePort + "/" + databaseName
 
 	redisCli = rediscli.NewRedis(redisURL, redisPassword)
-	messageController = message.NewController(redisCli)
+	messageController = message.NewController(redisCli, authenticateToken, tokenRevoked, authorizeChannel, messageStore{})
 }
 
 // setupDBPool creates a connection pool to the database.
@@ -164,8 +375,18 @@ func setupDBPool() *pgxpool.Pool {
 	return dbpool
 }
 
-// setupRouter creates a router with all the routes.
+// setupRouter creates a router with all the routes. The tests make more requests than allowed, so requests are not
+// rate limited in test mode.
 func setupRouter() *gin.Engine {
+	if gin.Mode() == gin.TestMode {
+		return newRouter(noRateLimit)
+	}
+
+	return newRouter(rateLimit)
+}
+
+// newRouter creates a router with all the routes, rate limited with the given middleware.
+func newRouter(limit func(name string, burst int64, interval time.Duration) gin.HandlerFunc) *gin.Engine {
 	router := gin.New()
 	// Log to stdout.
 	gin.DefaultWriter = os.Stdout
@@ -182,7 +403,13 @@ func setupRouter() *gin.Engine {
 	}
 
 	router.GET("/ping", ping)
-	users := router.Group("/users")
+
+	// Rate limits shared by the route groups, per client
+	readLimit := limit("read", 300, time.Minute)
+	writeLimit := limit("write", 60, time.Minute)
+	authLimit := limit("auth", 20, time.Minute)
+
+	users := router.Group("/users", readLimit)
 	{
 		users.GET("", getUsers)
 		users.GET("/:user_id", getUser)
@@ -191,61 +418,130 @@ func setupRouter() *gin.Engine {
 		users.GET("/:user_id/following", getUserIsFollowing)
 		users.GET("/:user_id/products", getUserProducts)
 		users.GET("/:user_id/reviews", getUserReviews)
+		users.GET("/:user_id/reviews/summary", getReviewSummary)
 		users.GET("/:user_id/pinned", getPinnedProducts)
 		users.GET("/:user_id/following/products", getFollowingUsersProducts)
-		users.GET("/:user_id/chats", getUserChats)
-		users.POST("", createUser)
-		users.POST("/:user_id/products", createProduct)
-		users.POST("/:user_id/reviews", createReview)
-		users.POST("/:user_id/communities", joinCommunity)
-		users.POST("/:user_id/pinned", addPinnedProduct)
-		users.POST("/:user_id/followers", createFollow)
-		users.POST("/:user_id/chats", createChat)
-		users.DELETE("/:user_id", deleteUser)
-		users.DELETE("/:user_id/pinned/:product_id", deletePinnedProduct)
-		users.DELETE("/:user_id/chats/:chat_id", deleteChat)
-		users.DELETE("/:user_id/products/:product_id", deleteProduct)
-		users.PUT("/:user_id", updateUser)
+		users.POST("", authLimit, createUser)
+		users.POST("/:user_id/reviews", writeLimit, authRequired(), createReview)
+		users.PUT("/:user_id/reviews/:review_id", writeLimit, authRequired(), updateReview)
+		users.DELETE("/:user_id/reviews/:review_id", writeLimit, authRequired(), deleteReview)
+		users.POST("/:user_id/reviews/:review_id/flags", writeLimit, authRequired(), flagReview)
+	}
+
+	// Routes modifying a user's resources, or reading its chats, are only allowed for that user
+	ownUser := router.Group("/users/:user_id", writeLimit, authRequired(), userOwnerRequired())
+	{
+		ownUser.POST("/products", createProduct)
+		ownUser.POST("/communities", joinCommunity)
+		ownUser.POST("/pinned", addPinnedProduct)
+		ownUser.POST("/followers", createFollow)
+		ownUser.GET("/chats", getUserChats)
+		ownUser.POST("/chats", createChat)
+		ownUser.DELETE("", deleteUser)
+		ownUser.DELETE("/pinned/:product_id", deletePinnedProduct)
+		ownUser.DELETE("/chats/:chat_id", deleteChat)
+		ownUser.GET("/chats/groups", getGroupChats)
+		ownUser.POST("/chats/groups", createGroupChat)
+		ownUser.GET("/chats/groups/:group_id", getGroupChat)
+		ownUser.PUT("/chats/groups/:group_id", updateGroupChat)
+		ownUser.DELETE("/chats/groups/:group_id", deleteGroupChat)
+		ownUser.POST("/chats/groups/:group_id/members", addGroupChatMember)
+		ownUser.DELETE("/chats/groups/:group_id/members/:member_id", removeGroupChatMember)
+		ownUser.DELETE("/communities/:community_id", leaveCommunity)
+		ownUser.DELETE("/products/:product_id", productOwnerRequired(), deleteProduct)
+		ownUser.POST("/verification", requestPhoneVerification)
+		ownUser.POST("/verification/confirm", confirmPhoneVerification)
+		ownUser.PUT("", updateUser)
+		ownUser.PUT("/picture", uploadUserPicture)
+		ownUser.DELETE("/picture", deleteUserPicture)
+		ownUser.POST("/products/:product_id/images", productOwnerRequired(), addProductImage)
+		ownUser.PUT("/products/:product_id/images", productOwnerRequired(), reorderProductImages)
+		ownUser.DELETE("/products/:product_id/images/:image_id", productOwnerRequired(), deleteProductImage)
+		ownUser.PUT("/products/:product_id/state", productOwnerRequired(), setProductState)
+		ownUser.GET("/products/:product_id/state/changes", productOwnerRequired(), getProductStateChanges)
+		ownUser.GET("/products/:product_id/requests", productOwnerRequired(), getPurchaseRequests)
+		ownUser.POST("/products/:product_id/requests/:buyer_id/accept", productOwnerRequired(), acceptPurchaseRequest)
+		ownUser.PUT("/products/:product_id/communities", productOwnerRequired(), setProductCommunities)
+		ownUser.PUT("/reviews/:review_id/reply", setReviewReply)
+		ownUser.DELETE("/reviews/:review_id/reply", deleteReviewReply)
 	}
 
-	communities := router.Group("/communities")
+	communities := router.Group("/communities", readLimit)
 	{
 		communities.GET("", getCommunities)
+		communities.GET("/:community_id/feed", authOptional(), getCommunityFeed)
+		communities.GET("/:community_id/members", authOptional(), getCommunityMembers)
+		communities.GET("/:community_id/posts/:post_id", authOptional(), getPost)
+		communities.GET("/:community_id/posts/:post_id/comments", authOptional(), getComments)
+		communities.GET("/:community_id/requests", authRequired(), getJoinRequests)
+		communities.POST("", writeLimit, authRequired(), createUserCommunity)
+		communities.PUT("/:community_id/moderators/:user_id", writeLimit, authRequired(), addModerator)
+		communities.DELETE("/:community_id/moderators/:user_id", writeLimit, authRequired(), removeModerator)
+		communities.POST("/:community_id/invites", writeLimit, authRequired(), createInvite)
+		communities.POST("/:community_id/requests", writeLimit, authRequired(), requestToJoin)
+		communities.POST("/:community_id/requests/:user_id/approve", writeLimit, authRequired(), approveJoinRequest)
+		communities.POST("/:community_id/requests/:user_id/reject", writeLimit, authRequired(), rejectJoinRequest)
+		communities.POST("/:community_id/posts", writeLimit, authRequired(), createPost)
+		communities.DELETE("/:community_id/posts/:post_id", writeLimit, authRequired(), deletePost)
+		communities.POST("/:community_id/posts/:post_id/comments", writeLimit, authRequired(), createComment)
+	}
+
+	categories := router.Group("/categories", readLimit)
+	{
+		categories.GET("", getCategories)
 	}
 
-	products := router.Group("/products")
+	products := router.Group("/products", readLimit)
 	{
 		products.GET("", getProducts)
+		products.GET("/search", searchProducts)
 		products.GET("/:product_id", getProduct)
-		products.PUT("/:product_id", updateProduct)
+		products.GET("/:product_id/communities", authOptional(), getProductCommunities)
+		products.PUT("/:product_id", writeLimit, authRequired(), productOwnerRequired(), updateProduct)
+		products.POST("/:product_id/flags", writeLimit, authRequired(), flagProduct)
+		products.POST("/:product_id/requests", writeLimit, authRequired(), requestPurchase)
+		products.DELETE("/:product_id/requests", writeLimit, authRequired(), cancelPurchaseRequest)
+		products.GET("/:product_id/offers", authRequired(), getOffers)
+		products.POST("/:product_id/offers", writeLimit, authRequired(), makeOffer)
+		products.POST("/:product_id/offers/:offer_id/accept", writeLimit, authRequired(), acceptOffer)
+		products.POST("/:product_id/offers/:offer_id/reject", writeLimit, authRequired(), rejectOffer)
+		products.POST("/:product_id/offers/:offer_id/counter", writeLimit, authRequired(), counterOffer)
 	}
-	router.POST("/login", login)
-	router.GET("/ws", func(c *gin.Context) {
-		websocket.Handler(c.Writer, c.Request, redisCli, messageController)
-	})
-
-	return router
-}
-
-// initRedisUsers adds all users from the PostgreSQL database to the Redis database.
-func initRedisUsers() {
-	var userlist []User
 
-	query := "SELECT user_id, name, phone_number, password, rating, business FROM Users"
-	err := pgxscan.Select(context.Background(), dbPool, &userlist, query)
+	admin := router.Group("/admin", writeLimit, authRequired(), adminRequired())
+	{
+		admin.POST("/users/:user_id/ban", banUser)
+		admin.DELETE("/users/:user_id/ban", unbanUser)
+		admin.DELETE("/products/:product_id", adminDeleteProduct)
+		admin.DELETE("/reviews/:review_id", adminDeleteReview)
+		admin.POST("/categories", createCategory)
+		admin.POST("/communities", createCommunity)
+		admin.PUT("/communities/:community_id", renameCommunity)
+		admin.POST("/communities/:community_id/archive", archiveCommunity)
+		admin.DELETE("/communities/:community_id/archive", unarchiveCommunity)
+		admin.GET("/flags", getFlags)
+		admin.DELETE("/flags/:flag_id", deleteFlag)
+	}
 
-	if err != nil {
-		fmt.Println(err)
-		return
+	auth := router.Group("", authLimit)
+	{
+		auth.POST("/login", login)
+		auth.POST("/logout", authRequired(), logout)
+		auth.POST("/token/refresh", refreshToken)
+		auth.POST("/password/forgot", forgotPassword)
+		auth.POST("/password/reset", resetPassword)
 	}
 
-	// Add all users from the database to the redis database
-	for _, user := range userlist {
-		_, err = redisCli.UserCreate(strconv.Itoa(user.UserID), user.Name)
-		if err != nil {
-			fmt.Println(err)
-		}
+	// The local blob store is served by the server itself
+	if store, ok := blobStore.(*localBlobStore); ok {
+		router.Static(blobRoutePath, store.dir)
 	}
+
+	router.GET("/ws", func(c *gin.Context) {
+		websocket.Handler(c.Writer, c.Request, redisCli, messageController)
+	})
+
+	return router
 }
 
 // main is the entry point for the application.
@@ -253,30 +549,19 @@ func main() {
 	setupConfig()
 
 	dbPool = setupDBPool()
-	defer dbPool.Close()
-
-	router := setupRouter()
-
-	initRedisUsers()
 
-	var err error
+	err := runCommand(context.Background(), os.Args[1:])
 
-	switch {
-	case autoTLSDomain != "":
-		fmt.Println("Auto TLS enabled")
+	dbPool.Close()
 
-		err = autotls.Run(router, autoTLSDomain)
-	case tlsCertFile != "" && tlsKeyFile != "":
-		fmt.Println("TLS enabled")
-
-		err = router.RunTLS(serverURL, tlsCertFile, tlsKeyFile)
-	default:
-		fmt.Println("No TLS enabled")
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
 
-		err = router.Run(serverURL)
-	}
+		if errors.Is(err, errUsage) {
+			fmt.Fprint(os.Stderr, "\n"+usage)
+			os.Exit(2)
+		}
 
-	if err != nil {
-		fmt.Println(err)
+		os.Exit(1)
 	}
 }
@@ -1,18 +1,38 @@
 package main
 
 import (
+	"bytes"
 	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
 	"encoding/json"
+	"encoding/pem"
 	"fmt"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"mime/multipart"
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"path/filepath"
+	"regexp"
 	"strconv"
 	"strings"
 	"testing"
+	"time"
 
+	"github.com/VictorAnnell/kandidat-backend/message"
+	"github.com/VictorAnnell/kandidat-backend/rediscli"
+	"github.com/georgysavva/scany/pgxscan"
 	"github.com/gin-gonic/gin"
 	"github.com/go-playground/validator/v10"
+	"github.com/gobwas/ws"
+	"github.com/gobwas/ws/wsutil"
+	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -42,10 +62,33 @@ func RunTests(m *testing.M) int {
 	}
 
 	dbPool = setupDBPool()
-	router = setupRouter()
 
 	defer dbPool.Close()
 
+	// Uploaded images are stored in a temporary directory
+	blobDir, err := os.MkdirTemp("", "kandidat-blobs")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Unable to create blob directory: %v\n", err)
+		return 1
+	}
+
+	defer os.RemoveAll(blobDir)
+
+	blobStore, err = newLocalBlobStore(blobDir, blobRoutePath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Unable to set up blob store: %v\n", err)
+		return 1
+	}
+
+	// The tests rely on the seed data
+	seedData = true
+	if err := migrateDatabase(context.Background()); err != nil {
+		fmt.Fprintf(os.Stderr, "Unable to migrate database: %v\n", err)
+		return 1
+	}
+
+	router = setupRouter()
+
 	validate = validator.New()
 
 	return m.Run()
@@ -88,15 +131,15 @@ func TestGetProducts(t *testing.T) {
 	req, _ := http.NewRequest(get, "/products", nil)
 	router.ServeHTTP(w, req)
 
-	var productarray []Product
+	var page ProductPage
 
-	err := json.Unmarshal(w.Body.Bytes(), &productarray)
+	err := json.Unmarshal(w.Body.Bytes(), &page)
 	if err != nil {
 		t.Errorf("Error unmarshalling json: %v", err)
 	}
 
 	// Validate all Community structs in the array communityarray
-	for _, product := range productarray {
+	for _, product := range page.Products {
 		err = validate.Struct(product)
 		if err != nil {
 			t.Errorf("Error validating struct: %v", err)
@@ -289,15 +332,15 @@ func TestGetUserProducts(t *testing.T) {
 	req, _ := http.NewRequest(get, "/users/1/products", nil)
 	router.ServeHTTP(w, req)
 
-	var productarray []Product
+	var page ProductPage
 
-	err := json.Unmarshal(w.Body.Bytes(), &productarray)
+	err := json.Unmarshal(w.Body.Bytes(), &page)
 	if err != nil {
 		t.Errorf("Error unmarshalling json: %v", err)
 	}
 
-	// Validate all Product structs in the array productarray
-	for _, product := range productarray {
+	// Validate all Product structs in the page
+	for _, product := range page.Products {
 		err = validate.Struct(product)
 		if err != nil {
 			t.Errorf("Error validating struct: %v", err)
@@ -317,10 +360,10 @@ func TestGetUserProducts(t *testing.T) {
 func TestCreateProduct(t *testing.T) {
 	// Test with valid JSON body and valid user ID
 	endpoint := "/users/1/products"
-	reqBody := `{"name": "Test Product", "category": "Test Category", "service": true, "price": 2, "description": "Test Description"}`
+	reqBody := `{"name": "Test Product", "category_id": 3, "service": true, "price": 2, "description": "Test Description"}`
 	expectedHTTPStatusCode := http.StatusCreated
 	expectedResponseStruct := Product{}
-	bodyBytes := reqTester(t, post, endpoint, reqBody, expectedHTTPStatusCode)
+	bodyBytes := authReqTester(t, 1, post, endpoint, reqBody, expectedHTTPStatusCode)
 
 	// Test decoding of JSON response body
 	err := json.Unmarshal(bodyBytes, &expectedResponseStruct)
@@ -340,27 +383,30 @@ func TestCreateProduct(t *testing.T) {
 	expectedHTTPStatusCode = http.StatusBadRequest
 	expectedResponseStruct = Product{}
 
-	reqTester(t, post, endpoint, reqBody, expectedHTTPStatusCode)
+	authReqTester(t, 1, post, endpoint, reqBody, expectedHTTPStatusCode)
 
-	// Test with valid JSON body and invalid user ID
+	// Test with valid JSON body and another user's ID
 	endpoint = "/users/99999/products"
-	reqBody = `{"name": "Test Product", "category": "Test Category", "service": true, "price": 5, "description": "Test Description"}`
-	expectedHTTPStatusCode = http.StatusNotFound
+	reqBody = `{"name": "Test Product", "category_id": 3, "service": true, "price": 5, "description": "Test Description"}`
+	expectedHTTPStatusCode = http.StatusForbidden
 	expectedResponseStruct = Product{}
 
-	reqTester(t, post, endpoint, reqBody, expectedHTTPStatusCode)
+	authReqTester(t, 1, post, endpoint, reqBody, expectedHTTPStatusCode)
 
-	// Test with invalid JSON body and invalid user ID
-	endpoint = "/users/99999/products"
-	reqBody = `{"invalid-field-name": "Test Product", "wrong-field-name2": false, "price": "abc", "description": "Test Description"}`
-	expectedHTTPStatusCode = http.StatusNotFound
-	expectedResponseStruct = Product{}
+	// Test with valid JSON body and no token
+	endpoint = "/users/1/products"
+	expectedHTTPStatusCode = http.StatusUnauthorized
 
 	reqTester(t, post, endpoint, reqBody, expectedHTTPStatusCode)
+
+	// Test with valid JSON body and invalid token
+	expectedHTTPStatusCode = http.StatusUnauthorized
+
+	tokenReqTester(t, "not a token", post, endpoint, reqBody, expectedHTTPStatusCode)
 }
 func TestCreateReview(t *testing.T) {
 	c := context.Background()
-	// delete review to test
+	// delete review to test, of the seed sale of product 4 by user 2 to user 1
 	query := "DELETE FROM Review where fk_owner_id = $1 and fk_reviewer_id = $2"
 	_, err := dbPool.Exec(c, query, 1, 2)
 
@@ -369,10 +415,10 @@ func TestCreateReview(t *testing.T) {
 	}
 	// Test with valid JSON body and valid user ID
 	endpoint := "/users/1/reviews"
-	reqBody := `{"rating": 5, "description": "Test Description", "reviewer_id": 2}`
+	reqBody := `{"rating": 5, "description": "Test Description", "reviewer_id": 2, "product_id": 4}`
 	expectedHTTPStatusCode := http.StatusCreated
 	expectedResponseStruct := Review{}
-	bodyBytes := reqTester(t, post, endpoint, reqBody, expectedHTTPStatusCode)
+	bodyBytes := authReqTester(t, 2, post, endpoint, reqBody, expectedHTTPStatusCode)
 
 	// Test decoding of JSON response body
 	err = json.Unmarshal(bodyBytes, &expectedResponseStruct)
@@ -386,22 +432,33 @@ func TestCreateReview(t *testing.T) {
 		t.Errorf("Error validating struct: %v", err)
 	}
 
+	if assert.NotNil(t, expectedResponseStruct.ProductID) {
+		assert.Equal(t, 4, *expectedResponseStruct.ProductID)
+	}
+
+	// Test reviewing the same sale again
+	authReqTester(t, 2, post, endpoint, reqBody, http.StatusBadRequest)
+
 	// Test with invalid JSON body and valid user ID
 	endpoint = "/users/1/reviews"
-	reqBody = `{"rating": "this should be a number", "description": "Test Description", "reviewer_id": 2}`
+	reqBody = `{"rating": "this should be a number", "description": "Test Description", "reviewer_id": 2, "product_id": 4}`
 	expectedHTTPStatusCode = http.StatusBadRequest
 	expectedResponseStruct = Review{}
 
-	reqTester(t, post, endpoint, reqBody, expectedHTTPStatusCode)
+	authReqTester(t, 2, post, endpoint, reqBody, expectedHTTPStatusCode)
+
+	// Test without a product
+	reqBody = `{"rating": 5, "description": "Test Description", "reviewer_id": 2}`
+	authReqTester(t, 2, post, endpoint, reqBody, http.StatusBadRequest)
 
 	// Test with valid JSON body and invalid user ID
 
 	endpoint = "/users/99999/reviews"
-	reqBody = `{"rating": 1, "description": "Test Description", "reviewer_id": 2}`
+	reqBody = `{"rating": 1, "description": "Test Description", "reviewer_id": 2, "product_id": 4}`
 	expectedHTTPStatusCode = http.StatusNotFound
 	expectedResponseStruct = Review{}
 
-	reqTester(t, post, endpoint, reqBody, expectedHTTPStatusCode)
+	authReqTester(t, 2, post, endpoint, reqBody, expectedHTTPStatusCode)
 
 	// Test with invalid JSON body and invalid user ID
 	endpoint = "/users/99999/reviews"
@@ -409,7 +466,44 @@ func TestCreateReview(t *testing.T) {
 	expectedHTTPStatusCode = http.StatusNotFound
 	expectedResponseStruct = Review{}
 
-	reqTester(t, post, endpoint, reqBody, expectedHTTPStatusCode)
+	authReqTester(t, 2, post, endpoint, reqBody, expectedHTTPStatusCode)
+
+	// Test with a reviewer ID that is not the authenticated user
+	endpoint = "/users/1/reviews"
+	reqBody = `{"rating": 1, "description": "Test Description", "reviewer_id": 2, "product_id": 4}`
+	expectedHTTPStatusCode = http.StatusForbidden
+
+	authReqTester(t, 1, post, endpoint, reqBody, expectedHTTPStatusCode)
+
+	// Test with a product that does not exist and one that is not sold
+	reqBody = `{"rating": 1, "description": "Test Description", "reviewer_id": 2, "product_id": 99999}`
+	authReqTester(t, 2, post, endpoint, reqBody, http.StatusNotFound)
+
+	reqBody = `{"rating": 1, "description": "Test Description", "reviewer_id": 2, "product_id": 1}`
+	authReqTester(t, 2, post, endpoint, reqBody, http.StatusConflict)
+
+	// Test reviewing oneself, and reviewing as a user who did not take part in the sale
+	reqBody = `{"rating": 1, "description": "Test Description", "reviewer_id": 2, "product_id": 4}`
+	authReqTester(t, 2, post, "/users/2/reviews", reqBody, http.StatusForbidden)
+
+	user := createTestUser(t)
+	reqBody = `{"rating": 1, "description": "Test Description", "reviewer_id": ` + strconv.Itoa(user.UserID) + `, "product_id": 4}`
+	authReqTester(t, user.UserID, post, "/users/2/reviews", reqBody, http.StatusForbidden)
+
+	// Test that the buyer and the seller of a product sold through the API review each other
+	seller := createTestUser(t)
+	product := createTestSale(t, seller.UserID, user.UserID)
+
+	if assert.NotNil(t, product.BuyerID) {
+		assert.Equal(t, user.UserID, *product.BuyerID)
+	}
+
+	productID := strconv.Itoa(product.ProductID)
+	reqBody = `{"rating": 4, "reviewer_id": ` + strconv.Itoa(user.UserID) + `, "product_id": ` + productID + `}`
+	authReqTester(t, user.UserID, post, "/users/"+strconv.Itoa(seller.UserID)+"/reviews", reqBody, http.StatusCreated)
+
+	reqBody = `{"rating": 5, "reviewer_id": ` + strconv.Itoa(seller.UserID) + `, "product_id": ` + productID + `}`
+	authReqTester(t, seller.UserID, post, "/users/"+strconv.Itoa(user.UserID)+"/reviews", reqBody, http.StatusCreated)
 }
 
 func TestCreateUser(t *testing.T) {
@@ -433,8 +527,10 @@ func TestCreateUser(t *testing.T) {
 	}
 
 	// Delete the created test user
+	token, _ := createAccessToken(expectedResponseStruct.UserID)
 	w := httptest.NewRecorder()
 	req, _ := http.NewRequest(del, "/users/"+strconv.Itoa(expectedResponseStruct.UserID), nil)
+	req.Header.Set("Authorization", "Bearer "+token)
 	router.ServeHTTP(w, req)
 
 	if w.Code != http.StatusNoContent {
@@ -450,15 +546,21 @@ func TestCreateUser(t *testing.T) {
 }
 
 func TestJoinCommunity(t *testing.T) {
+	// leave community to test
+	_, err := dbPool.Exec(context.Background(), "DELETE FROM User_Community WHERE fk_user_id = $1 AND fk_community_id = $2", 1, 2)
+	if err != nil {
+		fmt.Println(err)
+	}
+
 	// Test with valid JSON body and valid user ID
 	endpoint := "/users/1/communities"
 	reqBody := `{"community_id": 2}`
 	expectedHTTPStatusCode := http.StatusCreated
 	expectedResponseStruct := Community{}
-	bodyBytes := reqTester(t, post, endpoint, reqBody, expectedHTTPStatusCode)
+	bodyBytes := authReqTester(t, 1, post, endpoint, reqBody, expectedHTTPStatusCode)
 
 	// Test decoding of JSON response body
-	err := json.Unmarshal(bodyBytes, &expectedResponseStruct)
+	err = json.Unmarshal(bodyBytes, &expectedResponseStruct)
 	if err != nil {
 		t.Errorf("Error unmarshalling json: %v", err)
 	}
@@ -469,21 +571,24 @@ func TestJoinCommunity(t *testing.T) {
 		t.Errorf("Error validating struct: %v", err)
 	}
 
+	// Test joining again
+	authReqTester(t, 1, post, endpoint, reqBody, http.StatusConflict)
+
 	// Test with invalid JSON body and valid user ID
 	endpoint = "/users/1/communities"
 	reqBody = `{"invalid-field-name": 1}`
 	expectedHTTPStatusCode = http.StatusBadRequest
 	expectedResponseStruct = Community{}
 
-	reqTester(t, post, endpoint, reqBody, expectedHTTPStatusCode)
+	authReqTester(t, 1, post, endpoint, reqBody, expectedHTTPStatusCode)
 
-	// Test with valid JSON body and invalid user ID
+	// Test with valid JSON body and another user's ID
 	endpoint = "/users/99999/communities"
 	reqBody = `{"community_id": 3}`
-	expectedHTTPStatusCode = http.StatusNotFound
+	expectedHTTPStatusCode = http.StatusForbidden
 	expectedResponseStruct = Community{}
 
-	reqTester(t, post, endpoint, reqBody, expectedHTTPStatusCode)
+	authReqTester(t, 1, post, endpoint, reqBody, expectedHTTPStatusCode)
 }
 
 func TestDeleteUser(t *testing.T) { //nolint:dupl // Testing different endpoints
@@ -510,24 +615,30 @@ func TestDeleteUser(t *testing.T) { //nolint:dupl // Testing different endpoints
 		return
 	}
 
-	// Test with valid user ID
+	// Test with another user's token
 	endpoint := "/users/" + strconv.Itoa(testUser.UserID)
-	expectedHTTPStatusCode := http.StatusNoContent
-	reqTester(t, del, endpoint, "", expectedHTTPStatusCode)
+	expectedHTTPStatusCode := http.StatusForbidden
+	authReqTester(t, 1, del, endpoint, "", expectedHTTPStatusCode)
 
-	// Test with invalid user ID
+	// Test with valid user ID
+	expectedHTTPStatusCode = http.StatusNoContent
+	authReqTester(t, testUser.UserID, del, endpoint, "", expectedHTTPStatusCode)
+
+	// Test with another user's ID
 	endpoint = "/users/99999"
-	expectedHTTPStatusCode = http.StatusNotFound
+	expectedHTTPStatusCode = http.StatusForbidden
 
-	reqTester(t, del, endpoint, "", expectedHTTPStatusCode)
+	authReqTester(t, testUser.UserID, del, endpoint, "", expectedHTTPStatusCode)
 }
 
 func TestDeleteProduct(t *testing.T) { //nolint:dupl // Testing different endpoints
 	// Create product to delete
 	reqBody := `{"name": "Test Product", "service": false, "price": 100}`
+	token, _ := createAccessToken(2)
 	w := httptest.NewRecorder()
 	req, _ := http.NewRequest(post, "/users/2/products", strings.NewReader(reqBody))
 	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+token)
 	router.ServeHTTP(w, req)
 
 	if w.Code != http.StatusCreated {
@@ -546,33 +657,38 @@ func TestDeleteProduct(t *testing.T) { //nolint:dupl // Testing different endpoi
 		return
 	}
 
-	// Test with valid product ID
+	// Test with a product owned by another user
 	endpoint := "/users/1/products/" + strconv.Itoa(testProduct.ProductID)
-	expectedHTTPStatusCode := http.StatusNoContent
-	reqTester(t, del, endpoint, "", expectedHTTPStatusCode)
+	expectedHTTPStatusCode := http.StatusForbidden
+	authReqTester(t, 1, del, endpoint, "", expectedHTTPStatusCode)
+
+	// Test with valid product ID
+	endpoint = "/users/2/products/" + strconv.Itoa(testProduct.ProductID)
+	expectedHTTPStatusCode = http.StatusNoContent
+	authReqTester(t, 2, del, endpoint, "", expectedHTTPStatusCode)
 
 	// Test with invalid product ID
-	endpoint = "/users/1/products/99999"
+	endpoint = "/users/2/products/99999"
 	expectedHTTPStatusCode = http.StatusNotFound
 
-	reqTester(t, del, endpoint, "", expectedHTTPStatusCode)
+	authReqTester(t, 2, del, endpoint, "", expectedHTTPStatusCode)
 }
 
 func TestGetPinnedProducts(t *testing.T) {
 	// Test with valid user ID
 	endpoint := "/users/1/pinned" //nolint:goconst // No const is better for readability
 	expectedHTTPStatusCode := http.StatusOK
-	expectedResponseStructSlice := []Product{}
+	expectedResponseStruct := ProductPage{}
 	bodyBytes := reqTester(t, get, endpoint, "", expectedHTTPStatusCode)
 
 	// Test decoding of JSON response body
-	err := json.Unmarshal(bodyBytes, &expectedResponseStructSlice)
+	err := json.Unmarshal(bodyBytes, &expectedResponseStruct)
 	if err != nil {
 		t.Errorf("Error unmarshalling json: %v", err)
 	}
 
-	// Validate all product structs in the slice
-	for _, product := range expectedResponseStructSlice {
+	// Validate all product structs in the page
+	for _, product := range expectedResponseStruct.Products {
 		err = validate.Struct(product)
 		if err != nil {
 			t.Errorf("Error validating struct: %v", err)
@@ -592,7 +708,7 @@ func TestAddPinnedProduct(t *testing.T) {
 	reqBody := `{"product_id": 2}`
 	expectedHTTPStatusCode := http.StatusCreated
 	expectedResponseStruct := Product{}
-	bodyBytes := reqTester(t, post, endpoint, reqBody, expectedHTTPStatusCode)
+	bodyBytes := authReqTester(t, 1, post, endpoint, reqBody, expectedHTTPStatusCode)
 
 	// Test decoding of JSON response body
 	err := json.Unmarshal(bodyBytes, &expectedResponseStruct)
@@ -607,8 +723,10 @@ func TestAddPinnedProduct(t *testing.T) {
 	}
 
 	// Delete the created test pinned product
+	token, _ := createAccessToken(1)
 	w := httptest.NewRecorder()
 	req, _ := http.NewRequest(del, "/users/1/pinned/2", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
 	router.ServeHTTP(w, req)
 
 	if w.Code != http.StatusNoContent {
@@ -616,26 +734,28 @@ func TestAddPinnedProduct(t *testing.T) {
 		fmt.Println(w.Body.String())
 	}
 
-	// Test with invalid user ID
+	// Test with another user's ID
 	endpoint = "/users/99999/pinned"
-	expectedHTTPStatusCode = http.StatusNotFound
+	expectedHTTPStatusCode = http.StatusForbidden
 
-	reqTester(t, post, endpoint, reqBody, expectedHTTPStatusCode)
+	authReqTester(t, 1, post, endpoint, reqBody, expectedHTTPStatusCode)
 
 	// Test with invalid product ID
 	endpoint = "/users/1/pinned"
 	reqBody = `{"product_id": 99999}`
 	expectedHTTPStatusCode = http.StatusBadRequest
 
-	reqTester(t, post, endpoint, reqBody, expectedHTTPStatusCode)
+	authReqTester(t, 1, post, endpoint, reqBody, expectedHTTPStatusCode)
 }
 
 func TestDeletePinnedProduct(t *testing.T) {
 	// Add pinned product to be deleted
 	reqBody := `{"product_id": 2}`
+	token, _ := createAccessToken(1)
 	w := httptest.NewRecorder()
 	req, _ := http.NewRequest(post, "/users/1/pinned", strings.NewReader(reqBody))
 	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+token)
 	router.ServeHTTP(w, req)
 
 	if w.Code != http.StatusCreated {
@@ -649,22 +769,22 @@ func TestDeletePinnedProduct(t *testing.T) {
 	// Test with valid but wrong user ID and valid product ID
 	endpoint := "/users/2/pinned/2"
 	expectedHTTPStatusCode := http.StatusNotFound
-	reqTester(t, del, endpoint, "", expectedHTTPStatusCode)
+	authReqTester(t, 2, del, endpoint, "", expectedHTTPStatusCode)
 
 	// Test with valid user ID and valid product ID
 	endpoint = "/users/1/pinned/2"
 	expectedHTTPStatusCode = http.StatusNoContent
-	reqTester(t, del, endpoint, "", expectedHTTPStatusCode)
+	authReqTester(t, 1, del, endpoint, "", expectedHTTPStatusCode)
 
-	// Test with invalid user ID
+	// Test with another user's ID
 	endpoint = "/users/99999/pinned/2"
-	expectedHTTPStatusCode = http.StatusNotFound
-	reqTester(t, del, endpoint, "", expectedHTTPStatusCode)
+	expectedHTTPStatusCode = http.StatusForbidden
+	authReqTester(t, 1, del, endpoint, "", expectedHTTPStatusCode)
 
 	// Test with invalid product ID
 	endpoint = "/users/1/pinned/99999"
 	expectedHTTPStatusCode = http.StatusNotFound
-	reqTester(t, del, endpoint, "", expectedHTTPStatusCode)
+	authReqTester(t, 1, del, endpoint, "", expectedHTTPStatusCode)
 }
 
 func TestGetUserFollowing(t *testing.T) {
@@ -704,15 +824,15 @@ func TestGetUserFollowingProducts(t *testing.T) {
 	req, _ := http.NewRequest(get, "/users/1/following/products", nil)
 	router.ServeHTTP(w, req)
 
-	var productarray []Product
+	var page ProductPage
 
-	err := json.Unmarshal(w.Body.Bytes(), &productarray)
+	err := json.Unmarshal(w.Body.Bytes(), &page)
 	if err != nil {
 		t.Errorf("Error unmarshalling json: %v", err)
 	}
 
-	// Validate all Product structs in the array productarray
-	for _, product := range productarray {
+	// Validate all Product structs in the page
+	for _, product := range page.Products {
 		err = validate.Struct(product)
 		if err != nil {
 			t.Errorf("Error validating struct: %v", err)
@@ -733,6 +853,25 @@ func TestGetUserFollowingProducts(t *testing.T) {
 func reqTester(t *testing.T, httpMethod string, endpoint string, reqBody string, expectedHTTPStatusCode int) []byte {
 	t.Helper()
 
+	return tokenReqTester(t, "", httpMethod, endpoint, reqBody, expectedHTTPStatusCode)
+}
+
+// authReqTester is a helper function for request testing as the user with the given ID
+func authReqTester(t *testing.T, userID int, httpMethod string, endpoint string, reqBody string, expectedHTTPStatusCode int) []byte {
+	t.Helper()
+
+	token, err := createAccessToken(userID)
+	if err != nil {
+		t.Fatalf("Error creating token: %v", err)
+	}
+
+	return tokenReqTester(t, token, httpMethod, endpoint, reqBody, expectedHTTPStatusCode)
+}
+
+// tokenReqTester is a helper function for request testing with the given bearer token
+func tokenReqTester(t *testing.T, token string, httpMethod string, endpoint string, reqBody string, expectedHTTPStatusCode int) []byte {
+	t.Helper()
+
 	w := httptest.NewRecorder()
 	req, _ := http.NewRequest(httpMethod, endpoint, strings.NewReader(reqBody))
 
@@ -740,6 +879,10 @@ func reqTester(t *testing.T, httpMethod string, endpoint string, reqBody string,
 		req.Header.Set("Content-Type", "application/json")
 	}
 
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
 	router.ServeHTTP(w, req)
 
 	if !assert.Equal(t, expectedHTTPStatusCode, w.Code) {
@@ -755,7 +898,7 @@ func TestUpdateUser(t *testing.T) {
 	reqBody := `{"name": "Test User", "phone_number": "+12027485281", "password": "a nice password", "business": false}`
 	expectedHTTPStatusCode := http.StatusCreated
 	expectedResponseStruct := User{}
-	bodyBytes := reqTester(t, put, endpoint, reqBody, expectedHTTPStatusCode)
+	bodyBytes := authReqTester(t, 2, put, endpoint, reqBody, expectedHTTPStatusCode)
 
 	// Test decoding of JSON response body
 	err := json.Unmarshal(bodyBytes, &expectedResponseStruct)
@@ -781,13 +924,13 @@ func TestUpdateUser(t *testing.T) {
 	expectedHTTPStatusCode = http.StatusCreated
 	expectedResponseStruct = User{}
 
-	reqTester(t, put, endpoint, reqBody, expectedHTTPStatusCode)
+	authReqTester(t, 2, put, endpoint, reqBody, expectedHTTPStatusCode)
 
-	// Test with invalid user ID
-	w := httptest.NewRecorder()
-	req, _ := http.NewRequest(put, "/users/99999", nil)
-	router.ServeHTTP(w, req)
-	assert.Equal(t, http.StatusNotFound, w.Code)
+	// Test with another user's ID
+	endpoint = "/users/99999"
+	expectedHTTPStatusCode = http.StatusForbidden
+
+	authReqTester(t, 2, put, endpoint, reqBody, expectedHTTPStatusCode)
 }
 
 func TestLogin(t *testing.T) {
@@ -795,70 +938,162 @@ func TestLogin(t *testing.T) {
 	endpoint := "/login" //nolint:goconst // No const is better for readability
 	reqBody := `{"phone_number": "+12027455483", "password": "lorem ipsum"}`
 	expectedHTTPStatusCode := http.StatusCreated
-	reqTester(t, post, endpoint, reqBody, expectedHTTPStatusCode)
+	bodyBytes := reqTester(t, post, endpoint, reqBody, expectedHTTPStatusCode)
+
+	// Test decoding of JSON response body
+	var response tokenResponse
+
+	err := json.Unmarshal(bodyBytes, &response)
+	if err != nil {
+		t.Errorf("Error unmarshalling json: %v", err)
+	}
+
+	assert.NotEmpty(t, response.Token)
+	assert.NotEmpty(t, response.RefreshToken)
 
 	// Test with invalid phone number
 	endpoint = "/login"
-	reqBody = `{"phone_number": "+99999999999", "password": "lorem ipsum"}`
+	reqBody = `{"phone_number": "` + fmt.Sprintf("+1204%07d", time.Now().UnixNano()%10000000) + `", "password": "lorem ipsum"}`
 	expectedHTTPStatusCode = http.StatusBadRequest
-	reqTester(t, post, endpoint, reqBody, expectedHTTPStatusCode)
+	bodyBytes = reqTester(t, post, endpoint, reqBody, expectedHTTPStatusCode)
+	invalidPhoneNumberBody := string(bodyBytes)
 
 	// Test with invalid password
 	endpoint = "/login"
 	reqBody = `{"phone_number": "+12027455483", "password": "wrong password"}`
 	expectedHTTPStatusCode = http.StatusBadRequest
-	reqTester(t, post, endpoint, reqBody, expectedHTTPStatusCode)
+	bodyBytes = reqTester(t, post, endpoint, reqBody, expectedHTTPStatusCode)
+
+	// Test that the response does not reveal whether the phone number exists
+	assert.Equal(t, invalidPhoneNumberBody, string(bodyBytes))
 }
 
-func TestGetUserChats(t *testing.T) {
-	// Test with valid user ID
+func TestLoginLockout(t *testing.T) {
+	user := createTestUser(t)
+
+	// Test failing logins until locked out
+	endpoint := "/login"
+	reqBody := `{"phone_number": "` + user.PhoneNumber + `", "password": "wrong password"}`
+	expectedHTTPStatusCode := http.StatusBadRequest
+
+	for i := 0; i < loginLockoutThreshold; i++ {
+		reqTester(t, post, endpoint, reqBody, expectedHTTPStatusCode)
+	}
+
+	// Test that the right password is rejected during the lockout
+	reqBody = `{"phone_number": "` + user.PhoneNumber + `", "password": "a nice password"}`
+	expectedHTTPStatusCode = http.StatusTooManyRequests
+
 	w := httptest.NewRecorder()
-	req, _ := http.NewRequest(get, "/users/1/chats", nil)
+	req, _ := http.NewRequest(post, endpoint, strings.NewReader(reqBody))
 	router.ServeHTTP(w, req)
 
-	var chatarray []Chat
+	assert.Equal(t, expectedHTTPStatusCode, w.Code)
+	assert.NotEmpty(t, w.Header().Get("Retry-After"))
+}
 
-	err := json.Unmarshal(w.Body.Bytes(), &chatarray)
-	if err != nil {
-		t.Errorf("Error unmarshalling json: %v", err)
+func TestLoginLockoutDuration(t *testing.T) {
+	assert.Zero(t, loginLockoutDuration(loginLockoutThreshold-1))
+	assert.Equal(t, loginLockoutBase, loginLockoutDuration(loginLockoutThreshold))
+	assert.Equal(t, 2*loginLockoutBase, loginLockoutDuration(loginLockoutThreshold+1))
+	assert.Equal(t, loginLockoutMax, loginLockoutDuration(1000))
+}
+
+func TestRateLimit(t *testing.T) {
+	limitedRouter := gin.New()
+	limitedRouter.GET("/limited", rateLimit("test."+uuid.NewString(), 2, time.Minute), ping)
+
+	// Test that the burst is allowed
+	for i := 0; i < 2; i++ {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(get, "/limited", nil)
+		limitedRouter.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
 	}
 
-	// Validate all User structs in the array userarray
-	for _, user := range chatarray {
-		err = validate.Struct(user)
-		if err != nil {
-			t.Errorf("Error validating struct: %v", err)
-		}
+	// Test that further requests are limited
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(get, "/limited", nil)
+	limitedRouter.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.NotEmpty(t, w.Header().Get("Retry-After"))
+}
+
+func TestRouterRateLimit(t *testing.T) {
+	limitedRouter := newRouter(rateLimit)
+
+	// Requests are limited per client, so each run is a new client
+	n := time.Now().UnixNano()
+	clientAddr := fmt.Sprintf("10.%d.%d.%d:1234", n>>24&255, n>>16&255, n>>8&255)
+	limitReqTester := func(expectedHTTPStatusCode int) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(post, "/login", strings.NewReader(`{}`))
+		req.RemoteAddr = clientAddr
+		limitedRouter.ServeHTTP(w, req)
+
+		assert.Equal(t, expectedHTTPStatusCode, w.Code)
+
+		return w
 	}
 
-	assert.Equal(t, http.StatusOK, w.Code)
+	// Test that the burst of the routes for logging in is allowed
+	for i := 0; i < 20; i++ {
+		limitReqTester(http.StatusBadRequest)
+	}
+
+	// Test that further requests are limited
+	w := limitReqTester(http.StatusTooManyRequests)
+	assert.NotEmpty(t, w.Header().Get("Retry-After"))
 
-	// Test with valid alt user ID
+	// Test that the limit is shared by the routes and not by other routes
 	w = httptest.NewRecorder()
-	req, _ = http.NewRequest(get, "/users/2/chats", nil)
-	router.ServeHTTP(w, req)
+	req, _ := http.NewRequest(post, "/token/refresh", strings.NewReader(`{}`))
+	req.RemoteAddr = clientAddr
+	limitedRouter.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest(get, "/users/1", nil)
+	req.RemoteAddr = clientAddr
+	limitedRouter.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+}
+
+func TestGetUserChats(t *testing.T) {
+	// Test with the user's own ID
+	endpoint := "/users/1/chats"
+	expectedHTTPStatusCode := http.StatusOK
 
-	err = json.Unmarshal(w.Body.Bytes(), &chatarray)
+	bodyBytes := authReqTester(t, 1, get, endpoint, "", expectedHTTPStatusCode)
+
+	var chatters []User
+
+	err := json.Unmarshal(bodyBytes, &chatters)
 	if err != nil {
 		t.Errorf("Error unmarshalling json: %v", err)
 	}
 
-	// Validate all User structs in the array userarray
-	for _, user := range chatarray {
-		err = validate.Struct(user)
-		if err != nil {
-			t.Errorf("Error validating struct: %v", err)
-		}
+	// Test that only the public details of the users are returned
+	for _, user := range chatters {
+		assert.NotZero(t, user.UserID)
+		assert.Empty(t, user.PhoneNumber)
+		assert.Empty(t, user.Password)
 	}
 
-	assert.Equal(t, http.StatusOK, w.Code)
+	// Test with another user's ID
+	endpoint = "/users/2/chats"
+	expectedHTTPStatusCode = http.StatusForbidden
 
-	// Test with invalid user ID
-	w = httptest.NewRecorder()
-	req, _ = http.NewRequest(get, "/users/99999/chats", nil)
-	router.ServeHTTP(w, req)
+	authReqTester(t, 1, get, endpoint, "", expectedHTTPStatusCode)
 
-	assert.Equal(t, http.StatusNotFound, w.Code)
+	// Test without authentication
+	expectedHTTPStatusCode = http.StatusUnauthorized
+
+	reqTester(t, get, endpoint, "", expectedHTTPStatusCode)
 }
 
 func TestCreateAndDeleteChat(t *testing.T) {
@@ -868,13 +1103,13 @@ func TestCreateAndDeleteChat(t *testing.T) {
 	expectedHTTPStatusCode := http.StatusNoContent
 	expectedResponseStruct := User{}
 
-	reqTester(t, del, endpoint, reqBody, expectedHTTPStatusCode)
+	authReqTester(t, 1, del, endpoint, reqBody, expectedHTTPStatusCode)
 
 	// Test create same chat
 	endpoint = "/users/2/chats"
 	reqBody = `{"user_id": 1}`
 	expectedHTTPStatusCode = http.StatusCreated
-	bodyBytes := reqTester(t, post, endpoint, reqBody, expectedHTTPStatusCode)
+	bodyBytes := authReqTester(t, 2, post, endpoint, reqBody, expectedHTTPStatusCode)
 
 	// Test decoding of JSON response body
 	err := json.Unmarshal(bodyBytes, &expectedResponseStruct)
@@ -891,23 +1126,2346 @@ func TestCreateAndDeleteChat(t *testing.T) {
 	// Test delete and create again
 	endpoint = "/users/2/chats/1"
 	expectedHTTPStatusCode = http.StatusNoContent
-	reqTester(t, del, endpoint, reqBody, expectedHTTPStatusCode)
+	authReqTester(t, 2, del, endpoint, reqBody, expectedHTTPStatusCode)
 
 	endpoint = "/users/1/chats"
 	reqBody = `{"user_id": 2}`
 	expectedHTTPStatusCode = http.StatusCreated
-	reqTester(t, post, endpoint, reqBody, expectedHTTPStatusCode)
+	authReqTester(t, 1, post, endpoint, reqBody, expectedHTTPStatusCode)
 
-	// Test with invalid user ID
+	// Test with another user's ID
 	endpoint = "/users/99999/chats"
-	expectedHTTPStatusCode = http.StatusNotFound
+	expectedHTTPStatusCode = http.StatusForbidden
 
-	reqTester(t, post, endpoint, reqBody, expectedHTTPStatusCode)
+	authReqTester(t, 1, post, endpoint, reqBody, expectedHTTPStatusCode)
 
 	// Test with invalid product ID
 	endpoint = "/users/2/chats"
 	reqBody = `{"user_id": 99999}`
 	expectedHTTPStatusCode = http.StatusNotFound
 
+	authReqTester(t, 2, post, endpoint, reqBody, expectedHTTPStatusCode)
+}
+
+func TestUpdateProduct(t *testing.T) {
+	// Test with valid JSON body as the owner of the product
+	endpoint := "/products/4"
+	reqBody := `{"name": "Car", "service": true, "price": 1, "description": "Car description"}`
+	expectedHTTPStatusCode := http.StatusCreated
+	expectedResponseStruct := Product{}
+	bodyBytes := authReqTester(t, 2, put, endpoint, reqBody, expectedHTTPStatusCode)
+
+	// Test decoding of JSON response body
+	err := json.Unmarshal(bodyBytes, &expectedResponseStruct)
+	if err != nil {
+		t.Errorf("Error unmarshalling json: %v", err)
+	}
+
+	// Validate struct
+	err = validate.Struct(expectedResponseStruct)
+	if err != nil {
+		t.Errorf("Error validating struct: %v", err)
+	}
+
+	// Test as a user that does not own the product
+	expectedHTTPStatusCode = http.StatusForbidden
+	authReqTester(t, 1, put, endpoint, reqBody, expectedHTTPStatusCode)
+
+	// Test without token
+	expectedHTTPStatusCode = http.StatusUnauthorized
+	reqTester(t, put, endpoint, reqBody, expectedHTTPStatusCode)
+
+	// Test with invalid product ID
+	endpoint = "/products/99999"
+	expectedHTTPStatusCode = http.StatusNotFound
+	authReqTester(t, 2, put, endpoint, reqBody, expectedHTTPStatusCode)
+}
+
+func TestRefreshToken(t *testing.T) {
+	refreshToken, err := createRefreshToken(2)
+	if err != nil {
+		t.Fatalf("Error creating token: %v", err)
+	}
+
+	// Test with valid refresh token
+	endpoint := "/token/refresh"
+	reqBody := `{"refresh_token": "` + refreshToken + `"}`
+	expectedHTTPStatusCode := http.StatusCreated
+	bodyBytes := reqTester(t, post, endpoint, reqBody, expectedHTTPStatusCode)
+
+	var response tokenResponse
+
+	err = json.Unmarshal(bodyBytes, &response)
+	if err != nil {
+		t.Errorf("Error unmarshalling json: %v", err)
+	}
+
+	assert.Equal(t, 2, response.ID)
+
+	// Test that the new access token is accepted
+	tokenReqTester(t, response.Token, put, "/products/4", `{"name": "Car", "service": true, "price": 1, "description": "Car description"}`, http.StatusCreated)
+
+	// Test reusing the rotated refresh token
+	expectedHTTPStatusCode = http.StatusUnauthorized
+	reqTester(t, post, endpoint, reqBody, expectedHTTPStatusCode)
+
+	// Test with an access token instead of a refresh token
+	reqBody = `{"refresh_token": "` + response.Token + `"}`
+	expectedHTTPStatusCode = http.StatusUnauthorized
+	reqTester(t, post, endpoint, reqBody, expectedHTTPStatusCode)
+
+	// Test with invalid JSON body
+	reqBody = `{"invalid-field-name": "abc"}`
+	expectedHTTPStatusCode = http.StatusBadRequest
 	reqTester(t, post, endpoint, reqBody, expectedHTTPStatusCode)
 }
+
+func TestRevokeUserTokens(t *testing.T) {
+	user := createTestUser(t)
+	accessToken, _ := createAccessToken(user.UserID)
+
+	if err := revokeUserTokens(user.UserID); err != nil {
+		t.Fatalf("Error revoking tokens: %v", err)
+	}
+
+	// Test that tokens issued in the same second are revoked only if issued before the revocation
+	newAccessToken, _ := createAccessToken(user.UserID)
+
+	revoked, err := tokenRevoked(accessToken)
+	if assert.NoError(t, err) {
+		assert.True(t, revoked)
+	}
+
+	revoked, err = tokenRevoked(newAccessToken)
+	if assert.NoError(t, err) {
+		assert.False(t, revoked)
+	}
+
+	tokenReqTester(t, accessToken, get, "/users/"+strconv.Itoa(user.UserID)+"/chats/groups", "", http.StatusUnauthorized)
+	tokenReqTester(t, newAccessToken, get, "/users/"+strconv.Itoa(user.UserID)+"/chats/groups", "", http.StatusOK)
+
+	// Test that sessions signed in with a revoked token end
+	assert.True(t, messageController.SessionRevoked(accessToken))
+	assert.False(t, messageController.SessionRevoked(newAccessToken))
+	assert.True(t, messageController.SessionRevoked("not a token"))
+}
+
+func TestLogout(t *testing.T) {
+	accessToken, _ := createAccessToken(2)
+	refreshToken, _ := createRefreshToken(2)
+
+	// Test logout with refresh token
+	endpoint := "/logout"
+	reqBody := `{"refresh_token": "` + refreshToken + `"}`
+	expectedHTTPStatusCode := http.StatusNoContent
+	tokenReqTester(t, accessToken, post, endpoint, reqBody, expectedHTTPStatusCode)
+
+	// Test that both tokens have been revoked
+	expectedHTTPStatusCode = http.StatusUnauthorized
+	tokenReqTester(t, accessToken, post, endpoint, "", expectedHTTPStatusCode)
+
+	reqBody = `{"refresh_token": "` + refreshToken + `"}`
+	reqTester(t, post, "/token/refresh", reqBody, expectedHTTPStatusCode)
+
+	// Test without token
+	reqTester(t, post, endpoint, "", expectedHTTPStatusCode)
+}
+
+func TestLoadJWTKeys(t *testing.T) {
+	// Restore the configured keys after the test
+	defer func(keys map[string]*jwtSigningKey, activeKey *jwtSigningKey) {
+		jwtKeys = keys
+		jwtActiveKey = activeKey
+	}(jwtKeys, jwtActiveKey)
+
+	// Write an Ed25519 private key to a file
+	_, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	der, err := x509.MarshalPKCS8PrivateKey(privateKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	keyFile := filepath.Join(t.TempDir(), "jwt.pem")
+
+	err = os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Sign a token with the old key
+	err = loadJWTKeys("old:old secret", "", "")
+	if err != nil {
+		t.Fatalf("Error loading keys: %v", err)
+	}
+
+	oldToken, err := createAccessToken(1)
+	if err != nil {
+		t.Fatalf("Error creating token: %v", err)
+	}
+
+	// Rotate to the Ed25519 key while still accepting the old key
+	err = loadJWTKeys("old:old secret", "new:"+keyFile, "new")
+	if err != nil {
+		t.Fatalf("Error loading keys: %v", err)
+	}
+
+	assert.Equal(t, "EdDSA", jwtActiveKey.method.Alg())
+
+	newToken, err := createAccessToken(1)
+	if err != nil {
+		t.Fatalf("Error creating token: %v", err)
+	}
+
+	_, err = parseToken(oldToken, tokenTypeAccess)
+	assert.NoError(t, err)
+
+	_, err = parseToken(newToken, tokenTypeAccess)
+	assert.NoError(t, err)
+
+	// Remove the old key
+	err = loadJWTKeys("", "new:"+keyFile, "")
+	if err != nil {
+		t.Fatalf("Error loading keys: %v", err)
+	}
+
+	_, err = parseToken(oldToken, tokenTypeAccess)
+	assert.Error(t, err)
+
+	// Test with invalid configurations
+	assert.Error(t, loadJWTKeys("no separator", "", ""))
+	assert.Error(t, loadJWTKeys("a:secret,a:other secret", "", ""))
+	assert.Error(t, loadJWTKeys("a:secret", "", "b"))
+	assert.Error(t, loadJWTKeys("", "a:"+filepath.Join(t.TempDir(), "missing.pem"), ""))
+
+	// Test that keys must be configured in release mode
+	mode := gin.Mode()
+	gin.SetMode(gin.ReleaseMode)
+	err = loadJWTKeys("", "", "")
+	gin.SetMode(mode)
+
+	assert.ErrorIs(t, err, errNoJWTKeys)
+	assert.NoError(t, loadJWTKeys("", "", ""))
+}
+
+// createTestUser is a helper function creating a user with a unique phone number, which is deleted after the test
+func createTestUser(t *testing.T) User {
+	t.Helper()
+
+	phoneNumber := fmt.Sprintf("+1203%07d", time.Now().UnixNano()%10000000)
+	reqBody := `{"name": "Test User", "phone_number": "` + phoneNumber + `", "password": "a nice password", "business": false}`
+	bodyBytes := reqTester(t, post, "/users", reqBody, http.StatusCreated)
+
+	var user User
+
+	err := json.Unmarshal(bodyBytes, &user)
+	if err != nil {
+		t.Fatalf("Error unmarshalling json of test user: %v", err)
+	}
+
+	t.Cleanup(func() {
+		_, err := dbPool.Exec(context.Background(), "DELETE FROM Users WHERE user_id = $1", user.UserID)
+		if err != nil {
+			fmt.Println("Notice: the created test user could not be deleted.", err)
+		}
+	})
+
+	return user
+}
+
+func TestPhoneVerification(t *testing.T) {
+	// Capture the sent messages
+	var sentMessages bytes.Buffer
+
+	defer func(sender SMSSender) {
+		smsSender = sender
+	}(smsSender)
+
+	smsSender = newLogSMSSender(&sentMessages)
+
+	user := createTestUser(t)
+	endpoint := "/users/" + strconv.Itoa(user.UserID) + "/verification"
+
+	// Test requesting a code
+	expectedHTTPStatusCode := http.StatusAccepted
+	authReqTester(t, user.UserID, post, endpoint, "", expectedHTTPStatusCode)
+
+	code := regexp.MustCompile(`code is (\d{6})`).FindStringSubmatch(sentMessages.String())
+	if !assert.Len(t, code, 2, "No code sent: "+sentMessages.String()) {
+		return
+	}
+
+	assert.Contains(t, sentMessages.String(), user.PhoneNumber)
+
+	// Test requesting another code right away
+	expectedHTTPStatusCode = http.StatusTooManyRequests
+	authReqTester(t, user.UserID, post, endpoint, "", expectedHTTPStatusCode)
+
+	// Test confirming with the wrong code
+	wrongCode := "000000"
+	if code[1] == wrongCode {
+		wrongCode = "111111"
+	}
+
+	expectedHTTPStatusCode = http.StatusBadRequest
+	authReqTester(t, user.UserID, post, endpoint+"/confirm", `{"code": "`+wrongCode+`"}`, expectedHTTPStatusCode)
+
+	// Test confirming as another user
+	expectedHTTPStatusCode = http.StatusForbidden
+	authReqTester(t, 1, post, endpoint+"/confirm", `{"code": "`+code[1]+`"}`, expectedHTTPStatusCode)
+
+	// Test confirming with the right code
+	expectedHTTPStatusCode = http.StatusOK
+	authReqTester(t, user.UserID, post, endpoint+"/confirm", `{"code": "`+code[1]+`"}`, expectedHTTPStatusCode)
+
+	bodyBytes := reqTester(t, get, "/users/"+strconv.Itoa(user.UserID), "", http.StatusOK)
+
+	err := json.Unmarshal(bodyBytes, &user)
+	if err != nil {
+		t.Errorf("Error unmarshalling json: %v", err)
+	}
+
+	assert.True(t, user.Verified)
+
+	// Test that the code can not be reused
+	expectedHTTPStatusCode = http.StatusBadRequest
+	authReqTester(t, user.UserID, post, endpoint+"/confirm", `{"code": "`+code[1]+`"}`, expectedHTTPStatusCode)
+
+	// Test requesting a code for a verified phone number
+	expectedHTTPStatusCode = http.StatusConflict
+	authReqTester(t, user.UserID, post, endpoint, "", expectedHTTPStatusCode)
+}
+
+func TestPasswordReset(t *testing.T) {
+	// Capture the sent messages
+	var sentMessages bytes.Buffer
+
+	defer func(sender SMSSender) {
+		smsSender = sender
+	}(smsSender)
+
+	smsSender = newLogSMSSender(&sentMessages)
+
+	user := createTestUser(t)
+	accessToken, _ := createAccessToken(user.UserID)
+	refreshToken, _ := createRefreshToken(user.UserID)
+
+	// Test requesting a code
+	endpoint := "/password/forgot"
+	reqBody := `{"phone_number": "` + user.PhoneNumber + `"}`
+	expectedHTTPStatusCode := http.StatusAccepted
+	reqTester(t, post, endpoint, reqBody, expectedHTTPStatusCode)
+
+	code := regexp.MustCompile(`code is (\d{6})`).FindStringSubmatch(sentMessages.String())
+	if !assert.Len(t, code, 2, "No code sent: "+sentMessages.String()) {
+		return
+	}
+
+	// Test requesting a code for a phone number without a user
+	reqBody = `{"phone_number": "` + fmt.Sprintf("+1204%07d", time.Now().UnixNano()%10000000) + `"}`
+	reqTester(t, post, endpoint, reqBody, expectedHTTPStatusCode)
+
+	// Test with an invalid phone number
+	reqBody = `{"phone_number": "not a phone number"}`
+	expectedHTTPStatusCode = http.StatusBadRequest
+	reqTester(t, post, endpoint, reqBody, expectedHTTPStatusCode)
+
+	// Test resetting with the wrong code
+	wrongCode := "000000"
+	if code[1] == wrongCode {
+		wrongCode = "111111"
+	}
+
+	endpoint = "/password/reset"
+	reqBody = `{"phone_number": "` + user.PhoneNumber + `", "code": "` + wrongCode + `", "password": "a new password"}`
+	reqTester(t, post, endpoint, reqBody, expectedHTTPStatusCode)
+
+	// Test resetting with the right code
+	reqBody = `{"phone_number": "` + user.PhoneNumber + `", "code": "` + code[1] + `", "password": "a new password"}`
+	expectedHTTPStatusCode = http.StatusNoContent
+	reqTester(t, post, endpoint, reqBody, expectedHTTPStatusCode)
+
+	// Test that the code can not be reused
+	expectedHTTPStatusCode = http.StatusBadRequest
+	reqTester(t, post, endpoint, reqBody, expectedHTTPStatusCode)
+
+	// Test that the issued tokens have been revoked
+	expectedHTTPStatusCode = http.StatusUnauthorized
+	tokenReqTester(t, accessToken, post, "/logout", "", expectedHTTPStatusCode)
+	reqTester(t, post, "/token/refresh", `{"refresh_token": "`+refreshToken+`"}`, expectedHTTPStatusCode)
+
+	// Test logging in with the new password
+	reqBody = `{"phone_number": "` + user.PhoneNumber + `", "password": "a new password"}`
+	expectedHTTPStatusCode = http.StatusCreated
+	reqTester(t, post, "/login", reqBody, expectedHTTPStatusCode)
+
+	// Test the rate limit of requesting codes
+	endpoint = "/password/forgot"
+	reqBody = `{"phone_number": "` + user.PhoneNumber + `"}`
+	expectedHTTPStatusCode = http.StatusAccepted
+
+	for i := 1; i < passwordForgotLimit; i++ {
+		reqTester(t, post, endpoint, reqBody, expectedHTTPStatusCode)
+	}
+
+	expectedHTTPStatusCode = http.StatusTooManyRequests
+	reqTester(t, post, endpoint, reqBody, expectedHTTPStatusCode)
+}
+
+// createTestAdmin creates a user with the admin role that is deleted when the test finishes.
+func createTestAdmin(t *testing.T) User {
+	t.Helper()
+
+	admin := createTestUser(t)
+
+	_, err := dbPool.Exec(context.Background(), "UPDATE Users SET role = $2 WHERE user_id = $1", admin.UserID, roleAdmin)
+	if err != nil {
+		t.Fatalf("Error making test user an admin: %v", err)
+	}
+
+	admin.Role = roleAdmin
+
+	return admin
+}
+
+func TestAdminRequired(t *testing.T) {
+	// Test without token
+	endpoint := "/admin/flags"
+	expectedHTTPStatusCode := http.StatusUnauthorized
+	reqTester(t, get, endpoint, "", expectedHTTPStatusCode)
+
+	// Test as a user that is not an admin
+	expectedHTTPStatusCode = http.StatusForbidden
+	authReqTester(t, 1, get, endpoint, "", expectedHTTPStatusCode)
+
+	// Test as an admin
+	admin := createTestAdmin(t)
+	expectedHTTPStatusCode = http.StatusOK
+	authReqTester(t, admin.UserID, get, endpoint, "", expectedHTTPStatusCode)
+}
+
+func TestAdminBanUser(t *testing.T) {
+	admin := createTestAdmin(t)
+	user := createTestUser(t)
+	accessToken, _ := createAccessToken(user.UserID)
+
+	// Test banning the user
+	endpoint := "/admin/users/" + strconv.Itoa(user.UserID) + "/ban"
+	expectedHTTPStatusCode := http.StatusOK
+	authReqTester(t, admin.UserID, post, endpoint, "", expectedHTTPStatusCode)
+
+	// Test that the tokens of the user have been revoked and it can not log in
+	expectedHTTPStatusCode = http.StatusUnauthorized
+	tokenReqTester(t, accessToken, post, "/logout", "", expectedHTTPStatusCode)
+
+	loginBody := `{"phone_number": "` + user.PhoneNumber + `", "password": "a nice password"}`
+	expectedHTTPStatusCode = http.StatusForbidden
+	reqTester(t, post, "/login", loginBody, expectedHTTPStatusCode)
+
+	// Test unbanning the user
+	expectedHTTPStatusCode = http.StatusOK
+	authReqTester(t, admin.UserID, del, endpoint, "", expectedHTTPStatusCode)
+
+	expectedHTTPStatusCode = http.StatusCreated
+	reqTester(t, post, "/login", loginBody, expectedHTTPStatusCode)
+
+	// Test banning an admin
+	endpoint = "/admin/users/" + strconv.Itoa(admin.UserID) + "/ban"
+	expectedHTTPStatusCode = http.StatusForbidden
+	authReqTester(t, admin.UserID, post, endpoint, "", expectedHTTPStatusCode)
+
+	// Test banning a user that does not exist
+	endpoint = "/admin/users/0/ban"
+	expectedHTTPStatusCode = http.StatusNotFound
+	authReqTester(t, admin.UserID, post, endpoint, "", expectedHTTPStatusCode)
+}
+
+func TestAdminCommunities(t *testing.T) {
+	admin := createTestAdmin(t)
+
+	// Test creating a community
+	endpoint := "/admin/communities"
+	reqBody := `{"name": "Test community"}`
+	expectedHTTPStatusCode := http.StatusCreated
+	bodyBytes := authReqTester(t, admin.UserID, post, endpoint, reqBody, expectedHTTPStatusCode)
+
+	var community Community
+
+	err := json.Unmarshal(bodyBytes, &community)
+	if err != nil {
+		t.Fatalf("Error unmarshalling json: %v", err)
+	}
+
+	t.Cleanup(func() {
+		_, err := dbPool.Exec(context.Background(), "DELETE FROM Community WHERE community_id = $1", community.CommunityID)
+		if err != nil {
+			fmt.Println("Notice: the created test community could not be deleted.", err)
+		}
+	})
+
+	assert.Nil(t, community.OwnerID)
+
+	// Test that admins act as the owner of the community, which has no owner
+	user := createTestUser(t)
+	userID := strconv.Itoa(user.UserID)
+	communityEndpoint := "/communities/" + strconv.Itoa(community.CommunityID)
+
+	reqBody = `{"community_id": ` + strconv.Itoa(community.CommunityID) + `}`
+	authReqTester(t, user.UserID, post, "/users/"+userID+"/communities", reqBody, http.StatusCreated)
+	authReqTester(t, user.UserID, post, communityEndpoint+"/invites", "", http.StatusForbidden)
+	authReqTester(t, admin.UserID, post, communityEndpoint+"/invites", "", http.StatusCreated)
+	authReqTester(t, admin.UserID, put, communityEndpoint+"/moderators/"+userID, "", http.StatusOK)
+	authReqTester(t, user.UserID, post, communityEndpoint+"/invites", "", http.StatusCreated)
+
+	// Test creating an invite-only community
+	reqBody = `{"name": "Invite-only test community", "visibility": "invite_only"}`
+	bodyBytes = authReqTester(t, admin.UserID, post, endpoint, reqBody, http.StatusCreated)
+
+	var inviteOnly Community
+
+	err = json.Unmarshal(bodyBytes, &inviteOnly)
+	if err != nil {
+		t.Fatalf("Error unmarshalling json: %v", err)
+	}
+
+	t.Cleanup(func() {
+		_, err := dbPool.Exec(context.Background(), "DELETE FROM Community WHERE community_id = $1", inviteOnly.CommunityID)
+		if err != nil {
+			fmt.Println("Notice: the created test community could not be deleted.", err)
+		}
+	})
+
+	assert.Equal(t, visibilityInviteOnly, inviteOnly.Visibility)
+	authReqTester(t, admin.UserID, post, "/communities/"+strconv.Itoa(inviteOnly.CommunityID)+"/invites", "", http.StatusCreated)
+
+	// Test renaming the community
+	endpoint = "/admin/communities/" + strconv.Itoa(community.CommunityID)
+	reqBody = `{"name": "Renamed test community"}`
+	expectedHTTPStatusCode = http.StatusOK
+	bodyBytes = authReqTester(t, admin.UserID, put, endpoint, reqBody, expectedHTTPStatusCode)
+
+	err = json.Unmarshal(bodyBytes, &community)
+	if err != nil {
+		t.Errorf("Error unmarshalling json: %v", err)
+	}
+
+	assert.Equal(t, "Renamed test community", community.Name)
+
+	// Test archiving the community
+	expectedHTTPStatusCode = http.StatusOK
+	authReqTester(t, admin.UserID, post, endpoint+"/archive", "", expectedHTTPStatusCode)
+
+	bodyBytes = reqTester(t, get, "/communities", "", http.StatusOK)
+	assert.NotContains(t, string(bodyBytes), "Renamed test community")
+
+	// Test joining the archived community
+	reqBody = `{"community_id": ` + strconv.Itoa(community.CommunityID) + `}`
+	expectedHTTPStatusCode = http.StatusConflict
+	authReqTester(t, admin.UserID, post, "/users/"+strconv.Itoa(admin.UserID)+"/communities", reqBody, expectedHTTPStatusCode)
+
+	// Test restoring the community
+	expectedHTTPStatusCode = http.StatusOK
+	authReqTester(t, admin.UserID, del, endpoint+"/archive", "", expectedHTTPStatusCode)
+
+	bodyBytes = reqTester(t, get, "/communities", "", http.StatusOK)
+	assert.Contains(t, string(bodyBytes), "Renamed test community")
+
+	// Test renaming a community that does not exist
+	endpoint = "/admin/communities/0"
+	reqBody = `{"name": "Nothing"}`
+	expectedHTTPStatusCode = http.StatusNotFound
+	authReqTester(t, admin.UserID, put, endpoint, reqBody, expectedHTTPStatusCode)
+}
+
+func TestAdminFlaggedContent(t *testing.T) {
+	admin := createTestAdmin(t)
+	user := createTestUser(t)
+
+	// Create a product and a review to flag, of the buyer of the product
+	product := createTestSale(t, user.UserID, 2)
+
+	reqBody := `{"rating": 1, "content": "Flagged review", "reviewer_id": ` + strconv.Itoa(user.UserID) + `, "product_id": ` + strconv.Itoa(product.ProductID) + `}`
+	bodyBytes := authReqTester(t, user.UserID, post, "/users/2/reviews", reqBody, http.StatusCreated)
+
+	var review Review
+
+	err := json.Unmarshal(bodyBytes, &review)
+	if err != nil {
+		t.Fatalf("Error unmarshalling json: %v", err)
+	}
+
+	// Test flagging the product and the review
+	productEndpoint := "/products/" + strconv.Itoa(product.ProductID)
+	reviewEndpoint := "/users/2/reviews/" + strconv.Itoa(review.ReviewID)
+	expectedHTTPStatusCode := http.StatusCreated
+	authReqTester(t, 1, post, productEndpoint+"/flags", `{"reason": "Spam"}`, expectedHTTPStatusCode)
+	authReqTester(t, 1, post, reviewEndpoint+"/flags", "", expectedHTTPStatusCode)
+
+	// Test flagging without token and flagging a review of another user
+	expectedHTTPStatusCode = http.StatusUnauthorized
+	reqTester(t, post, productEndpoint+"/flags", "", expectedHTTPStatusCode)
+
+	expectedHTTPStatusCode = http.StatusNotFound
+	authReqTester(t, 1, post, "/users/1/reviews/"+strconv.Itoa(review.ReviewID)+"/flags", "", expectedHTTPStatusCode)
+
+	// Test listing the flagged content
+	expectedHTTPStatusCode = http.StatusOK
+	bodyBytes = authReqTester(t, admin.UserID, get, "/admin/flags?type=product", "", expectedHTTPStatusCode)
+
+	var flags []Flag
+
+	err = json.Unmarshal(bodyBytes, &flags)
+	if err != nil {
+		t.Errorf("Error unmarshalling json: %v", err)
+	}
+
+	if assert.NotEmpty(t, flags) {
+		assert.Equal(t, product.ProductID, *flags[0].ProductID)
+		assert.Nil(t, flags[0].ReviewID)
+	}
+
+	expectedHTTPStatusCode = http.StatusBadRequest
+	authReqTester(t, admin.UserID, get, "/admin/flags?type=user", "", expectedHTTPStatusCode)
+
+	// Test force-deleting the product and the review
+	expectedHTTPStatusCode = http.StatusNoContent
+	authReqTester(t, admin.UserID, del, "/admin"+productEndpoint, "", expectedHTTPStatusCode)
+	authReqTester(t, admin.UserID, del, "/admin/reviews/"+strconv.Itoa(review.ReviewID), "", expectedHTTPStatusCode)
+
+	expectedHTTPStatusCode = http.StatusNotFound
+	reqTester(t, get, productEndpoint, "", expectedHTTPStatusCode)
+	authReqTester(t, admin.UserID, del, "/admin"+productEndpoint, "", expectedHTTPStatusCode)
+	authReqTester(t, admin.UserID, del, "/admin/reviews/"+strconv.Itoa(review.ReviewID), "", expectedHTTPStatusCode)
+}
+
+func TestCreateAdmin(t *testing.T) {
+	phoneNumber := fmt.Sprintf("+1205%07d", time.Now().UnixNano()%10000000)
+
+	// Test creating a new admin
+	err := runCommand(context.Background(), []string{"create-admin", "-name", "Test Admin", "-phone", phoneNumber, "-password", "admin password"})
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	var admin User
+
+	query := "SELECT user_id, role, verified FROM Users WHERE phone_number = $1"
+
+	err = pgxscan.Get(context.Background(), dbPool, &admin, query, phoneNumber)
+	if err != nil {
+		t.Fatalf("Error getting created admin: %v", err)
+	}
+
+	t.Cleanup(func() {
+		_, err := dbPool.Exec(context.Background(), "DELETE FROM Users WHERE user_id = $1", admin.UserID)
+		if err != nil {
+			fmt.Println("Notice: the created test admin could not be deleted.", err)
+		}
+	})
+
+	assert.Equal(t, roleAdmin, admin.Role)
+	assert.True(t, admin.Verified)
+
+	reqBody := `{"phone_number": "` + phoneNumber + `", "password": "admin password"}`
+	reqTester(t, post, "/login", reqBody, http.StatusCreated)
+
+	// Test making an existing user an admin
+	user := createTestUser(t)
+
+	err = runCommand(context.Background(), []string{"create-admin", "-phone", user.PhoneNumber})
+	assert.NoError(t, err)
+	authReqTester(t, user.UserID, get, "/admin/flags", "", http.StatusOK)
+
+	// Test with invalid arguments
+	err = runCommand(context.Background(), []string{"create-admin", "-phone", "not a phone number"})
+	assert.ErrorIs(t, err, errUsage)
+
+	err = runCommand(context.Background(), []string{"not a command"})
+	assert.ErrorIs(t, err, errUsage)
+}
+
+func TestSearchProducts(t *testing.T) {
+	// Test with Swedish stemming
+	endpoint := "/products/search?q=soffor"
+	expectedHTTPStatusCode := http.StatusOK
+	bodyBytes := reqTester(t, get, endpoint, "", expectedHTTPStatusCode)
+
+	var results []ProductSearchResult
+
+	err := json.Unmarshal(bodyBytes, &results)
+	if err != nil {
+		t.Errorf("Error unmarshalling json: %v", err)
+	}
+
+	if assert.NotEmpty(t, results) {
+		assert.Equal(t, "Soffa", results[0].Name)
+		assert.Equal(t, "<b>Soffa</b>", results[0].NameHighlight)
+	}
+
+	// Test with English stemming
+	endpoint = "/products/search?q=couches"
+	bodyBytes = reqTester(t, get, endpoint, "", expectedHTTPStatusCode)
+
+	results = nil
+
+	err = json.Unmarshal(bodyBytes, &results)
+	if err != nil {
+		t.Errorf("Error unmarshalling json: %v", err)
+	}
+
+	if assert.NotEmpty(t, results) {
+		assert.Equal(t, "Couch", results[0].Name)
+	}
+
+	// Test that updated products are found by their new name
+	user := createTestUser(t)
+	reqBody := `{"name": "Gungstol", "service": false, "price": 1, "description": "A rocking chair"}`
+	bodyBytes = authReqTester(t, user.UserID, post, "/users/"+strconv.Itoa(user.UserID)+"/products", reqBody, http.StatusCreated)
+
+	var product Product
+
+	err = json.Unmarshal(bodyBytes, &product)
+	if err != nil {
+		t.Fatalf("Error unmarshalling json: %v", err)
+	}
+
+	t.Cleanup(func() {
+		_, err := dbPool.Exec(context.Background(), "DELETE FROM Product WHERE product_id = $1", product.ProductID)
+		if err != nil {
+			fmt.Println("Notice: the created test product could not be deleted.", err)
+		}
+	})
+
+	reqBody = `{"name": "Fåtölj", "service": false, "price": 1, "description": "An <img src=x onerror=alert(1)> armchair"}`
+	authReqTester(t, user.UserID, put, "/products/"+strconv.Itoa(product.ProductID), reqBody, http.StatusCreated)
+
+	bodyBytes = reqTester(t, get, "/products/search?q=armchair", "", expectedHTTPStatusCode)
+	assert.Contains(t, string(bodyBytes), "Fåtölj")
+
+	// Test that the text of the highlights is escaped
+	results = nil
+
+	err = json.Unmarshal(bodyBytes, &results)
+	if err != nil {
+		t.Fatalf("Error unmarshalling json: %v", err)
+	}
+
+	for _, result := range results {
+		if result.ProductID == product.ProductID {
+			assert.NotContains(t, result.DescriptionHighlight, "<img")
+			assert.Contains(t, result.DescriptionHighlight, "&lt;img")
+			assert.Contains(t, result.DescriptionHighlight, "<b>armchair</b>")
+		}
+	}
+
+	bodyBytes = reqTester(t, get, "/products/search?q=rocking", "", expectedHTTPStatusCode)
+	assert.NotContains(t, string(bodyBytes), "Fåtölj")
+
+	// Test without query and with an invalid limit
+	expectedHTTPStatusCode = http.StatusBadRequest
+	reqTester(t, get, "/products/search", "", expectedHTTPStatusCode)
+	reqTester(t, get, "/products/search?q=soffa&limit=0", "", expectedHTTPStatusCode)
+}
+
+func TestProductListing(t *testing.T) {
+	user := createTestUser(t)
+	userID := strconv.Itoa(user.UserID)
+
+	// Create products to list
+	for i, price := range []int{10, 20, 30} {
+		reqBody := `{"name": "Listed product ` + strconv.Itoa(i) + `", "service": ` + strconv.FormatBool(price == 30) + `, "price": ` + strconv.Itoa(price) + `}`
+		bodyBytes := authReqTester(t, user.UserID, post, "/users/"+userID+"/products", reqBody, http.StatusCreated)
+
+		var product Product
+
+		err := json.Unmarshal(bodyBytes, &product)
+		if err != nil {
+			t.Fatalf("Error unmarshalling json: %v", err)
+		}
+
+		t.Cleanup(func() {
+			_, err := dbPool.Exec(context.Background(), "DELETE FROM Product WHERE product_id = $1", product.ProductID)
+			if err != nil {
+				fmt.Println("Notice: the created test product could not be deleted.", err)
+			}
+		})
+	}
+
+	listPrices := func(endpoint string) ([]int, *string) {
+		t.Helper()
+
+		bodyBytes := reqTester(t, get, endpoint, "", http.StatusOK)
+
+		var page ProductPage
+
+		err := json.Unmarshal(bodyBytes, &page)
+		if err != nil {
+			t.Fatalf("Error unmarshalling json: %v", err)
+		}
+
+		prices := []int{}
+		for _, product := range page.Products {
+			prices = append(prices, product.Price)
+		}
+
+		return prices, page.NextCursor
+	}
+
+	// Test paginating with a cursor
+	endpoint := "/users/" + userID + "/products?sort=price_asc&limit=2"
+	prices, nextCursor := listPrices(endpoint)
+	assert.Equal(t, []int{10, 20}, prices)
+
+	if !assert.NotNil(t, nextCursor) {
+		return
+	}
+
+	prices, lastCursor := listPrices(endpoint + "&cursor=" + *nextCursor)
+	assert.Equal(t, []int{30}, prices)
+	assert.Nil(t, lastCursor)
+
+	// Test sorting and filtering
+	prices, _ = listPrices("/users/" + userID + "/products?sort=price_desc")
+	assert.Equal(t, []int{30, 20, 10}, prices)
+
+	prices, _ = listPrices("/users/" + userID + "/products?min_price=15&max_price=25")
+	assert.Equal(t, []int{20}, prices)
+
+	prices, _ = listPrices("/users/" + userID + "/products?type=service")
+	assert.Equal(t, []int{30}, prices)
+
+	prices, _ = listPrices("/users/" + userID + "/products?type=goods&sold=false&sort=price_asc")
+	assert.Equal(t, []int{10, 20}, prices)
+
+	prices, _ = listPrices("/users/" + userID + "/products?sold=true")
+	assert.Empty(t, prices)
+
+	prices, _ = listPrices("/users/" + userID + "/products?uploaded_from=2999-01-01")
+	assert.Empty(t, prices)
+
+	// Test with invalid parameters
+	expectedHTTPStatusCode := http.StatusBadRequest
+	reqTester(t, get, "/products?sort=cheapest", "", expectedHTTPStatusCode)
+	reqTester(t, get, "/products?min_price=cheap", "", expectedHTTPStatusCode)
+	reqTester(t, get, "/products?max_price=2147483648", "", expectedHTTPStatusCode)
+	reqTester(t, get, "/products?min_price=-99999999999", "", expectedHTTPStatusCode)
+	reqTester(t, get, "/products?uploaded_to=yesterday", "", expectedHTTPStatusCode)
+	reqTester(t, get, "/products?cursor=not-a-cursor", "", expectedHTTPStatusCode)
+	reqTester(t, get, "/products?sort=newest&cursor="+*nextCursor, "", expectedHTTPStatusCode)
+
+	// Test with cursors whose value is not of the type sorted on
+	tampered := productCursor{Sort: "price_asc", Value: "cheap", ProductID: 1}.encode()
+	reqTester(t, get, "/products?sort=price_asc&cursor="+tampered, "", expectedHTTPStatusCode)
+	tampered = productCursor{Sort: "price_asc", Value: "99999999999", ProductID: 1}.encode()
+	reqTester(t, get, "/products?sort=price_asc&cursor="+tampered, "", expectedHTTPStatusCode)
+	tampered = productCursor{Sort: "newest", Value: "yesterday", ProductID: 1}.encode()
+	reqTester(t, get, "/products?sort=newest&cursor="+tampered, "", expectedHTTPStatusCode)
+}
+
+func TestGetCategories(t *testing.T) {
+	endpoint := "/categories"
+	expectedHTTPStatusCode := http.StatusOK
+	bodyBytes := reqTester(t, get, endpoint, "", expectedHTTPStatusCode)
+
+	var categories []Category
+
+	err := json.Unmarshal(bodyBytes, &categories)
+	if err != nil {
+		t.Errorf("Error unmarshalling json: %v", err)
+	}
+
+	// Test that the subcategories are children of their parent
+	for _, category := range categories {
+		assert.Nil(t, category.ParentID)
+
+		if category.Name == "Furniture" {
+			var children []string
+			for _, child := range category.Children {
+				assert.Equal(t, category.CategoryID, *child.ParentID)
+				children = append(children, child.Name)
+			}
+
+			assert.Equal(t, []string{"Beds", "Sofas"}, children)
+		}
+	}
+}
+
+func TestProductCategories(t *testing.T) {
+	user := createTestUser(t)
+	endpoint := "/users/" + strconv.Itoa(user.UserID) + "/products"
+
+	// Test creating a product with a category that does not exist
+	reqBody := `{"name": "Uncategorized", "service": false, "price": 1, "category_id": 99999}`
+	expectedHTTPStatusCode := http.StatusBadRequest
+	authReqTester(t, user.UserID, post, endpoint, reqBody, expectedHTTPStatusCode)
+
+	// Test updating a product to a category that does not exist
+	expectedHTTPStatusCode = http.StatusBadRequest
+	authReqTester(t, 1, put, "/products/1", `{"name": "Soffa", "service": true, "price": 1, "category_id": 99999}`, expectedHTTPStatusCode)
+
+	// Test that filtering on a category includes its subcategories
+	bodyBytes := reqTester(t, get, "/products?category_id=1&limit=100", "", http.StatusOK)
+
+	var page ProductPage
+
+	err := json.Unmarshal(bodyBytes, &page)
+	if err != nil {
+		t.Errorf("Error unmarshalling json: %v", err)
+	}
+
+	var names []string
+	for _, product := range page.Products {
+		names = append(names, product.Name)
+	}
+
+	assert.Subset(t, names, []string{"Soffa", "Couch", "Bed"})
+	assert.NotContains(t, names, "Car")
+
+	expectedHTTPStatusCode = http.StatusBadRequest
+	reqTester(t, get, "/products?category_id=furniture", "", expectedHTTPStatusCode)
+}
+
+func TestAdminCreateCategory(t *testing.T) {
+	admin := createTestAdmin(t)
+
+	// Test creating a subcategory
+	endpoint := "/admin/categories"
+	reqBody := `{"name": "Test armchairs ` + strconv.Itoa(admin.UserID) + `", "parent_id": 1}`
+	expectedHTTPStatusCode := http.StatusCreated
+	bodyBytes := authReqTester(t, admin.UserID, post, endpoint, reqBody, expectedHTTPStatusCode)
+
+	var category Category
+
+	err := json.Unmarshal(bodyBytes, &category)
+	if err != nil {
+		t.Fatalf("Error unmarshalling json: %v", err)
+	}
+
+	t.Cleanup(func() {
+		_, err := dbPool.Exec(context.Background(), "DELETE FROM Category WHERE category_id = $1", category.CategoryID)
+		if err != nil {
+			fmt.Println("Notice: the created test category could not be deleted.", err)
+		}
+	})
+
+	if assert.NotNil(t, category.ParentID) {
+		assert.Equal(t, 1, *category.ParentID)
+	}
+
+	// Test creating a category that exists with another case
+	reqBody = `{"name": "FURNITURE"}`
+	expectedHTTPStatusCode = http.StatusConflict
+	authReqTester(t, admin.UserID, post, endpoint, reqBody, expectedHTTPStatusCode)
+
+	reqBody = `{"name": "TEST ARMCHAIRS ` + strconv.Itoa(admin.UserID) + `", "parent_id": 1}`
+	authReqTester(t, admin.UserID, post, endpoint, reqBody, expectedHTTPStatusCode)
+
+	// Test creating a category with the name of a subcategory of another parent
+	reqBody = `{"name": "Test armchairs ` + strconv.Itoa(admin.UserID) + `", "parent_id": 2}`
+	expectedHTTPStatusCode = http.StatusCreated
+	bodyBytes = authReqTester(t, admin.UserID, post, endpoint, reqBody, expectedHTTPStatusCode)
+
+	var sibling Category
+
+	err = json.Unmarshal(bodyBytes, &sibling)
+	if err != nil {
+		t.Fatalf("Error unmarshalling json: %v", err)
+	}
+
+	t.Cleanup(func() {
+		_, err := dbPool.Exec(context.Background(), "DELETE FROM Category WHERE category_id = $1", sibling.CategoryID)
+		if err != nil {
+			fmt.Println("Notice: the created test category could not be deleted.", err)
+		}
+	})
+
+	// Test creating a category with a parent that does not exist
+	reqBody = `{"name": "Orphans", "parent_id": 99999}`
+	expectedHTTPStatusCode = http.StatusBadRequest
+	authReqTester(t, admin.UserID, post, endpoint, reqBody, expectedHTTPStatusCode)
+}
+
+// pictureURLs is the JSON encoding of an Image.
+type pictureURLs struct {
+	URL          string `json:"url"`
+	ThumbnailURL string `json:"thumbnail_url"`
+}
+
+// testImage returns a PNG image of the given size, with a red left half and a blue right half.
+func testImage(t *testing.T, width int, height int) []byte {
+	t.Helper()
+
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			img.Set(x, y, color.RGBA{R: 255, A: 255})
+
+			if x >= width/2 {
+				img.Set(x, y, color.RGBA{B: 255, A: 255})
+			}
+		}
+	}
+
+	var buf bytes.Buffer
+
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatalf("Error encoding test image: %v", err)
+	}
+
+	return buf.Bytes()
+}
+
+// uploadTester is a helper function for testing image uploads in the given form field as the user with the given ID
+func uploadTester(t *testing.T, userID int, httpMethod string, endpoint string, field string, picture []byte, expectedHTTPStatusCode int) []byte {
+	t.Helper()
+
+	var body bytes.Buffer
+
+	form := multipart.NewWriter(&body)
+
+	part, err := form.CreateFormFile(field, "picture.png")
+	if err == nil {
+		_, err = part.Write(picture)
+	}
+
+	if err == nil {
+		err = form.Close()
+	}
+
+	if err != nil {
+		t.Fatalf("Error creating multipart form: %v", err)
+	}
+
+	token, err := createAccessToken(userID)
+	if err != nil {
+		t.Fatalf("Error creating token: %v", err)
+	}
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(httpMethod, endpoint, &body)
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	req.Header.Set("Authorization", "Bearer "+token)
+	router.ServeHTTP(w, req)
+
+	if !assert.Equal(t, expectedHTTPStatusCode, w.Code) {
+		fmt.Println("Returned response: " + w.Body.String())
+	}
+
+	return w.Body.Bytes()
+}
+
+// getPicture returns the picture of the user or product at endpoint, which is nil if it has none.
+func getPicture(t *testing.T, endpoint string) *pictureURLs {
+	t.Helper()
+
+	var body struct {
+		Picture *pictureURLs `json:"picture"`
+	}
+
+	err := json.Unmarshal(reqTester(t, get, endpoint, "", http.StatusOK), &body)
+	if err != nil {
+		t.Fatalf("Error unmarshalling json: %v", err)
+	}
+
+	return body.Picture
+}
+
+func TestUserPicture(t *testing.T) {
+	// The picture of the seed data is moved to the blob store
+	assert.NotNil(t, getPicture(t, "/users/1"))
+
+	user := createTestUser(t)
+	endpoint := "/users/" + strconv.Itoa(user.UserID) + "/picture"
+
+	assert.Nil(t, getPicture(t, "/users/"+strconv.Itoa(user.UserID)))
+
+	// Test uploading a picture
+	bodyBytes := uploadTester(t, user.UserID, put, endpoint, "picture", testImage(t, 1000, 500), http.StatusOK)
+
+	var body struct {
+		Picture pictureURLs `json:"picture"`
+	}
+
+	err := json.Unmarshal(bodyBytes, &body)
+	if err != nil {
+		t.Fatalf("Error unmarshalling json: %v", err)
+	}
+
+	assert.Equal(t, &body.Picture, getPicture(t, "/users/"+strconv.Itoa(user.UserID)))
+
+	// The thumbnail is a scaled down JPEG
+	thumbnail, err := jpeg.Decode(bytes.NewReader(reqTester(t, get, body.Picture.ThumbnailURL, "", http.StatusOK)))
+	if assert.NoError(t, err) {
+		assert.Equal(t, image.Rect(0, 0, thumbnailMaxSize, thumbnailMaxSize/2), thumbnail.Bounds())
+	}
+
+	reqTester(t, get, body.Picture.URL, "", http.StatusOK)
+
+	// Test uploading a new picture, which replaces the old one
+	uploadTester(t, user.UserID, put, endpoint, "picture", testImage(t, 100, 100), http.StatusOK)
+	reqTester(t, get, body.Picture.URL, "", http.StatusNotFound)
+	reqTester(t, get, body.Picture.ThumbnailURL, "", http.StatusNotFound)
+
+	// Test uploading something that is not an image
+	uploadTester(t, user.UserID, put, endpoint, "picture", []byte("not an image"), http.StatusBadRequest)
+
+	// Test uploading a picture for another user
+	uploadTester(t, user.UserID, put, "/users/1/picture", "picture", testImage(t, 100, 100), http.StatusForbidden)
+
+	// Test deleting the picture
+	authReqTester(t, user.UserID, del, endpoint, "", http.StatusNoContent)
+	assert.Nil(t, getPicture(t, "/users/"+strconv.Itoa(user.UserID)))
+}
+
+func TestProductImages(t *testing.T) {
+	user := createTestUser(t)
+
+	reqBody := `{"name": "Test Product", "service": false, "price": 100}`
+	bodyBytes := authReqTester(t, user.UserID, post, "/users/"+strconv.Itoa(user.UserID)+"/products", reqBody, http.StatusCreated)
+
+	var product Product
+
+	err := json.Unmarshal(bodyBytes, &product)
+	if err != nil {
+		t.Fatalf("Error unmarshalling json: %v", err)
+	}
+
+	productEndpoint := "/users/" + strconv.Itoa(user.UserID) + "/products/" + strconv.Itoa(product.ProductID)
+	endpoint := productEndpoint + "/images"
+
+	// Test adding an image to a product owned by another user
+	otherEndpoint := "/users/1/products/" + strconv.Itoa(product.ProductID) + "/images"
+	uploadTester(t, 1, post, otherEndpoint, "image", testImage(t, 100, 100), http.StatusForbidden)
+
+	// Test adding images, which are added last
+	var imageIDs []int
+
+	for _, width := range []int{2000, 200, 300} {
+		bodyBytes = uploadTester(t, user.UserID, post, endpoint, "image", testImage(t, width, 100), http.StatusCreated)
+
+		var image struct {
+			ProductImageID int `json:"product_image_id"`
+			Position       int `json:"position"`
+		}
+
+		err = json.Unmarshal(bodyBytes, &image)
+		if err != nil {
+			t.Fatalf("Error unmarshalling json: %v", err)
+		}
+
+		assert.Equal(t, len(imageIDs), image.Position)
+		imageIDs = append(imageIDs, image.ProductImageID)
+	}
+
+	// getImages returns the IDs of the images of the product in order and the picture, the primary image
+	getImages := func() ([]int, pictureURLs, []pictureURLs) {
+		var body struct {
+			Picture pictureURLs `json:"picture"`
+			Images  []struct {
+				ProductImageID int         `json:"product_image_id"`
+				Image          pictureURLs `json:"image"`
+			} `json:"images"`
+		}
+
+		err := json.Unmarshal(reqTester(t, get, "/products/"+strconv.Itoa(product.ProductID), "", http.StatusOK), &body)
+		if err != nil {
+			t.Fatalf("Error unmarshalling json: %v", err)
+		}
+
+		ids := []int{}
+		images := []pictureURLs{}
+
+		for _, image := range body.Images {
+			ids = append(ids, image.ProductImageID)
+			images = append(images, image.Image)
+		}
+
+		return ids, body.Picture, images
+	}
+
+	ids, picture, images := getImages()
+	assert.Equal(t, imageIDs, ids)
+	assert.Equal(t, images[0], picture)
+
+	// The images are scaled down
+	full, err := jpeg.Decode(bytes.NewReader(reqTester(t, get, images[0].URL, "", http.StatusOK)))
+	if assert.NoError(t, err) {
+		assert.Equal(t, image.Rect(0, 0, imageMaxSize, imageMaxSize/20), full.Bounds())
+	}
+
+	// Test reordering the images, which makes the first the primary image
+	reqBody = fmt.Sprintf(`{"image_ids": [%d, %d, %d]}`, imageIDs[2], imageIDs[0], imageIDs[1])
+	authReqTester(t, user.UserID, put, endpoint, reqBody, http.StatusOK)
+
+	ids, picture, images = getImages()
+	assert.Equal(t, []int{imageIDs[2], imageIDs[0], imageIDs[1]}, ids)
+	assert.Equal(t, images[0], picture)
+
+	// Test reordering without listing all images
+	reqBody = fmt.Sprintf(`{"image_ids": [%d, %d]}`, imageIDs[0], imageIDs[1])
+	authReqTester(t, user.UserID, put, endpoint, reqBody, http.StatusBadRequest)
+
+	// Test deleting the primary image
+	authReqTester(t, user.UserID, del, endpoint+"/"+strconv.Itoa(imageIDs[2]), "", http.StatusNoContent)
+	authReqTester(t, user.UserID, del, endpoint+"/"+strconv.Itoa(imageIDs[2]), "", http.StatusNotFound)
+	reqTester(t, get, images[0].URL, "", http.StatusNotFound)
+
+	ids, picture, images = getImages()
+	assert.Equal(t, []int{imageIDs[0], imageIDs[1]}, ids)
+	assert.Equal(t, images[0], picture)
+
+	// The images are deleted along with the product
+	authReqTester(t, user.UserID, del, productEndpoint, "", http.StatusNoContent)
+	reqTester(t, get, images[0].URL, "", http.StatusNotFound)
+}
+
+func TestProcessImage(t *testing.T) {
+	var buf bytes.Buffer
+
+	photo, err := png.Decode(bytes.NewReader(testImage(t, 400, 200)))
+	if err == nil {
+		err = jpeg.Encode(&buf, photo, nil)
+	}
+
+	if err != nil {
+		t.Fatalf("Error encoding test photo: %v", err)
+	}
+
+	// EXIF data with the orientation of a photo taken with the camera rotated 90° clockwise
+	exif := []byte("Exif\x00\x00MM\x00\x2a\x00\x00\x00\x08\x00\x01" +
+		"\x01\x12\x00\x03\x00\x00\x00\x01\x00\x06\x00\x00" +
+		"\x00\x00\x00\x00")
+	segment := append([]byte{0xFF, 0xE1, 0, byte(len(exif) + 2)}, exif...)
+	data := append(append(append([]byte{}, buf.Bytes()[:2]...), segment...), buf.Bytes()[2:]...)
+
+	assert.Equal(t, 6, exifOrientation(data))
+
+	full, thumbnail, err := processImage(data)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	// The EXIF data is stripped after rotating the image upright
+	assert.False(t, bytes.Contains(full, []byte("Exif")))
+
+	img, err := jpeg.Decode(bytes.NewReader(thumbnail))
+	if assert.NoError(t, err) {
+		assert.Equal(t, image.Rect(0, 0, 160, 320), img.Bounds())
+
+		// The red left half is now the top half
+		r, _, b, _ := img.At(80, 20).RGBA()
+		assert.Greater(t, r, b)
+	}
+
+	_, _, err = processImage([]byte("not an image"))
+	assert.ErrorIs(t, err, errInvalidImage)
+}
+
+func TestPurchaseFlow(t *testing.T) {
+	seller := createTestUser(t)
+	buyer := createTestUser(t)
+	otherBuyer := createTestUser(t)
+
+	reqBody := `{"name": "Test Product", "service": false, "price": 100}`
+	bodyBytes := authReqTester(t, seller.UserID, post, "/users/"+strconv.Itoa(seller.UserID)+"/products", reqBody, http.StatusCreated)
+
+	var product Product
+
+	err := json.Unmarshal(bodyBytes, &product)
+	if err != nil {
+		t.Fatalf("Error unmarshalling json: %v", err)
+	}
+
+	t.Cleanup(func() {
+		_, err := dbPool.Exec(context.Background(), "DELETE FROM Product WHERE product_id = $1", product.ProductID)
+		if err != nil {
+			fmt.Println("Notice: the created test product could not be deleted.", err)
+		}
+	})
+
+	assert.Equal(t, productAvailable, product.State)
+
+	productID := strconv.Itoa(product.ProductID)
+	requestEndpoint := "/products/" + productID + "/requests"
+	sellerEndpoint := "/users/" + strconv.Itoa(seller.UserID) + "/products/" + productID
+
+	// setState changes the state of the product as the seller
+	setState := func(state string, expectedHTTPStatusCode int) Product {
+		var product Product
+
+		bodyBytes := authReqTester(t, seller.UserID, put, sellerEndpoint+"/state", `{"state": "`+state+`"}`, expectedHTTPStatusCode)
+		if expectedHTTPStatusCode == http.StatusOK {
+			if err := json.Unmarshal(bodyBytes, &product); err != nil {
+				t.Fatalf("Error unmarshalling json: %v", err)
+			}
+		}
+
+		return product
+	}
+
+	// Test requesting to buy the product
+	authReqTester(t, seller.UserID, post, requestEndpoint, "", http.StatusBadRequest)
+	authReqTester(t, buyer.UserID, post, requestEndpoint, "", http.StatusCreated)
+	authReqTester(t, buyer.UserID, post, requestEndpoint, "", http.StatusConflict)
+	authReqTester(t, otherBuyer.UserID, post, requestEndpoint, "", http.StatusCreated)
+
+	// Test listing the requests as another user than the seller
+	authReqTester(t, buyer.UserID, get, "/users/"+strconv.Itoa(buyer.UserID)+"/products/"+productID+"/requests", "", http.StatusForbidden)
+
+	var requests []PurchaseRequest
+
+	err = json.Unmarshal(authReqTester(t, seller.UserID, get, sellerEndpoint+"/requests", "", http.StatusOK), &requests)
+	if err != nil {
+		t.Fatalf("Error unmarshalling json: %v", err)
+	}
+
+	assert.Len(t, requests, 2)
+
+	// Test invalid transitions of an available product
+	setState(productSold, http.StatusConflict)
+	setState(productReserved, http.StatusBadRequest)
+
+	// Test accepting a request, which reserves the product and rejects the other requests
+	acceptEndpoint := func(buyerID int) string {
+		return sellerEndpoint + "/requests/" + strconv.Itoa(buyerID) + "/accept"
+	}
+
+	authReqTester(t, seller.UserID, post, acceptEndpoint(seller.UserID), "", http.StatusNotFound)
+	authReqTester(t, seller.UserID, post, acceptEndpoint(buyer.UserID), "", http.StatusOK)
+	authReqTester(t, seller.UserID, post, acceptEndpoint(otherBuyer.UserID), "", http.StatusConflict)
+	authReqTester(t, otherBuyer.UserID, post, requestEndpoint, "", http.StatusConflict)
+
+	err = json.Unmarshal(authReqTester(t, seller.UserID, get, sellerEndpoint+"/requests", "", http.StatusOK), &requests)
+	if err != nil {
+		t.Fatalf("Error unmarshalling json: %v", err)
+	}
+
+	for _, request := range requests {
+		if request.UserID == buyer.UserID {
+			assert.Equal(t, "accepted", request.Status)
+
+			// Test that the price of the product is agreed on
+			if assert.NotNil(t, request.Price) {
+				assert.Equal(t, product.Price, *request.Price)
+			}
+		} else {
+			assert.Equal(t, "rejected", request.Status)
+		}
+	}
+
+	// Test the buyer cancelling, which makes the product available again
+	authReqTester(t, buyer.UserID, del, requestEndpoint, "", http.StatusNoContent)
+	authReqTester(t, buyer.UserID, del, requestEndpoint, "", http.StatusNotFound)
+
+	// Test selling to a buyer whose request was rejected before
+	authReqTester(t, otherBuyer.UserID, post, requestEndpoint, "", http.StatusCreated)
+	authReqTester(t, seller.UserID, post, acceptEndpoint(otherBuyer.UserID), "", http.StatusOK)
+
+	sold := setState(productSold, http.StatusOK)
+	assert.Equal(t, productSold, sold.State)
+
+	if assert.NotNil(t, sold.BuyerID) {
+		assert.Equal(t, otherBuyer.UserID, *sold.BuyerID)
+	}
+
+	// A sold product is sold for good
+	setState(productAvailable, http.StatusConflict)
+	authReqTester(t, otherBuyer.UserID, del, requestEndpoint, "", http.StatusConflict)
+
+	// Test the recorded state changes
+	var changes []ProductStateChange
+
+	err = json.Unmarshal(authReqTester(t, seller.UserID, get, sellerEndpoint+"/state/changes", "", http.StatusOK), &changes)
+	if err != nil {
+		t.Fatalf("Error unmarshalling json: %v", err)
+	}
+
+	transitions := []string{}
+	for _, change := range changes {
+		transitions = append(transitions, change.FromState+"->"+change.ToState)
+	}
+
+	assert.Equal(t, []string{"available->reserved", "reserved->available", "available->reserved", "reserved->sold"}, transitions)
+}
+
+// createTestSale creates a product of the seller and sells it to the buyer. The product and its reviews are deleted
+// when the test finishes.
+func createTestSale(t *testing.T, sellerID int, buyerID int) Product {
+	t.Helper()
+
+	reqBody := `{"name": "Test Product", "service": false, "price": 100}`
+	bodyBytes := authReqTester(t, sellerID, post, "/users/"+strconv.Itoa(sellerID)+"/products", reqBody, http.StatusCreated)
+
+	var product Product
+
+	err := json.Unmarshal(bodyBytes, &product)
+	if err != nil {
+		t.Fatalf("Error unmarshalling json of test product: %v", err)
+	}
+
+	t.Cleanup(func() {
+		_, err := dbPool.Exec(context.Background(), "DELETE FROM Review WHERE fk_product_id = $1", product.ProductID)
+		if err == nil {
+			_, err = dbPool.Exec(context.Background(), "DELETE FROM Product WHERE product_id = $1", product.ProductID)
+		}
+
+		if err != nil {
+			fmt.Println("Notice: the created test product could not be deleted.", err)
+		}
+	})
+
+	productID := strconv.Itoa(product.ProductID)
+	sellerEndpoint := "/users/" + strconv.Itoa(sellerID) + "/products/" + productID
+
+	authReqTester(t, buyerID, post, "/products/"+productID+"/requests", "", http.StatusCreated)
+	authReqTester(t, sellerID, post, sellerEndpoint+"/requests/"+strconv.Itoa(buyerID)+"/accept", "", http.StatusOK)
+	bodyBytes = authReqTester(t, sellerID, put, sellerEndpoint+"/state", `{"state": "sold"}`, http.StatusOK)
+
+	err = json.Unmarshal(bodyBytes, &product)
+	if err != nil {
+		t.Fatalf("Error unmarshalling json of test product: %v", err)
+	}
+
+	return product
+}
+
+func TestWithdrawProduct(t *testing.T) {
+	seller := createTestUser(t)
+	buyer := createTestUser(t)
+	userID := strconv.Itoa(seller.UserID)
+
+	reqBody := `{"name": "Test Product", "service": false, "price": 100}`
+	bodyBytes := authReqTester(t, seller.UserID, post, "/users/"+userID+"/products", reqBody, http.StatusCreated)
+
+	var product Product
+
+	err := json.Unmarshal(bodyBytes, &product)
+	if err != nil {
+		t.Fatalf("Error unmarshalling json: %v", err)
+	}
+
+	t.Cleanup(func() {
+		_, err := dbPool.Exec(context.Background(), "DELETE FROM Product WHERE product_id = $1", product.ProductID)
+		if err != nil {
+			fmt.Println("Notice: the created test product could not be deleted.", err)
+		}
+	})
+
+	productID := strconv.Itoa(product.ProductID)
+	stateEndpoint := "/users/" + userID + "/products/" + productID + "/state"
+
+	authReqTester(t, buyer.UserID, post, "/products/"+productID+"/requests", "", http.StatusCreated)
+	authReqTester(t, seller.UserID, put, stateEndpoint, `{"state": "withdrawn"}`, http.StatusOK)
+
+	// Withdrawn products can not be requested and are only listed when asked for
+	authReqTester(t, buyer.UserID, post, "/products/"+productID+"/requests", "", http.StatusConflict)
+
+	countProducts := func(endpoint string) int {
+		var page ProductPage
+
+		err := json.Unmarshal(reqTester(t, get, endpoint, "", http.StatusOK), &page)
+		if err != nil {
+			t.Fatalf("Error unmarshalling json: %v", err)
+		}
+
+		return len(page.Products)
+	}
+
+	assert.Equal(t, 0, countProducts("/users/"+userID+"/products"))
+	assert.Equal(t, 1, countProducts("/users/"+userID+"/products?state=withdrawn"))
+
+	// Test making the product available again
+	authReqTester(t, seller.UserID, put, stateEndpoint, `{"state": "available"}`, http.StatusOK)
+	assert.Equal(t, 1, countProducts("/users/"+userID+"/products?state=available"))
+	reqTester(t, get, "/users/"+userID+"/products?state=gone", "", http.StatusBadRequest)
+
+	// Test that a product can only be sold to the buyer it is reserved for
+	_, err = dbPool.Exec(context.Background(), "UPDATE Product SET state = 'reserved' WHERE product_id = $1", product.ProductID)
+	if err != nil {
+		t.Fatalf("Error reserving product: %v", err)
+	}
+
+	authReqTester(t, seller.UserID, put, stateEndpoint, `{"state": "sold"}`, http.StatusConflict)
+}
+
+func TestOffers(t *testing.T) {
+	seller := createTestUser(t)
+	buyer := createTestUser(t)
+	otherBuyer := createTestUser(t)
+
+	reqBody := `{"name": "Test Product", "service": false, "price": 100}`
+	bodyBytes := authReqTester(t, seller.UserID, post, "/users/"+strconv.Itoa(seller.UserID)+"/products", reqBody, http.StatusCreated)
+
+	var product Product
+
+	err := json.Unmarshal(bodyBytes, &product)
+	if err != nil {
+		t.Fatalf("Error unmarshalling json: %v", err)
+	}
+
+	t.Cleanup(func() {
+		_, err := dbPool.Exec(context.Background(), "DELETE FROM Product WHERE product_id = $1", product.ProductID)
+		if err != nil {
+			fmt.Println("Notice: the created test product could not be deleted.", err)
+		}
+	})
+
+	offersEndpoint := "/products/" + strconv.Itoa(product.ProductID) + "/offers"
+
+	// makeOffer makes an offer as the given user and returns it
+	makeOffer := func(userID int, endpoint string, price int, expectedHTTPStatusCode int) Offer {
+		var offer Offer
+
+		bodyBytes := authReqTester(t, userID, post, endpoint, `{"price": `+strconv.Itoa(price)+`}`, expectedHTTPStatusCode)
+		if expectedHTTPStatusCode == http.StatusCreated {
+			if err := json.Unmarshal(bodyBytes, &offer); err != nil {
+				t.Fatalf("Error unmarshalling json: %v", err)
+			}
+		}
+
+		return offer
+	}
+
+	// Test making offers
+	makeOffer(seller.UserID, offersEndpoint, 80, http.StatusBadRequest)
+	authReqTester(t, buyer.UserID, post, offersEndpoint, `{"price": -1}`, http.StatusBadRequest)
+
+	offer := makeOffer(buyer.UserID, offersEndpoint, 70, http.StatusCreated)
+	assert.Equal(t, "pending", offer.Status)
+	assert.Equal(t, buyer.UserID, offer.ProposerID)
+
+	makeOffer(buyer.UserID, offersEndpoint, 75, http.StatusConflict)
+	otherOffer := makeOffer(otherBuyer.UserID, offersEndpoint, 60, http.StatusCreated)
+
+	offerEndpoint := func(offer Offer) string {
+		return offersEndpoint + "/" + strconv.Itoa(offer.OfferID)
+	}
+
+	// Test countering, after which the buyer responds to the counteroffer
+	authReqTester(t, buyer.UserID, post, offerEndpoint(offer)+"/accept", "", http.StatusForbidden)
+	authReqTester(t, otherBuyer.UserID, post, offerEndpoint(offer)+"/accept", "", http.StatusNotFound)
+
+	counter := makeOffer(seller.UserID, offerEndpoint(offer)+"/counter", 90, http.StatusCreated)
+	assert.Equal(t, seller.UserID, counter.ProposerID)
+	assert.Equal(t, buyer.UserID, counter.BuyerID)
+
+	authReqTester(t, seller.UserID, post, offerEndpoint(offer)+"/reject", "", http.StatusForbidden)
+	authReqTester(t, seller.UserID, post, offerEndpoint(counter)+"/accept", "", http.StatusForbidden)
+
+	counter = makeOffer(buyer.UserID, offerEndpoint(counter)+"/counter", 85, http.StatusCreated)
+	authReqTester(t, seller.UserID, post, offerEndpoint(counter)+"/accept", "", http.StatusOK)
+	authReqTester(t, seller.UserID, post, offerEndpoint(counter)+"/reject", "", http.StatusConflict)
+
+	// Accepting reserves the product for the buyer and rejects the other offers
+	var reserved Product
+
+	err = json.Unmarshal(reqTester(t, get, "/products/"+strconv.Itoa(product.ProductID), "", http.StatusOK), &reserved)
+	if err != nil {
+		t.Fatalf("Error unmarshalling json: %v", err)
+	}
+
+	assert.Equal(t, productReserved, reserved.State)
+	makeOffer(otherBuyer.UserID, offersEndpoint, 100, http.StatusConflict)
+
+	// Test that the offered price is agreed on
+	var requests []PurchaseRequest
+
+	sellerEndpoint := "/users/" + strconv.Itoa(seller.UserID) + "/products/" + strconv.Itoa(product.ProductID)
+
+	err = json.Unmarshal(authReqTester(t, seller.UserID, get, sellerEndpoint+"/requests", "", http.StatusOK), &requests)
+	if err != nil {
+		t.Fatalf("Error unmarshalling json: %v", err)
+	}
+
+	if assert.Len(t, requests, 1) {
+		assert.Equal(t, buyer.UserID, requests[0].UserID)
+		assert.Equal(t, "accepted", requests[0].Status)
+
+		if assert.NotNil(t, requests[0].Price) {
+			assert.Equal(t, 85, *requests[0].Price)
+		}
+	}
+
+	// Test the negotiation history
+	var offers []Offer
+
+	err = json.Unmarshal(authReqTester(t, buyer.UserID, get, offersEndpoint, "", http.StatusOK), &offers)
+	if err != nil {
+		t.Fatalf("Error unmarshalling json: %v", err)
+	}
+
+	statuses := []string{}
+	for _, offer := range offers {
+		statuses = append(statuses, offer.Status)
+	}
+
+	assert.Equal(t, []string{"countered", "countered", "accepted"}, statuses)
+
+	err = json.Unmarshal(authReqTester(t, seller.UserID, get, offersEndpoint+"?buyer_id="+strconv.Itoa(otherBuyer.UserID), "", http.StatusOK), &offers)
+	if err != nil {
+		t.Fatalf("Error unmarshalling json: %v", err)
+	}
+
+	if assert.Len(t, offers, 1) {
+		assert.Equal(t, otherOffer.OfferID, offers[0].OfferID)
+		assert.Equal(t, "rejected", offers[0].Status)
+	}
+}
+
+func TestReviewSummary(t *testing.T) {
+	seller := createTestUser(t)
+	userID := strconv.Itoa(seller.UserID)
+
+	// getSummary returns the review summary of the seller
+	getSummary := func() ReviewSummary {
+		var summary ReviewSummary
+
+		err := json.Unmarshal(reqTester(t, get, "/users/"+userID+"/reviews/summary", "", http.StatusOK), &summary)
+		if err != nil {
+			t.Fatalf("Error unmarshalling json: %v", err)
+		}
+
+		return summary
+	}
+
+	summary := getSummary()
+	assert.Nil(t, summary.Average)
+	assert.Equal(t, 0, summary.Count)
+	assert.Equal(t, map[int]int{1: 0, 2: 0, 3: 0, 4: 0, 5: 0}, summary.Histogram)
+
+	// Test the summary and the rating of the seller after reviews of two sales
+	for _, rating := range []int{4, 5} {
+		buyer := createTestUser(t)
+		product := createTestSale(t, seller.UserID, buyer.UserID)
+
+		reqBody := fmt.Sprintf(`{"rating": %d, "reviewer_id": %d, "product_id": %d}`, rating, buyer.UserID, product.ProductID)
+		authReqTester(t, buyer.UserID, post, "/users/"+userID+"/reviews", reqBody, http.StatusCreated)
+	}
+
+	summary = getSummary()
+	if assert.NotNil(t, summary.Average) {
+		assert.InDelta(t, 4.5, *summary.Average, 0.001)
+	}
+
+	assert.Equal(t, 2, summary.Count)
+	assert.Equal(t, map[int]int{1: 0, 2: 0, 3: 0, 4: 1, 5: 1}, summary.Histogram)
+
+	var user User
+
+	err := json.Unmarshal(reqTester(t, get, "/users/"+userID, "", http.StatusOK), &user)
+	if err != nil {
+		t.Fatalf("Error unmarshalling json: %v", err)
+	}
+
+	if assert.NotNil(t, user.Rating) {
+		assert.InDelta(t, 4.5, *user.Rating, 0.001)
+	}
+
+	// Test with invalid user ID
+	reqTester(t, get, "/users/99999/reviews/summary", "", http.StatusNotFound)
+}
+
+func TestEditReview(t *testing.T) {
+	seller := createTestUser(t)
+	buyer := createTestUser(t)
+	product := createTestSale(t, seller.UserID, buyer.UserID)
+	reviewsEndpoint := "/users/" + strconv.Itoa(seller.UserID) + "/reviews"
+
+	reqBody := fmt.Sprintf(`{"rating": 2, "content": "Slow", "reviewer_id": %d, "product_id": %d}`, buyer.UserID, product.ProductID)
+	bodyBytes := authReqTester(t, buyer.UserID, post, reviewsEndpoint, reqBody, http.StatusCreated)
+
+	var review Review
+
+	err := json.Unmarshal(bodyBytes, &review)
+	if err != nil {
+		t.Fatalf("Error unmarshalling json: %v", err)
+	}
+
+	assert.False(t, review.Edited)
+
+	reviewEndpoint := reviewsEndpoint + "/" + strconv.Itoa(review.ReviewID)
+
+	// Test editing the review, which only the author can
+	authReqTester(t, seller.UserID, put, reviewEndpoint, `{"rating": 5}`, http.StatusForbidden)
+	authReqTester(t, buyer.UserID, put, reviewEndpoint, `{"rating": 6}`, http.StatusBadRequest)
+	authReqTester(t, buyer.UserID, put, "/users/"+strconv.Itoa(buyer.UserID)+"/reviews/"+strconv.Itoa(review.ReviewID), `{"rating": 4}`, http.StatusNotFound)
+
+	bodyBytes = authReqTester(t, buyer.UserID, put, reviewEndpoint, `{"rating": 4, "content": "Slow, but friendly"}`, http.StatusOK)
+
+	err = json.Unmarshal(bodyBytes, &review)
+	if err != nil {
+		t.Fatalf("Error unmarshalling json: %v", err)
+	}
+
+	assert.Equal(t, 4, review.Rating)
+	assert.True(t, review.Edited)
+	assert.NotNil(t, review.EditedAt)
+
+	var summary ReviewSummary
+
+	err = json.Unmarshal(reqTester(t, get, reviewsEndpoint+"/summary", "", http.StatusOK), &summary)
+	if err != nil {
+		t.Fatalf("Error unmarshalling json: %v", err)
+	}
+
+	if assert.NotNil(t, summary.Average) {
+		assert.InDelta(t, 4, *summary.Average, 0.001)
+	}
+
+	// Test replying to the review, which only the reviewed user can, once
+	var reply ReviewReply
+
+	authReqTester(t, buyer.UserID, put, reviewEndpoint+"/reply", `{"content": "Thanks"}`, http.StatusForbidden)
+	authReqTester(t, seller.UserID, put, reviewEndpoint+"/reply", `{}`, http.StatusBadRequest)
+	authReqTester(t, seller.UserID, put, reviewEndpoint+"/reply", `{"content": "Thanks"}`, http.StatusOK)
+
+	err = json.Unmarshal(authReqTester(t, seller.UserID, put, reviewEndpoint+"/reply", `{"content": "Thank you"}`, http.StatusOK), &reply)
+	if err != nil {
+		t.Fatalf("Error unmarshalling json: %v", err)
+	}
+
+	assert.True(t, reply.Edited)
+
+	var reviews []Review
+
+	err = json.Unmarshal(reqTester(t, get, reviewsEndpoint, "", http.StatusOK), &reviews)
+	if err != nil {
+		t.Fatalf("Error unmarshalling json: %v", err)
+	}
+
+	if assert.Len(t, reviews, 1) && assert.NotNil(t, reviews[0].Reply) {
+		assert.Equal(t, "Thank you", reviews[0].Reply.Content)
+	}
+
+	authReqTester(t, seller.UserID, del, reviewEndpoint+"/reply", "", http.StatusNoContent)
+	authReqTester(t, seller.UserID, del, reviewEndpoint+"/reply", "", http.StatusNotFound)
+
+	// Test deleting the review, which leaves the seller without a rating
+	authReqTester(t, seller.UserID, del, reviewEndpoint, "", http.StatusForbidden)
+	authReqTester(t, buyer.UserID, del, reviewEndpoint, "", http.StatusNoContent)
+	authReqTester(t, buyer.UserID, del, reviewEndpoint, "", http.StatusNotFound)
+
+	var user User
+
+	err = json.Unmarshal(reqTester(t, get, "/users/"+strconv.Itoa(seller.UserID), "", http.StatusOK), &user)
+	if err != nil {
+		t.Fatalf("Error unmarshalling json: %v", err)
+	}
+
+	assert.Nil(t, user.Rating)
+}
+
+func TestCommunityFeed(t *testing.T) {
+	user := createTestUser(t)
+	outsider := createTestUser(t)
+	userID := strconv.Itoa(user.UserID)
+
+	t.Cleanup(func() {
+		_, err := dbPool.Exec(context.Background(), "DELETE FROM User_Community WHERE fk_user_id = $1", user.UserID)
+		if err != nil {
+			fmt.Println("Notice: the test user could not leave the community.", err)
+		}
+	})
+
+	authReqTester(t, user.UserID, post, "/users/"+userID+"/communities", `{"community_id": 3}`, http.StatusCreated)
+
+	// Test posting, which only members can
+	postsEndpoint := "/communities/3/posts"
+	reqBody := `{"title": "Test Post", "content": "Test content"}`
+
+	authReqTester(t, outsider.UserID, post, postsEndpoint, reqBody, http.StatusForbidden)
+	authReqTester(t, user.UserID, post, postsEndpoint, `{"content": "No title"}`, http.StatusBadRequest)
+	authReqTester(t, user.UserID, post, "/communities/99999/posts", reqBody, http.StatusNotFound)
+
+	var testPost Post
+
+	err := json.Unmarshal(authReqTester(t, user.UserID, post, postsEndpoint, reqBody, http.StatusCreated), &testPost)
+	if err != nil {
+		t.Fatalf("Error unmarshalling json: %v", err)
+	}
+
+	postEndpoint := postsEndpoint + "/" + strconv.Itoa(testPost.PostID)
+	reqTester(t, get, "/communities/1/posts/"+strconv.Itoa(testPost.PostID), "", http.StatusNotFound)
+
+	// Test threaded comments
+	var comment Comment
+
+	err = json.Unmarshal(authReqTester(t, user.UserID, post, postEndpoint+"/comments", `{"content": "First"}`, http.StatusCreated), &comment)
+	if err != nil {
+		t.Fatalf("Error unmarshalling json: %v", err)
+	}
+
+	reqBody = `{"content": "Reply", "parent_id": ` + strconv.Itoa(comment.CommentID) + `}`
+	authReqTester(t, user.UserID, post, postEndpoint+"/comments", reqBody, http.StatusCreated)
+	authReqTester(t, user.UserID, post, postEndpoint+"/comments", `{"content": "Reply", "parent_id": 99999}`, http.StatusBadRequest)
+	authReqTester(t, outsider.UserID, post, postEndpoint+"/comments", `{"content": "Hello"}`, http.StatusForbidden)
+
+	var threads []Comment
+
+	err = json.Unmarshal(reqTester(t, get, postEndpoint+"/comments", "", http.StatusOK), &threads)
+	if err != nil {
+		t.Fatalf("Error unmarshalling json: %v", err)
+	}
+
+	if assert.Len(t, threads, 1) && assert.Len(t, threads[0].Replies, 1) {
+		assert.Equal(t, "Reply", threads[0].Replies[0].Content)
+	}
+
+	err = json.Unmarshal(reqTester(t, get, postEndpoint, "", http.StatusOK), &testPost)
+	if err != nil {
+		t.Fatalf("Error unmarshalling json: %v", err)
+	}
+
+	assert.Equal(t, 2, testPost.CommentCount)
+
+	// Test listing a product in the community, which has to be one the seller is a member of
+	reqBody = `{"name": "Test Product", "service": false, "price": 100}`
+	bodyBytes := authReqTester(t, user.UserID, post, "/users/"+userID+"/products", reqBody, http.StatusCreated)
+
+	var product Product
+
+	err = json.Unmarshal(bodyBytes, &product)
+	if err != nil {
+		t.Fatalf("Error unmarshalling json: %v", err)
+	}
+
+	t.Cleanup(func() {
+		_, err := dbPool.Exec(context.Background(), "DELETE FROM Product WHERE product_id = $1", product.ProductID)
+		if err != nil {
+			fmt.Println("Notice: the created test product could not be deleted.", err)
+		}
+	})
+
+	productID := strconv.Itoa(product.ProductID)
+	communitiesEndpoint := "/users/" + userID + "/products/" + productID + "/communities"
+
+	authReqTester(t, user.UserID, put, communitiesEndpoint, `{"community_ids": [1]}`, http.StatusForbidden)
+	authReqTester(t, user.UserID, put, communitiesEndpoint, `{"community_ids": [3]}`, http.StatusOK)
+
+	var communities []Community
+
+	err = json.Unmarshal(reqTester(t, get, "/products/"+productID+"/communities", "", http.StatusOK), &communities)
+	if err != nil {
+		t.Fatalf("Error unmarshalling json: %v", err)
+	}
+
+	if assert.Len(t, communities, 1) {
+		assert.Equal(t, 3, communities[0].CommunityID)
+	}
+
+	// Test the feed, where the product listed after the post comes first
+	var page FeedPage
+
+	err = json.Unmarshal(reqTester(t, get, "/communities/3/feed?limit=1", "", http.StatusOK), &page)
+	if err != nil {
+		t.Fatalf("Error unmarshalling json: %v", err)
+	}
+
+	if assert.Len(t, page.Items, 1) && assert.NotNil(t, page.Items[0].Product) {
+		assert.Equal(t, "product", page.Items[0].Type)
+		assert.Equal(t, product.ProductID, page.Items[0].Product.ProductID)
+	}
+
+	if assert.NotNil(t, page.NextCursor) {
+		err = json.Unmarshal(reqTester(t, get, "/communities/3/feed?limit=1&cursor="+*page.NextCursor, "", http.StatusOK), &page)
+		if err != nil {
+			t.Fatalf("Error unmarshalling json: %v", err)
+		}
+
+		if assert.Len(t, page.Items, 1) && assert.NotNil(t, page.Items[0].Post) {
+			assert.Equal(t, testPost.PostID, page.Items[0].Post.PostID)
+		}
+	}
+
+	reqTester(t, get, "/communities/3/feed?cursor=invalid", "", http.StatusBadRequest)
+
+	// Test deleting the post, which only the author can
+	authReqTester(t, outsider.UserID, del, postEndpoint, "", http.StatusForbidden)
+	authReqTester(t, user.UserID, del, postEndpoint, "", http.StatusNoContent)
+	reqTester(t, get, postEndpoint, "", http.StatusNotFound)
+}
+
+func TestCommunityMembership(t *testing.T) {
+	owner := createTestUser(t)
+	member := createTestUser(t)
+	invitee := createTestUser(t)
+
+	authReqTester(t, owner.UserID, post, "/communities", `{"name": "Test Community", "visibility": "secret"}`, http.StatusBadRequest)
+
+	var community Community
+
+	bodyBytes := authReqTester(t, owner.UserID, post, "/communities", `{"name": "Test Community", "visibility": "invite_only"}`, http.StatusCreated)
+
+	err := json.Unmarshal(bodyBytes, &community)
+	if err != nil {
+		t.Fatalf("Error unmarshalling json: %v", err)
+	}
+
+	t.Cleanup(func() {
+		_, err := dbPool.Exec(context.Background(), "DELETE FROM User_Community WHERE fk_community_id = $1", community.CommunityID)
+		if err == nil {
+			_, err = dbPool.Exec(context.Background(), "DELETE FROM Community WHERE community_id = $1", community.CommunityID)
+		}
+
+		if err != nil {
+			fmt.Println("Notice: the created test community could not be deleted.", err)
+		}
+	})
+
+	if assert.NotNil(t, community.OwnerID) {
+		assert.Equal(t, owner.UserID, *community.OwnerID)
+	}
+
+	communityEndpoint := "/communities/" + strconv.Itoa(community.CommunityID)
+	joinEndpoint := func(user User) string {
+		return "/users/" + strconv.Itoa(user.UserID) + "/communities"
+	}
+	joinBody := `{"community_id": ` + strconv.Itoa(community.CommunityID) + `}`
+
+	// Test joining by a join request approved by a moderator
+	authReqTester(t, member.UserID, post, joinEndpoint(member), joinBody, http.StatusForbidden)
+	authReqTester(t, member.UserID, get, communityEndpoint+"/feed", "", http.StatusForbidden)
+	authReqTester(t, member.UserID, post, communityEndpoint+"/requests", "", http.StatusCreated)
+	authReqTester(t, member.UserID, post, communityEndpoint+"/requests", "", http.StatusConflict)
+	authReqTester(t, member.UserID, get, communityEndpoint+"/requests", "", http.StatusForbidden)
+
+	var requests []JoinRequest
+
+	err = json.Unmarshal(authReqTester(t, owner.UserID, get, communityEndpoint+"/requests", "", http.StatusOK), &requests)
+	if err != nil {
+		t.Fatalf("Error unmarshalling json: %v", err)
+	}
+
+	if assert.Len(t, requests, 1) {
+		assert.Equal(t, member.UserID, requests[0].UserID)
+	}
+
+	approveEndpoint := communityEndpoint + "/requests/" + strconv.Itoa(member.UserID) + "/approve"
+	authReqTester(t, owner.UserID, post, approveEndpoint, "", http.StatusOK)
+	authReqTester(t, owner.UserID, post, approveEndpoint, "", http.StatusNotFound)
+	authReqTester(t, member.UserID, get, communityEndpoint+"/feed", "", http.StatusOK)
+
+	// Test that the community is only listed for a product to its members
+	var product Product
+
+	reqBody := `{"name": "Test Product", "service": false, "price": 100}`
+
+	err = json.Unmarshal(authReqTester(t, member.UserID, post, "/users/"+strconv.Itoa(member.UserID)+"/products", reqBody, http.StatusCreated), &product)
+	if err != nil {
+		t.Fatalf("Error unmarshalling json: %v", err)
+	}
+
+	t.Cleanup(func() {
+		_, err := dbPool.Exec(context.Background(), "DELETE FROM Product WHERE product_id = $1", product.ProductID)
+		if err != nil {
+			fmt.Println("Notice: the created test product could not be deleted.", err)
+		}
+	})
+
+	productEndpoint := "/products/" + strconv.Itoa(product.ProductID)
+	reqBody = `{"community_ids": [` + strconv.Itoa(community.CommunityID) + `]}`
+	authReqTester(t, member.UserID, put, "/users/"+strconv.Itoa(member.UserID)+productEndpoint+"/communities", reqBody, http.StatusOK)
+
+	var communities []Community
+
+	err = json.Unmarshal(authReqTester(t, member.UserID, get, productEndpoint+"/communities", "", http.StatusOK), &communities)
+	if err != nil {
+		t.Fatalf("Error unmarshalling json: %v", err)
+	}
+
+	assert.Len(t, communities, 1)
+
+	err = json.Unmarshal(authReqTester(t, invitee.UserID, get, productEndpoint+"/communities", "", http.StatusOK), &communities)
+	if err != nil {
+		t.Fatalf("Error unmarshalling json: %v", err)
+	}
+
+	assert.Empty(t, communities)
+
+	err = json.Unmarshal(reqTester(t, get, productEndpoint+"/communities", "", http.StatusOK), &communities)
+	if err != nil {
+		t.Fatalf("Error unmarshalling json: %v", err)
+	}
+
+	assert.Empty(t, communities)
+
+	// Test joining with an invite
+	var invite CommunityInvite
+
+	authReqTester(t, member.UserID, post, communityEndpoint+"/invites", "", http.StatusForbidden)
+
+	err = json.Unmarshal(authReqTester(t, owner.UserID, post, communityEndpoint+"/invites", "", http.StatusCreated), &invite)
+	if err != nil {
+		t.Fatalf("Error unmarshalling json: %v", err)
+	}
+
+	inviteBody := `{"community_id": ` + strconv.Itoa(community.CommunityID) + `, "invite_code": "` + invite.Code + `"}`
+	authReqTester(t, invitee.UserID, post, joinEndpoint(invitee), `{"community_id": `+strconv.Itoa(community.CommunityID)+`, "invite_code": "invalid"}`, http.StatusForbidden)
+	authReqTester(t, invitee.UserID, post, joinEndpoint(invitee), inviteBody, http.StatusCreated)
+	authReqTester(t, invitee.UserID, post, joinEndpoint(invitee), inviteBody, http.StatusConflict)
+
+	// Test appointing moderators, which only the owner can
+	moderatorEndpoint := func(user User) string {
+		return communityEndpoint + "/moderators/" + strconv.Itoa(user.UserID)
+	}
+
+	authReqTester(t, member.UserID, put, moderatorEndpoint(invitee), "", http.StatusForbidden)
+	authReqTester(t, owner.UserID, del, moderatorEndpoint(owner), "", http.StatusConflict)
+
+	var membership UserCommunity
+
+	err = json.Unmarshal(authReqTester(t, owner.UserID, put, moderatorEndpoint(member), "", http.StatusOK), &membership)
+	if err != nil {
+		t.Fatalf("Error unmarshalling json: %v", err)
+	}
+
+	assert.Equal(t, "moderator", membership.Role)
+	authReqTester(t, member.UserID, get, communityEndpoint+"/requests", "", http.StatusOK)
+
+	var members []UserCommunity
+
+	err = json.Unmarshal(authReqTester(t, invitee.UserID, get, communityEndpoint+"/members", "", http.StatusOK), &members)
+	if err != nil {
+		t.Fatalf("Error unmarshalling json: %v", err)
+	}
+
+	assert.Len(t, members, 3)
+	reqTester(t, get, communityEndpoint+"/members", "", http.StatusForbidden)
+
+	// Test leaving, which the owner can not
+	authReqTester(t, owner.UserID, del, joinEndpoint(owner)+"/"+strconv.Itoa(community.CommunityID), "", http.StatusConflict)
+	authReqTester(t, invitee.UserID, del, joinEndpoint(invitee)+"/"+strconv.Itoa(community.CommunityID), "", http.StatusNoContent)
+	authReqTester(t, invitee.UserID, del, joinEndpoint(invitee)+"/"+strconv.Itoa(community.CommunityID), "", http.StatusNotFound)
+}
+
+func TestCommunityChannel(t *testing.T) {
+	user := createTestUser(t)
+	userID := strconv.Itoa(user.UserID)
+	channel := communityChannelPrefix + "2"
+
+	allowed := func(channelUUID string) bool {
+		allowed, err := authorizeChannel(userID, channelUUID)
+		if err != nil {
+			t.Fatalf("Error authorizing channel: %v", err)
+		}
+
+		return allowed
+	}
+
+	assert.True(t, allowed("public"))
+	assert.False(t, allowed(channel))
+	assert.False(t, allowed(communityChannelPrefix+"99999"))
+
+	// Test that access follows the membership of the community
+	authReqTester(t, user.UserID, post, "/users/"+userID+"/communities", `{"community_id": 2}`, http.StatusCreated)
+	assert.True(t, allowed(channel))
+
+	authReqTester(t, user.UserID, del, "/users/"+userID+"/communities/2", "", http.StatusNoContent)
+	assert.False(t, allowed(channel))
+}
+
+func TestGroupChats(t *testing.T) {
+	owner := createTestUser(t)
+	member := createTestUser(t)
+	other := createTestUser(t)
+
+	chatsEndpoint := func(user User) string {
+		return "/users/" + strconv.Itoa(user.UserID) + "/chats/groups"
+	}
+
+	authReqTester(t, owner.UserID, post, chatsEndpoint(owner), `{"member_ids": [1]}`, http.StatusBadRequest)
+	authReqTester(t, owner.UserID, post, chatsEndpoint(owner), `{"title": "Test Chat", "member_ids": [99999]}`, http.StatusNotFound)
+
+	var chat GroupChat
+
+	reqBody := `{"title": "Test Chat", "member_ids": [` + strconv.Itoa(member.UserID) + `]}`
+
+	err := json.Unmarshal(authReqTester(t, owner.UserID, post, chatsEndpoint(owner), reqBody, http.StatusCreated), &chat)
+	if err != nil {
+		t.Fatalf("Error unmarshalling json: %v", err)
+	}
+
+	t.Cleanup(func() {
+		_, err := dbPool.Exec(context.Background(), "DELETE FROM Group_Chat WHERE group_chat_id = $1", chat.GroupChatID)
+		if err != nil {
+			fmt.Println("Notice: the created test chat could not be deleted.", err)
+		}
+	})
+
+	assert.Equal(t, "Test Chat", chat.Title)
+	assert.Len(t, chat.Members, 2)
+
+	chatEndpoint := func(user User) string {
+		return chatsEndpoint(user) + "/" + strconv.Itoa(chat.GroupChatID)
+	}
+
+	var chats []GroupChat
+
+	err = json.Unmarshal(authReqTester(t, member.UserID, get, chatsEndpoint(member), "", http.StatusOK), &chats)
+	if err != nil {
+		t.Fatalf("Error unmarshalling json: %v", err)
+	}
+
+	if assert.Len(t, chats, 1) {
+		assert.Equal(t, chat.GroupChatID, chats[0].GroupChatID)
+	}
+
+	authReqTester(t, other.UserID, get, chatEndpoint(other), "", http.StatusNotFound)
+
+	// Test that only the owner changes the chat
+	authReqTester(t, member.UserID, put, chatEndpoint(member), `{"title": "Renamed"}`, http.StatusForbidden)
+	authReqTester(t, owner.UserID, put, chatEndpoint(owner), `{"title": "Renamed"}`, http.StatusOK)
+
+	memberBody := `{"user_id": ` + strconv.Itoa(other.UserID) + `}`
+	authReqTester(t, member.UserID, post, chatEndpoint(member)+"/members", memberBody, http.StatusForbidden)
+	authReqTester(t, owner.UserID, post, chatEndpoint(owner)+"/members", memberBody, http.StatusCreated)
+	authReqTester(t, owner.UserID, post, chatEndpoint(owner)+"/members", memberBody, http.StatusConflict)
+	authReqTester(t, owner.UserID, post, chatEndpoint(owner)+"/members", `{"user_id": 99999}`, http.StatusNotFound)
+
+	// Test that access to the channel of the chat follows its members
+	allowed, err := authorizeChannel(strconv.Itoa(other.UserID), chat.Channel)
+	if assert.NoError(t, err) {
+		assert.True(t, allowed)
+	}
+
+	memberEndpoint := func(user User) string {
+		return "/members/" + strconv.Itoa(user.UserID)
+	}
+
+	authReqTester(t, member.UserID, del, chatEndpoint(member)+memberEndpoint(other), "", http.StatusForbidden)
+	authReqTester(t, owner.UserID, del, chatEndpoint(owner)+memberEndpoint(owner), "", http.StatusConflict)
+	authReqTester(t, owner.UserID, del, chatEndpoint(owner)+memberEndpoint(other), "", http.StatusNoContent)
+	authReqTester(t, member.UserID, del, chatEndpoint(member)+memberEndpoint(member), "", http.StatusNoContent)
+
+	allowed, err = authorizeChannel(strconv.Itoa(other.UserID), chat.Channel)
+	if assert.NoError(t, err) {
+		assert.False(t, allowed)
+	}
+
+	// Test deleting the chat
+	authReqTester(t, owner.UserID, del, chatEndpoint(owner), "", http.StatusNoContent)
+	authReqTester(t, owner.UserID, get, chatEndpoint(owner), "", http.StatusNotFound)
+}
+
+func TestMessageStore(t *testing.T) {
+	user := createTestUser(t)
+	recipient := createTestUser(t)
+	userID, recipientID := strconv.Itoa(user.UserID), strconv.Itoa(recipient.UserID)
+	store := messageStore{}
+
+	chatsEndpoint := func(userID string) string {
+		return "/users/" + userID + "/chats"
+	}
+
+	// chatters returns the IDs of the users the given user chats with
+	chatters := func(chatter User) []int {
+		var users []User
+
+		bodyBytes := authReqTester(t, chatter.UserID, get, chatsEndpoint(strconv.Itoa(chatter.UserID)), "", http.StatusOK)
+		if err := json.Unmarshal(bodyBytes, &users); err != nil {
+			t.Fatalf("Error unmarshalling json: %v", err)
+		}
+
+		ids := []int{}
+		for _, other := range users {
+			ids = append(ids, other.UserID)
+		}
+
+		return ids
+	}
+
+	// Test that the users are not given a chat until they send a message
+	channelUUID := uuid.NewString()
+
+	chatUUID, err := store.DirectChat(userID, recipientID, channelUUID)
+	if assert.NoError(t, err) {
+		assert.Equal(t, channelUUID, chatUUID)
+	}
+
+	assert.NotContains(t, chatters(user), recipient.UserID)
+
+	allowed, err := authorizeChannel(recipientID, channelUUID)
+	if assert.NoError(t, err) {
+		assert.False(t, allowed)
+	}
+
+	chatUUID, err = store.DirectChat(userID, "99999", uuid.NewString())
+	if assert.NoError(t, err) {
+		assert.Empty(t, chatUUID)
+	}
+
+	// Test that messages can only be stored in chats
+	_, err = saveChatMessage(context.Background(), "not-a-chat", &rediscli.Message{UUID: uuid.NewString()})
+	assert.ErrorIs(t, err, errNotAChat)
+
+	start := time.Now()
+
+	for i := 0; i < 3; i++ {
+		message := &rediscli.Message{
+			UUID:          uuid.NewString(),
+			SenderID:      userID,
+			RecipientUUID: recipientID,
+			Message:       "Message " + strconv.Itoa(i),
+			CreatedAt:     start.Add(time.Duration(i) * time.Second),
+		}
+
+		if err := store.MessageSave(channelUUID, message); err != nil {
+			t.Fatalf("Error storing message: %v", err)
+		}
+
+		// Test that storing a message again does nothing
+		stored, err := saveChatMessage(context.Background(), channelUUID, message)
+		if assert.NoError(t, err) {
+			assert.False(t, stored)
+		}
+	}
+
+	// Test that the first message gave the users a chat with the channel, which they keep
+	chatUUID, err = store.DirectChat(recipientID, userID, uuid.NewString())
+	if assert.NoError(t, err) {
+		assert.Equal(t, channelUUID, chatUUID)
+	}
+
+	assert.Contains(t, chatters(recipient), user.UserID)
+
+	allowed, err = authorizeChannel(recipientID, channelUUID)
+	if assert.NoError(t, err) {
+		assert.True(t, allowed)
+	}
+
+	allowed, err = authorizeChannel("1", channelUUID)
+	if assert.NoError(t, err) {
+		assert.False(t, allowed)
+	}
+
+	// Test that the messages are read from the newest
+	messages, count, err := store.Messages(channelUUID, 1, 10)
+	if err != nil {
+		t.Fatalf("Error reading messages: %v", err)
+	}
+
+	assert.Equal(t, int64(3), count)
+
+	if assert.Len(t, messages, 2) {
+		assert.Equal(t, "Message 1", messages[0].Message)
+		assert.Equal(t, "Message 0", messages[1].Message)
+		assert.Equal(t, userID, messages[0].SenderID)
+	}
+
+	// Test that deleting the chat only hides it from the user, and keeps its messages for the other user
+	authReqTester(t, user.UserID, del, chatsEndpoint(userID)+"/"+recipientID, "", http.StatusNoContent)
+	authReqTester(t, user.UserID, del, chatsEndpoint(userID)+"/"+recipientID, "", http.StatusBadRequest)
+	assert.NotContains(t, chatters(user), recipient.UserID)
+	assert.Contains(t, chatters(recipient), user.UserID)
+
+	_, count, err = store.Messages(channelUUID, 0, 10)
+	if assert.NoError(t, err) {
+		assert.Equal(t, int64(3), count)
+	}
+
+	// Test that a new message shows the chat to the user again
+	message := &rediscli.Message{UUID: uuid.NewString(), SenderID: recipientID, RecipientUUID: userID, Message: "Message 3", CreatedAt: time.Now()}
+	if err := store.MessageSave(channelUUID, message); err != nil {
+		t.Fatalf("Error storing message: %v", err)
+	}
+
+	assert.Contains(t, chatters(user), recipient.UserID)
+
+	// Test that the chat and its messages are deleted once both users have deleted it
+	authReqTester(t, user.UserID, del, chatsEndpoint(userID)+"/"+recipientID, "", http.StatusNoContent)
+
+	_, count, err = store.Messages(channelUUID, 0, 10)
+	if assert.NoError(t, err) {
+		assert.Equal(t, int64(4), count)
+	}
+
+	authReqTester(t, recipient.UserID, del, chatsEndpoint(recipientID)+"/"+userID, "", http.StatusNoContent)
+
+	_, count, err = store.Messages(channelUUID, 0, 10)
+	if assert.NoError(t, err) {
+		assert.Equal(t, int64(0), count)
+	}
+}
+
+func TestWebsocketSignOut(t *testing.T) {
+	user := createTestUser(t)
+
+	token, err := createAccessToken(user.UserID)
+	if err != nil {
+		t.Fatalf("Error creating token: %v", err)
+	}
+
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	conn, reader, _, err := ws.Dial(context.Background(), "ws"+strings.TrimPrefix(server.URL, "http")+"/ws?token="+token)
+	if err != nil {
+		t.Fatalf("Error dialing websocket: %v", err)
+	}
+	defer conn.Close()
+
+	// The reader holds the data the server sent along with the handshake
+	var socket io.ReadWriter = conn
+	if reader != nil {
+		socket = struct {
+			io.Reader
+			io.Writer
+		}{io.MultiReader(reader, conn), conn}
+	}
+
+	send := func(msg string) {
+		if err := wsutil.WriteClientText(socket, []byte(msg)); err != nil {
+			t.Fatalf("Error writing websocket message: %v", err)
+		}
+	}
+
+	// receive reads the messages of the connection until one of the given type, and reports whether it arrived in time
+	receive := func(dataType message.DataType, timeout time.Duration) bool {
+		if err := conn.SetReadDeadline(time.Now().Add(timeout)); err != nil {
+			t.Fatalf("Error setting read deadline: %v", err)
+		}
+
+		for {
+			data, err := wsutil.ReadServerText(socket)
+			if err != nil {
+				return false
+			}
+
+			msg := &message.Message{}
+			if err := json.Unmarshal(data, msg); err == nil && msg.Type == dataType {
+				return true
+			}
+		}
+	}
+
+	publish := func(text string) {
+		_, err := redisCli.ChannelMessage(&rediscli.Message{
+			UUID:          uuid.NewString(),
+			SenderID:      "0",
+			RecipientUUID: publicChannel,
+			Message:       text,
+			CreatedAt:     time.Now(),
+		})
+		if err != nil {
+			t.Fatalf("Error publishing message: %v", err)
+		}
+	}
+
+	send(`{"type": "channelJoin", "channelJoin": {"recipientUUID": "public"}}`)
+	assert.True(t, receive(message.DataTypeChannelJoin, time.Second*3))
+
+	// Test that the messages of the channel arrive while signed in
+	publish("Before sign out")
+	assert.True(t, receive(message.DataTypeChannelMessage, time.Second*3))
+
+	send(`{"type": "signOut", "signOut": {"uuid": "` + strconv.Itoa(user.UserID) + `"}}`)
+	assert.True(t, receive(message.DataTypeSignOut, time.Second*3))
+
+	// Test that no message of the channel arrives after signing out
+	publish("After sign out")
+	assert.False(t, receive(message.DataTypeChannelMessage, time.Second))
+}
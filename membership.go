@@ -0,0 +1,367 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/georgysavva/scany/pgxscan"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v4"
+)
+
+// Visibilities of a community.
+const (
+	visibilityPublic     = "public"
+	visibilityInviteOnly = "invite_only"
+)
+
+// Roles of the members of a community.
+const (
+	communityMember    = "member"
+	communityModerator = "moderator"
+)
+
+// How long an invite to a community is valid.
+const inviteLifetime = 7 * 24 * time.Hour
+
+// checkIfModerator is a helper function that checks if the user is a moderator of the community.
+func checkIfModerator(c *gin.Context, communityID int, userID int) bool {
+	var moderator bool
+
+	query := "SELECT EXISTS (SELECT 1 FROM User_Community WHERE fk_community_id = $1 AND fk_user_id = $2 AND role = $3)"
+
+	err := dbPool.QueryRow(c, query, communityID, userID, communityModerator).Scan(&moderator)
+	if err != nil {
+		fmt.Println(err)
+		return false
+	}
+
+	return moderator
+}
+
+// checkIfInviteValid is a helper function that checks if code is an invite to the community that has not expired.
+func checkIfInviteValid(c *gin.Context, communityID int, code string) bool {
+	var valid bool
+
+	query := "SELECT EXISTS (SELECT 1 FROM Community_Invite WHERE code = $1 AND fk_community_id = $2 AND expires_at > now())"
+
+	err := dbPool.QueryRow(c, query, code, communityID).Scan(&valid)
+	if err != nil {
+		fmt.Println(err)
+		return false
+	}
+
+	return valid
+}
+
+// getVisibleCommunity returns the community in the community_id parameter like getCommunity, if the logged in user can
+// see its content. The content of invite-only communities is only shown to their members.
+func getVisibleCommunity(c *gin.Context) (Community, bool) {
+	community, ok := getCommunity(c)
+	if ok && community.Visibility == visibilityInviteOnly && !checkIfMember(c, community.CommunityID, authUserID(c)) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Community is invite-only, and only shown to its members"})
+		return community, false
+	}
+
+	return community, ok
+}
+
+// getModeratedCommunity returns the community in the community_id parameter like getCommunity, if the logged in user
+// is a moderator of it or an admin.
+func getModeratedCommunity(c *gin.Context) (Community, bool) {
+	community, ok := getCommunity(c)
+	if ok && !checkIfModerator(c, community.CommunityID, authUserID(c)) && !checkIfAdmin(c, authUserID(c)) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You have to be a moderator of the community"})
+		return community, false
+	}
+
+	return community, ok
+}
+
+// createUserCommunity creates a community owned by the logged in user, who becomes its first moderator.
+func createUserCommunity(c *gin.Context) {
+	var community Community
+
+	if err := c.Bind(&community); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if community.Visibility == "" {
+		community.Visibility = visibilityPublic
+	}
+
+	userID := authUserID(c)
+
+	err := dbPool.BeginFunc(c, func(tx pgx.Tx) error {
+		query := "INSERT INTO Community(name, visibility, fk_owner_id) VALUES($1, $2, $3) RETURNING *"
+		if err := pgxscan.Get(c, tx, &community, query, community.Name, community.Visibility, userID); err != nil {
+			return err
+		}
+
+		query = "INSERT INTO User_Community(fk_user_id, fk_community_id, role) VALUES($1, $2, $3)"
+		_, err := tx.Exec(c, query, userID, community.CommunityID, communityModerator)
+
+		return err
+	})
+
+	if err != nil {
+		fmt.Println(err)
+		c.Status(http.StatusInternalServerError)
+
+		return
+	}
+
+	c.JSON(http.StatusCreated, community)
+}
+
+// leaveCommunity removes the user from a community. The owner of a community can not leave it.
+func leaveCommunity(c *gin.Context) {
+	community, ok := getCommunity(c)
+	if !ok {
+		return
+	}
+
+	userID := authUserID(c)
+
+	if community.OwnerID != nil && *community.OwnerID == userID {
+		c.JSON(http.StatusConflict, gin.H{"error": "The owner can not leave the community"})
+		return
+	}
+
+	query := "DELETE FROM User_Community WHERE fk_user_id = $1 AND fk_community_id = $2"
+
+	result, err := dbPool.Exec(c, query, userID, community.CommunityID)
+	if err != nil {
+		fmt.Println(err)
+		c.Status(http.StatusInternalServerError)
+
+		return
+	}
+
+	if result.RowsAffected() == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "You are not a member of the community"})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// getCommunityMembers returns the members of a community and their roles, moderators first.
+func getCommunityMembers(c *gin.Context) {
+	community, ok := getVisibleCommunity(c)
+	if !ok {
+		return
+	}
+
+	members := []*UserCommunity{}
+
+	query := `SELECT fk_community_id, fk_user_id, role FROM User_Community WHERE fk_community_id = $1
+		ORDER BY role = 'moderator' DESC, fk_user_id`
+
+	err := pgxscan.Select(c, dbPool, &members, query, community.CommunityID)
+	if err != nil {
+		fmt.Println(err)
+		c.Status(http.StatusInternalServerError)
+
+		return
+	}
+
+	c.JSON(http.StatusOK, members)
+}
+
+// setMemberRole sets the role of the member in the user_id parameter. Only the owner appoints and removes moderators,
+// and the owner stays a moderator. Admins act as the owner, so that communities created by admins, which have no
+// owner, can be managed.
+func setMemberRole(c *gin.Context, role string) {
+	community, ok := getCommunity(c)
+	if !ok {
+		return
+	}
+
+	owner := community.OwnerID != nil && *community.OwnerID == authUserID(c)
+	if !owner && !checkIfAdmin(c, authUserID(c)) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only the owner of the community can change its moderators"})
+		return
+	}
+
+	if community.OwnerID != nil && c.Param("user_id") == strconv.Itoa(*community.OwnerID) {
+		c.JSON(http.StatusConflict, gin.H{"error": "The owner is always a moderator"})
+		return
+	}
+
+	var member UserCommunity
+
+	query := `UPDATE User_Community SET role = $3 WHERE fk_community_id = $1 AND fk_user_id = $2
+		RETURNING fk_community_id, fk_user_id, role`
+
+	err := pgxscan.Get(c, dbPool, &member, query, community.CommunityID, c.Param("user_id"), role)
+	if err != nil {
+		if err.Error() == ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": "User is not a member of the community"})
+			return
+		}
+
+		fmt.Println(err)
+		c.Status(http.StatusInternalServerError)
+
+		return
+	}
+
+	c.JSON(http.StatusOK, member)
+}
+
+// addModerator makes a member of a community a moderator.
+func addModerator(c *gin.Context) {
+	setMemberRole(c, communityModerator)
+}
+
+// removeModerator makes a moderator of a community a regular member.
+func removeModerator(c *gin.Context) {
+	setMemberRole(c, communityMember)
+}
+
+// createInvite creates an invite to a community. Anyone with the code can join the community until the invite
+// expires.
+func createInvite(c *gin.Context) {
+	community, ok := getModeratedCommunity(c)
+	if !ok {
+		return
+	}
+
+	var invite CommunityInvite
+
+	query := "INSERT INTO Community_Invite(code, fk_community_id, fk_user_id, expires_at) VALUES($1, $2, $3, $4) RETURNING *"
+
+	err := pgxscan.Get(c, dbPool, &invite, query, uuid.NewString(), community.CommunityID, authUserID(c), time.Now().Add(inviteLifetime))
+	if err != nil {
+		fmt.Println(err)
+		c.Status(http.StatusInternalServerError)
+
+		return
+	}
+
+	c.JSON(http.StatusCreated, invite)
+}
+
+// requestToJoin makes a request from the logged in user to join an invite-only community. A user whose earlier
+// request was rejected can request again.
+func requestToJoin(c *gin.Context) {
+	community, ok := getCommunity(c)
+	if !ok {
+		return
+	}
+
+	userID := authUserID(c)
+
+	switch {
+	case community.Archived:
+		c.JSON(http.StatusConflict, gin.H{"error": "Community is archived"})
+		return
+	case community.Visibility != visibilityInviteOnly:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Community is public, and can be joined directly"})
+		return
+	case checkIfMember(c, community.CommunityID, userID):
+		c.JSON(http.StatusConflict, gin.H{"error": "You are already a member of the community"})
+		return
+	}
+
+	var request JoinRequest
+
+	query := `INSERT INTO Community_Join_Request(fk_community_id, fk_user_id) VALUES($1, $2)
+		ON CONFLICT (fk_community_id, fk_user_id) DO UPDATE SET status = 'pending', created_at = now()
+		WHERE Community_Join_Request.status <> 'pending'
+		RETURNING *`
+
+	err := pgxscan.Get(c, dbPool, &request, query, community.CommunityID, userID)
+	if err != nil {
+		if err.Error() == ErrNoRows {
+			c.JSON(http.StatusConflict, gin.H{"error": "You have already requested to join the community"})
+			return
+		}
+
+		fmt.Println(err)
+		c.Status(http.StatusInternalServerError)
+
+		return
+	}
+
+	c.JSON(http.StatusCreated, request)
+}
+
+// getJoinRequests returns the pending join requests of a community, oldest first.
+func getJoinRequests(c *gin.Context) {
+	community, ok := getModeratedCommunity(c)
+	if !ok {
+		return
+	}
+
+	requests := []*JoinRequest{}
+
+	query := "SELECT * FROM Community_Join_Request WHERE fk_community_id = $1 AND status = 'pending' ORDER BY created_at"
+
+	err := pgxscan.Select(c, dbPool, &requests, query, community.CommunityID)
+	if err != nil {
+		fmt.Println(err)
+		c.Status(http.StatusInternalServerError)
+
+		return
+	}
+
+	c.JSON(http.StatusOK, requests)
+}
+
+// respondToJoinRequest sets the status of the pending join request of the user in the user_id parameter, making the
+// user a member if it is approved.
+func respondToJoinRequest(c *gin.Context, status string) {
+	community, ok := getModeratedCommunity(c)
+	if !ok {
+		return
+	}
+
+	var request JoinRequest
+
+	err := dbPool.BeginFunc(c, func(tx pgx.Tx) error {
+		query := `UPDATE Community_Join_Request SET status = $3 WHERE fk_community_id = $1 AND fk_user_id = $2
+			AND status = 'pending' RETURNING *`
+		if err := pgxscan.Get(c, tx, &request, query, community.CommunityID, c.Param("user_id"), status); err != nil {
+			return err
+		}
+
+		if status != "approved" {
+			return nil
+		}
+
+		query = "INSERT INTO User_Community(fk_user_id, fk_community_id) VALUES($1, $2) ON CONFLICT DO NOTHING"
+		_, err := tx.Exec(c, query, request.UserID, community.CommunityID)
+
+		return err
+	})
+
+	if err != nil {
+		if err.Error() == ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Join request does not exist"})
+			return
+		}
+
+		fmt.Println(err)
+		c.Status(http.StatusInternalServerError)
+
+		return
+	}
+
+	c.JSON(http.StatusOK, request)
+}
+
+// approveJoinRequest approves a join request, which makes the user a member of the community.
+func approveJoinRequest(c *gin.Context) {
+	respondToJoinRequest(c, "approved")
+}
+
+// rejectJoinRequest rejects a join request.
+func rejectJoinRequest(c *gin.Context) {
+	respondToJoinRequest(c, "rejected")
+}
@@ -1,13 +1,137 @@
 package message
 
-import "github.com/VictorAnnell/kandidat-backend/rediscli"
+import (
+	"log"
+	"strconv"
+
+	"github.com/VictorAnnell/kandidat-backend/rediscli"
+)
+
+// Authenticator validates an access token and returns the ID of the user it was issued to.
+type Authenticator func(token string) (string, error)
+
+// RevocationChecker reports whether an access token that was valid has since been revoked. Unlike Authenticator, it
+// does not check that the token has not expired, so that sessions signed in with it last until it is revoked.
+type RevocationChecker func(token string) (bool, error)
+
+// ChannelAuthorizer reports whether the user with the given ID may join, read and post in a channel. It is asked on
+// every use of the channel, so that changes of who may use it take effect at once.
+type ChannelAuthorizer func(userID string, channelUUID string) (bool, error)
+
+// MessageStore stores the messages of channels durably, while Redis only keeps the most recent messages of each
+// channel for joining it, and passes messages on to the users in the channel.
+type MessageStore interface {
+	// MessageSave stores a message sent in the channel.
+	MessageSave(channelUUID string, message *rediscli.Message) error
+	// Messages returns the messages of the channel from the newest, skipping offset messages, along with the number of
+	// messages in the channel.
+	Messages(channelUUID string, offset, limit int64) ([]*rediscli.Message, int64, error)
+	// DirectChat returns the UUID of the chat of two users, which is the UUID of its channel. Users without a chat get
+	// channelUUID, and are given a chat with it when a message is first stored. It returns an empty string if the
+	// recipient is not a user.
+	DirectChat(senderID, recipientID, channelUUID string) (string, error)
+}
 
 type Controller struct {
-	r *rediscli.Redis
+	r                *rediscli.Redis
+	authenticate     Authenticator
+	tokenRevoked     RevocationChecker
+	authorizeChannel ChannelAuthorizer
+	store            MessageStore
 }
 
-func NewController(r *rediscli.Redis) *Controller {
+func NewController(
+	r *rediscli.Redis, authenticate Authenticator, tokenRevoked RevocationChecker, authorizeChannel ChannelAuthorizer,
+	store MessageStore,
+) *Controller {
 	return &Controller{
-		r: r,
+		r:                r,
+		authenticate:     authenticate,
+		tokenRevoked:     tokenRevoked,
+		authorizeChannel: authorizeChannel,
+		store:            store,
+	}
+}
+
+// Authorize validates the access token and returns the user it was issued to.
+func (p Controller) Authorize(token string) (*rediscli.User, error) {
+	userID, err := p.authenticate(token)
+	if err != nil {
+		return nil, err
+	}
+
+	return p.r.UserGet(userID)
+}
+
+// SessionRevoked reports whether the access token a session was signed in with has been revoked, which ends the
+// session. Failures to find out are logged and end the session.
+func (p Controller) SessionRevoked(token string) bool {
+	revoked, err := p.tokenRevoked(token)
+	if err != nil {
+		log.Println(err)
+		return true
+	}
+
+	return revoked
+}
+
+// channelAccess resolves the channel a user addresses by recipientUUID, and returns an error if the user may not use it.
+func (p Controller) channelAccess(userID, recipientUUID string) (string, IError) {
+	channelUUID, err := p.r.GetChannelUUID(userID, recipientUUID)
+	if err != nil {
+		return "", newError(errCodeChannelNotFound, err)
+	}
+
+	// The channel of two users is that of their chat in the store, which Redis is made to agree with
+	if _, err := strconv.ParseInt(recipientUUID, 10, 64); err == nil {
+		chatUUID, err := p.store.DirectChat(userID, recipientUUID, channelUUID)
+		if err != nil {
+			return "", newError(errCodeChannelStore, err)
+		}
+
+		if chatUUID == "" {
+			return "", newError(errCodeChannelNotFound, errNoRecipient)
+		}
+
+		if chatUUID != channelUUID {
+			if err := p.r.SetChannelUUID(userID, recipientUUID, chatUUID); err != nil {
+				return "", newError(errCodeChannelStore, err)
+			}
+
+			channelUUID = chatUUID
+		}
+
+		// The user addresses the other user, so the channel is theirs even before their chat is created
+		return channelUUID, nil
+	}
+
+	if !p.ChannelAllowed(userID, channelUUID) {
+		return "", newError(errCodeChannelForbidden, errChannelForbidden)
+	}
+
+	return channelUUID, nil
+}
+
+// ChannelAllowed reports whether the user may use the channel. Failures to find out are logged and deny access.
+func (p Controller) ChannelAllowed(userID, channelUUID string) bool {
+	allowed, err := p.authorizeChannel(userID, channelUUID)
+	if err != nil {
+		log.Println(err)
+		return false
+	}
+
+	return allowed
+}
+
+// setMessageUsers sets the sender and the recipient of the messages, if they are users.
+func (p Controller) setMessageUsers(messages []*rediscli.Message) {
+	for _, message := range messages {
+		if user, err := p.r.UserGet(message.SenderID); err == nil {
+			message.Sender = &rediscli.User{ID: user.ID, Name: user.Name}
+		}
+
+		if user, err := p.r.UserGet(message.RecipientUUID); err == nil {
+			message.Recipient = &rediscli.User{ID: user.ID, Name: user.Name}
+		}
 	}
 }
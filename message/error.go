@@ -23,6 +23,18 @@ const (
 	errCodeSignOut
 )
 
+// Error codes of channels, matching the HTTP status codes of the same meaning.
+const (
+	errCodeChannelBadRequest uint32 = 400
+	errCodeChannelForbidden  uint32 = 403
+	errCodeChannelNotFound   uint32 = 404
+	errCodeChannelStore      uint32 = 500
+)
+
 var (
-	errUserSetOnline = errors.New("could not set user status as OnLine")
+	errUserSetOnline    = errors.New("could not set user status as OnLine")
+	errUnauthorized     = errors.New("invalid or expired access token")
+	errChannelForbidden = errors.New("not allowed to use the channel")
+	errNegativeOffset   = errors.New("offset can not be negative")
+	errNoRecipient      = errors.New("recipient is not a user")
 )
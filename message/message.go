@@ -21,7 +21,6 @@ const (
 	DataTypeError           DataType = "error"
 	DataTypeUsers           DataType = "users"
 	DataTypeSignIn          DataType = "signIn"
-	DataTypeSignUp          DataType = "signUp"
 	DataTypeSignOut         DataType = "signOut"
 	DataTypeAuthorized      DataType = "authorized"
 	DataTypeUnAuthorized    DataType = "unauthorized"
@@ -44,7 +43,6 @@ type Message struct {
 	Error                 *DataError           `json:"error,omitempty"`
 	Users                 *DataUsers           `json:"users,omitempty"`
 	SignIn                *DataSignIn          `json:"signIn,omitempty"`
-	SignUp                *DataSignUp          `json:"signUp,omitempty"`
 	SignOut               *DataSignOut         `json:"signOut,omitempty"`
 	Authorized            *DataAuthorized      `json:"authorized,omitempty"`
 	ChannelJoin           *DataChannelJoin     `json:"channelJoin,omitempty"`
@@ -106,7 +104,9 @@ func channelSessionsRemove(sessionUUID string) {
 
 type Write func(conn io.ReadWriter, op ws.OpCode, message *Message) error
 
-func channelSessionsSendMessage(skipUserUUID, channelUUID string, write Write, message *Message) {
+// channelSessionsSendMessage sends the message to the sessions that have joined the channel, except those of
+// skipUserUUID and of users no longer allowed to use the channel.
+func (p Controller) channelSessionsSendMessage(skipUserUUID, channelUUID string, write Write, message *Message) {
 	channelSessionsSync.RLock()
 	defer channelSessionsSync.RUnlock()
 
@@ -116,6 +116,10 @@ func channelSessionsSendMessage(skipUserUUID, channelUUID string, write Write, m
 			continue
 		}
 
+		if !p.ChannelAllowed(data.userUUID, channelUUID) {
+			continue
+		}
+
 		log.Println(">>>>>>>>>>>>SEND", skipUserUUID, fmt.Sprintf("%+v", message))
 
 		if err := write(data.conn, ws.OpText, message); err != nil {
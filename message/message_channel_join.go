@@ -15,6 +15,10 @@ type DataChannelJoin struct {
 }
 
 func (p Controller) ChannelJoin(sessionUUID string, conn net.Conn, op ws.OpCode, write Write, message *Message) (*rediscli.ChannelPubSub, IError) {
+	if _, errI := p.channelAccess(message.UserID, message.ChannelJoin.RecipientUUID); errI != nil {
+		return nil, errI
+	}
+
 	errI := p.ChannelLeave(sessionUUID, write, &Message{
 		SUUID:  message.SUUID,
 		Type:   DataTypeChannelLeave,
@@ -58,6 +62,20 @@ func (p Controller) ChannelJoin(sessionUUID string, conn net.Conn, op ws.OpCode,
 		return nil, newError(102, err)
 	}
 
+	// Redis may have lost the recent messages, which are then read from the store, oldest first like in Redis
+	if len(channelMessages) == 0 {
+		channelMessages, _, err = p.store.Messages(channelUUID, 0, 10)
+		if err != nil {
+			return nil, newError(errCodeChannelStore, err)
+		}
+
+		for i, j := 0, len(channelMessages)-1; i < j; i, j = i+1, j-1 {
+			channelMessages[i], channelMessages[j] = channelMessages[j], channelMessages[i]
+		}
+
+		p.setMessageUsers(channelMessages)
+	}
+
 	channelUsers, err := p.r.ChannelUsers(channelUUID)
 	if err != nil {
 		return nil, newError(103, err)
@@ -77,7 +95,7 @@ func (p Controller) ChannelJoin(sessionUUID string, conn net.Conn, op ws.OpCode,
 		return nil, newError(104, err)
 	}
 
-	channelSessionsSendMessage("", channelUUID, write, &Message{
+	p.channelSessionsSendMessage("", channelUUID, write, &Message{
 		Type:   DataTypeSys,
 		SUUID:  sessionUUID,
 		UserID: message.UserID,
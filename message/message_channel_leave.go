@@ -11,7 +11,7 @@ func (p Controller) ChannelLeave(sessionUUID string, writer Write, message *Mess
 		return newError(0, err)
 	}
 
-	channelSessionsSendMessage("", channelUUID, writer, message)
+	p.channelSessionsSendMessage("", channelUUID, writer, message)
 	channelSessionsRemove(sessionUUID)
 
 	return nil
@@ -20,6 +20,11 @@ type DataChannelMessage struct {
 }
 
 func (p Controller) ChannelMessage(sessionUUID string, conn net.Conn, op ws.OpCode, writer Write, message *Message) IError {
+	channelUUID, errI := p.channelAccess(message.UserID, message.ChannelMessage.RecipientUUID)
+	if errI != nil {
+		return errI
+	}
+
 	channelMessage := &rediscli.Message{
 		UUID:          uuid.NewString(),
 		SenderID:      message.UserID,
@@ -28,12 +33,16 @@ func (p Controller) ChannelMessage(sessionUUID string, conn net.Conn, op ws.OpCo
 		CreatedAt:     time.Now(),
 	}
 
-	channelUUID, err := p.r.ChannelMessage(channelMessage)
-	if err != nil {
+	// The message is only sent once it is stored
+	if err := p.store.MessageSave(channelUUID, channelMessage); err != nil {
+		return newError(errCodeChannelStore, err)
+	}
+
+	if _, err := p.r.ChannelMessage(channelMessage); err != nil {
 		return nil
 	}
 
-	channelSessionsSendMessage(message.UserID, channelUUID, writer, message)
+	p.channelSessionsSendMessage(message.UserID, channelUUID, writer, message)
 
 	return nil
 }
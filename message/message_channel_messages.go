@@ -16,26 +16,33 @@ type DataChannelMessages struct {
 	MessagesRecieved int                 `json:"messagesRecieved,omitempty"`
 }
 
+// ChannelMessages sends a page of the messages of a channel, from the newest. Offset is the number of newer messages to
+// skip, and limit the number of messages in the page.
 func (p Controller) ChannelMessages(sessionUUID string, conn net.Conn, op ws.OpCode, writer Write, message *Message) IError {
-	if message.ChannelMessages.Limit == 0 {
+	if message.ChannelMessages.Offset < 0 {
+		return newError(errCodeChannelBadRequest, errNegativeOffset)
+	}
+
+	if message.ChannelMessages.Limit <= 0 {
 		message.ChannelMessages.Limit = 10
 	}
 
-	channelUUID, err := p.r.GetChannelUUID(message.UserID, message.ChannelMessages.RecipientUUID)
-	if err != nil {
-		return newError(404, err)
+	if message.ChannelMessages.Limit > 100 {
+		message.ChannelMessages.Limit = 100
 	}
 
-	channelMessages, err := p.r.ChannelMessages(channelUUID, message.ChannelMessages.Offset, message.ChannelMessages.Limit)
-	if err != nil {
-		return newError(0, err)
+	channelUUID, errI := p.channelAccess(message.UserID, message.ChannelMessages.RecipientUUID)
+	if errI != nil {
+		return errI
 	}
 
-	messagesCount, err := p.r.ChannelMessagesCount(channelUUID)
+	channelMessages, messagesCount, err := p.store.Messages(channelUUID, message.ChannelMessages.Offset, message.ChannelMessages.Limit)
 	if err != nil {
-		return newError(0, err)
+		return newError(errCodeChannelStore, err)
 	}
 
+	p.setMessageUsers(channelMessages)
+
 	err = writer(conn, op, &Message{
 		Type: DataTypeChannelMessages,
 		ChannelMessages: &DataChannelMessages{
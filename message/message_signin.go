@@ -6,28 +6,39 @@ import (
 	"net"
 	"sync"
 
+	"github.com/VictorAnnell/kandidat-backend/rediscli"
 	"github.com/gobwas/ws"
 )
 
 type DataSignIn struct {
 	UUID     string `json:"uuid"`
 	Username string `json:"username"`
-	Password string `json:"password,omitempty"`
+	Token    string `json:"token,omitempty"`
 }
 
 var usersConn = map[string]net.Conn{}
 var usersConnSync = &sync.RWMutex{}
 
-func (p Controller) SignIn(sessionUUID string, conn net.Conn, op ws.OpCode, write Write, message *Message) IError {
-	log.Println("SignIn", sessionUUID, fmt.Sprintf("%+v", message))
+// SignIn authorizes the session with the access token issued by the REST login endpoint.
+func (p Controller) SignIn(sessionUUID string, conn net.Conn, op ws.OpCode, write Write, message *Message) (*rediscli.User, IError) {
+	log.Println("SignIn", sessionUUID)
 
-	user, err := p.r.UserAuthorize(message.SignIn.Username, message.SignIn.Password)
+	user, err := p.Authorize(message.SignIn.Token)
 	if err != nil {
 		log.Println("SignIn", err)
-		return newError(errCodeSignIn, err)
+		return nil, newError(errCodeSignIn, errUnauthorized)
 	}
 
-	err = write(conn, op, &Message{
+	if errI := p.SignedIn(sessionUUID, conn, op, write, user); errI != nil {
+		return nil, errI
+	}
+
+	return user, nil
+}
+
+// SignedIn notifies the session and the other users that the user has signed in.
+func (p Controller) SignedIn(sessionUUID string, conn net.Conn, op ws.OpCode, write Write, user *rediscli.User) IError {
+	err := write(conn, op, &Message{
 		Type: DataTypeAuthorized,
 		Authorized: &DataAuthorized{
 			UserUUID: user.ID,
@@ -40,11 +51,11 @@ func (p Controller) SignIn(sessionUUID string, conn net.Conn, op ws.OpCode, writ
 
 	err = p.r.UserSetOnline(user.ID)
 	if err != nil {
-		log.Println(fmt.Errorf("%s:%w", errUserSetOnline, err), sessionUUID, message)
+		log.Println(fmt.Errorf("%s:%w", errUserSetOnline, err), sessionUUID, user.ID)
 	}
 
 	usersConnSync.Lock()
-	usersConn[message.UserID] = conn
+	usersConn[user.ID] = conn
 
 	for _, conn := range usersConn {
 		err := write(conn, op, p.SysSignIn(user))
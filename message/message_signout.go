@@ -17,6 +17,11 @@ func (p Controller) SignOut(sessionUUID string, conn net.Conn, op ws.OpCode, wri
 	}
 
 	p.r.UserSignOut(message.UserID)
+	channelSessionsRemove(sessionUUID)
+
+	usersConnSync.Lock()
+	delete(usersConn, message.UserID)
+	usersConnSync.Unlock()
 
 	err = write(conn, op, &Message{
 		Type: DataTypeSignOut,
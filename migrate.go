@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/VictorAnnell/kandidat-backend/db"
+)
+
+// migrateDatabase applies the migrations that have not been applied yet, and seeds the database if seedData is set.
+// Pictures stored in the database are then moved to the blob store.
+func migrateDatabase(ctx context.Context) error {
+	migrations, err := db.Up(ctx, dbPool)
+	for _, migration := range migrations {
+		fmt.Printf("Applied migration %d_%s\n", migration.Version, migration.Name)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if seedData {
+		seeded, err := db.Seed(ctx, dbPool)
+		if err != nil {
+			return err
+		}
+
+		if seeded {
+			fmt.Println("Seeded the database")
+		}
+	}
+
+	return moveLegacyPictures(ctx)
+}
+
+// runMigrate runs the migrate command with the given arguments:
+// up applies all new migrations, down [steps] reverts the latest migration or the given number of them and status
+// lists the migrations and whether they are applied.
+func runMigrate(ctx context.Context, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("%w: missing migrate command", errUsage)
+	}
+
+	switch args[0] {
+	case "up":
+		migrations, err := db.Up(ctx, dbPool)
+		for _, migration := range migrations {
+			fmt.Printf("Applied migration %d_%s\n", migration.Version, migration.Name)
+		}
+
+		if err != nil {
+			return err
+		}
+
+		if len(migrations) == 0 {
+			fmt.Println("No migrations to apply")
+		}
+
+		return moveLegacyPictures(ctx)
+	case "down":
+		steps := 1
+
+		if len(args) > 1 {
+			var err error
+
+			steps, err = strconv.Atoi(args[1])
+			if err != nil || steps < 1 {
+				return fmt.Errorf("%w: invalid number of steps %s", errUsage, args[1])
+			}
+		}
+
+		migrations, err := db.Down(ctx, dbPool, steps)
+		for _, migration := range migrations {
+			fmt.Printf("Reverted migration %d_%s\n", migration.Version, migration.Name)
+		}
+
+		return err
+	case "status":
+		statuses, err := db.Status(ctx, dbPool)
+		for _, status := range statuses {
+			applied := "not applied"
+			if status.AppliedAt != nil {
+				applied = "applied " + status.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+
+			fmt.Printf("%04d_%s: %s\n", status.Version, status.Name, applied)
+		}
+
+		return err
+	default:
+		return fmt.Errorf("%w: unknown migrate command %s", errUsage, args[0])
+	}
+}
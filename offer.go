@@ -0,0 +1,239 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/georgysavva/scany/pgxscan"
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v4"
+)
+
+// offerPrice is the request body of offers and counteroffers.
+type offerPrice struct {
+	Price *int `json:"price" binding:"required,min=0"`
+}
+
+// makeOffer makes an offer from the logged in user to buy an available product at the price in the request. The
+// seller then accepts, rejects or counters it.
+func makeOffer(c *gin.Context) {
+	var body offerPrice
+
+	if err := c.Bind(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	productID := c.Param("product_id")
+	userID := authUserID(c)
+
+	var offer Offer
+
+	err := dbPool.BeginFunc(c, func(tx pgx.Tx) error {
+		var ownerID int
+
+		var state string
+
+		query := "SELECT fk_user_id, state FROM Product WHERE product_id = $1 FOR UPDATE"
+		if err := tx.QueryRow(c, query, productID).Scan(&ownerID, &state); err != nil {
+			return err
+		}
+
+		if ownerID == userID {
+			return errOwnProduct
+		}
+
+		if state != productAvailable {
+			return errProductUnavailable
+		}
+
+		var pending bool
+
+		query = "SELECT EXISTS (SELECT 1 FROM Offer WHERE fk_product_id = $1 AND fk_buyer_id = $2 AND status = 'pending')"
+		if err := tx.QueryRow(c, query, productID, userID).Scan(&pending); err != nil {
+			return err
+		}
+
+		if pending {
+			return errPendingOffer
+		}
+
+		query = "INSERT INTO Offer(fk_product_id, fk_buyer_id, fk_proposer_id, price) VALUES($1, $2, $2, $3) RETURNING *"
+
+		return pgxscan.Get(c, tx, &offer, query, productID, userID, body.Price)
+	})
+
+	if err != nil {
+		respondProductStateError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, offer)
+}
+
+// getOffers returns the offers of a product, grouped by buyer and oldest first. The seller sees the offers of all
+// buyers, or those of the buyer given by the buyer_id query parameter, while buyers only see their own.
+func getOffers(c *gin.Context) {
+	productID := c.Param("product_id")
+	userID := authUserID(c)
+
+	var ownerID int
+
+	err := pgxscan.Get(c, dbPool, &ownerID, "SELECT fk_user_id FROM Product WHERE product_id = $1", productID)
+	if err != nil {
+		respondProductStateError(c, err)
+		return
+	}
+
+	buyerID := strconv.Itoa(userID)
+	if ownerID == userID {
+		buyerID = c.Query("buyer_id")
+	}
+
+	offers := []*Offer{}
+
+	query := `SELECT * FROM Offer WHERE fk_product_id = $1 AND ($2 = '' OR fk_buyer_id::text = $2)
+		ORDER BY fk_buyer_id, created_at, offer_id`
+
+	err = pgxscan.Select(c, dbPool, &offers, query, productID, buyerID)
+	if err != nil {
+		fmt.Println(err)
+		c.Status(http.StatusInternalServerError)
+
+		return
+	}
+
+	c.JSON(http.StatusOK, offers)
+}
+
+// lockPendingOffer locks the product and the offer in the URL within tx, and returns the offer if it is pending and
+// the logged in user is the party it was made to.
+func lockPendingOffer(c *gin.Context, tx pgx.Tx) (Offer, error) {
+	var offer Offer
+
+	var ownerID int
+
+	query := "SELECT fk_user_id FROM Product WHERE product_id = $1 FOR UPDATE"
+	if err := tx.QueryRow(c, query, c.Param("product_id")).Scan(&ownerID); err != nil {
+		return offer, err
+	}
+
+	query = "SELECT * FROM Offer WHERE offer_id = $1 AND fk_product_id = $2 FOR UPDATE"
+
+	err := pgxscan.Get(c, tx, &offer, query, c.Param("offer_id"), c.Param("product_id"))
+	if err != nil {
+		if err.Error() == ErrNoRows {
+			return offer, errNoOffer
+		}
+
+		return offer, err
+	}
+
+	userID := authUserID(c)
+
+	switch {
+	case userID != ownerID && userID != offer.BuyerID:
+		return offer, errNoOffer
+	case userID == offer.ProposerID:
+		return offer, errOwnOffer
+	case offer.Status != "pending":
+		return offer, errOfferNotPending
+	}
+
+	return offer, nil
+}
+
+// respondToOffer runs respond in a transaction on the pending offer in the URL, and responds with the offer it
+// returns.
+func respondToOffer(c *gin.Context, status int, respond func(tx pgx.Tx, offer *Offer) error) {
+	var offer Offer
+
+	err := dbPool.BeginFunc(c, func(tx pgx.Tx) error {
+		var err error
+
+		offer, err = lockPendingOffer(c, tx)
+		if err != nil {
+			return err
+		}
+
+		return respond(tx, &offer)
+	})
+
+	if err != nil {
+		respondProductStateError(c, err)
+		return
+	}
+
+	c.JSON(status, offer)
+}
+
+// setOfferStatus sets the status of a pending offer as it is responded to.
+func setOfferStatus(ctx context.Context, tx pgx.Tx, offer *Offer, status string) error {
+	query := "UPDATE Offer SET status = $2, responded_at = now() WHERE offer_id = $1 RETURNING *"
+	return pgxscan.Get(ctx, tx, offer, query, offer.OfferID, status)
+}
+
+// acceptOffer accepts an offer, which reserves the product for the buyer at the offered price. The other pending
+// purchase requests and offers of the product are rejected.
+func acceptOffer(c *gin.Context) {
+	respondToOffer(c, http.StatusOK, func(tx pgx.Tx, offer *Offer) error {
+		if err := setOfferStatus(c, tx, offer, "accepted"); err != nil {
+			return err
+		}
+
+		productID := c.Param("product_id")
+
+		if err := changeProductState(c, tx, productID, productReserved, authUserID(c)); err != nil {
+			return err
+		}
+
+		// The reservation is the accepted purchase request of the buyer, at the offered price
+		query := `INSERT INTO Buying_Product(fk_product_id, fk_user_id, status, price) VALUES($1, $2, 'accepted', $3)
+			ON CONFLICT (fk_product_id, fk_user_id) DO UPDATE SET status = 'accepted', price = $3`
+		if _, err := tx.Exec(c, query, productID, offer.BuyerID, offer.Price); err != nil {
+			return err
+		}
+
+		return rejectPendingBids(c, tx, productID)
+	})
+}
+
+// rejectOffer rejects an offer, which ends the negotiation until the buyer makes a new offer.
+func rejectOffer(c *gin.Context) {
+	respondToOffer(c, http.StatusOK, func(tx pgx.Tx, offer *Offer) error {
+		return setOfferStatus(c, tx, offer, "rejected")
+	})
+}
+
+// counterOffer responds to an offer with a new offer at the price in the request, made to the other party.
+func counterOffer(c *gin.Context) {
+	var body offerPrice
+
+	if err := c.Bind(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	respondToOffer(c, http.StatusCreated, func(tx pgx.Tx, offer *Offer) error {
+		var state string
+
+		query := "SELECT state FROM Product WHERE product_id = $1"
+		if err := tx.QueryRow(c, query, offer.ProductID).Scan(&state); err != nil {
+			return err
+		}
+
+		if state != productAvailable {
+			return errProductUnavailable
+		}
+
+		if err := setOfferStatus(c, tx, offer, "countered"); err != nil {
+			return err
+		}
+
+		query = "INSERT INTO Offer(fk_product_id, fk_buyer_id, fk_proposer_id, price) VALUES($1, $2, $3, $4) RETURNING *"
+
+		return pgxscan.Get(c, tx, offer, query, offer.ProductID, offer.BuyerID, authUserID(c), body.Price)
+	})
+}
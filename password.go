@@ -0,0 +1,142 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/georgysavva/scany/pgxscan"
+	"github.com/gin-gonic/gin"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Rate limits of the password reset endpoints, per phone number.
+const (
+	passwordForgotLimit = 3
+	passwordResetLimit  = 10
+	passwordResetWindow = time.Hour
+	rateLimitKeyForgot  = "passwordForgot"
+	rateLimitKeyReset   = "passwordReset"
+)
+
+// passwordRateLimited counts a request for the phone number and responds with 429 if the limit has been reached.
+func passwordRateLimited(c *gin.Context, key, phoneNumber string, limit int64) bool {
+	limited, retryAfter, err := redisCli.RateLimitHit(key+"."+phoneNumber, limit, passwordResetWindow)
+	if err != nil {
+		fmt.Println(err)
+		c.Status(http.StatusInternalServerError)
+
+		return true
+	}
+
+	if limited {
+		setRetryAfter(c, retryAfter)
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": "Too many requests for this phone number, try again later"})
+
+		return true
+	}
+
+	return false
+}
+
+// forgotPassword sends a password reset code to the phone number if a user has it.
+// The response does not tell whether the phone number belongs to a user.
+func forgotPassword(c *gin.Context) {
+	var body struct {
+		PhoneNumber string `json:"phone_number" binding:"required,e164"`
+	}
+
+	if err := c.Bind(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if passwordRateLimited(c, rateLimitKeyForgot, body.PhoneNumber, passwordForgotLimit) {
+		return
+	}
+
+	var userID int
+
+	query := "SELECT user_id FROM Users WHERE phone_number = $1"
+	err := pgxscan.Get(c, dbPool, &userID, query, body.PhoneNumber)
+
+	if err == nil {
+		_, err = sendVerificationCode(c, codePurposePasswordReset, body.PhoneNumber, "Your password reset code is %s")
+	}
+
+	if err != nil && err.Error() != ErrNoRows {
+		fmt.Println(err)
+		c.Status(http.StatusInternalServerError)
+
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{"phone_number": body.PhoneNumber})
+}
+
+// resetPassword sets a new password for the user with the phone number if the given code is correct.
+// All tokens issued to the user are revoked.
+func resetPassword(c *gin.Context) {
+	var body struct {
+		PhoneNumber string `json:"phone_number" binding:"required,e164"`
+		Code        string `json:"code" binding:"required"`
+		Password    string `json:"password" binding:"required"`
+	}
+
+	if err := c.Bind(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if passwordRateLimited(c, rateLimitKeyReset, body.PhoneNumber, passwordResetLimit) {
+		return
+	}
+
+	valid, err := redisCli.VerificationCodeCheck(codePurposePasswordReset, body.PhoneNumber, body.Code, verificationCodeMaxAttempts)
+	if err != nil {
+		fmt.Println(err)
+		c.Status(http.StatusInternalServerError)
+
+		return
+	}
+
+	if !valid {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Incorrect or expired code"})
+		return
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(body.Password), bcrypt.DefaultCost)
+	if err != nil {
+		fmt.Println(err)
+		c.Status(http.StatusInternalServerError)
+
+		return
+	}
+
+	var userID int
+
+	// The code proves that the user has the phone number, so it is verified as well
+	query := "UPDATE Users SET password = $1, verified = true WHERE phone_number = $2 RETURNING user_id"
+	err = pgxscan.Get(c, dbPool, &userID, query, hashedPassword, body.PhoneNumber)
+
+	if err != nil {
+		if err.Error() == ErrNoRows {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Incorrect or expired code"})
+			return
+		}
+
+		fmt.Println(err)
+		c.Status(http.StatusInternalServerError)
+
+		return
+	}
+
+	if err = revokeUserTokens(userID); err != nil {
+		fmt.Println(err)
+		c.Status(http.StatusInternalServerError)
+
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
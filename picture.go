@@ -0,0 +1,217 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/georgysavva/scany/pgxscan"
+	"github.com/gin-gonic/gin"
+)
+
+// readUploadedImage reads the image uploaded in the given field of a multipart form. It responds with an error and
+// returns false if there is none.
+func readUploadedImage(c *gin.Context, field string) ([]byte, bool) {
+	// Leaves room for the rest of the form
+	maxRequestSize := int64(maxImageUploadSize + 1<<20)
+	if c.Request.ContentLength > maxRequestSize {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": errImageTooLarge.Error()})
+		return nil, false
+	}
+
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxRequestSize)
+
+	header, err := c.FormFile(field)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": field + " must be uploaded as a file in a multipart form"})
+		return nil, false
+	}
+
+	if header.Size > maxImageUploadSize {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": errImageTooLarge.Error()})
+		return nil, false
+	}
+
+	file, err := header.Open()
+	if err != nil {
+		fmt.Println(err)
+		c.Status(http.StatusInternalServerError)
+
+		return nil, false
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(file)
+	if err != nil {
+		fmt.Println(err)
+		c.Status(http.StatusInternalServerError)
+
+		return nil, false
+	}
+
+	return data, true
+}
+
+// setPicture stores the uploaded picture below prefix in the blob store and sets it as the picture of the row of table
+// with the given ID, deleting the picture it replaces. notFound is the error responded with if there is no such row.
+func setPicture(c *gin.Context, table string, idColumn string, id string, prefix string, notFound string) {
+	data, ok := readUploadedImage(c, "picture")
+	if !ok {
+		return
+	}
+
+	picture, err := storeImage(c, prefix, data)
+	if err != nil {
+		if errors.Is(err, errInvalidImage) || errors.Is(err, errImageTooLarge) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		fmt.Println(err)
+		c.Status(http.StatusInternalServerError)
+
+		return
+	}
+
+	// Joining the table with itself returns the picture from before the update
+	query := fmt.Sprintf("UPDATE %[1]s SET picture_key = $2 FROM %[1]s old WHERE %[1]s.%[2]s = $1 AND old.%[2]s = $1 RETURNING old.picture_key", table, idColumn)
+
+	var old *Image
+
+	err = pgxscan.Get(c, dbPool, &old, query, id, string(picture))
+	if err != nil {
+		deleteImages(c, picture)
+
+		if err.Error() == ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": notFound})
+			return
+		}
+
+		fmt.Println(err)
+		c.Status(http.StatusInternalServerError)
+
+		return
+	}
+
+	if old != nil {
+		deleteImages(c, *old)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"picture": picture})
+}
+
+// removePicture removes the picture of the row of table with the given ID.
+func removePicture(c *gin.Context, table string, idColumn string, id string, notFound string) {
+	query := fmt.Sprintf("UPDATE %[1]s SET picture_key = NULL FROM %[1]s old WHERE %[1]s.%[2]s = $1 AND old.%[2]s = $1 RETURNING old.picture_key", table, idColumn)
+
+	var old *Image
+
+	err := pgxscan.Get(c, dbPool, &old, query, id)
+	if err != nil {
+		if err.Error() == ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": notFound})
+			return
+		}
+
+		fmt.Println(err)
+		c.Status(http.StatusInternalServerError)
+
+		return
+	}
+
+	if old != nil {
+		deleteImages(c, *old)
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// uploadUserPicture sets the profile picture of a user to the uploaded image.
+func uploadUserPicture(c *gin.Context) {
+	setPicture(c, "Users", "user_id", c.Param("user_id"), "users", "User does not exist")
+}
+
+// deleteUserPicture removes the profile picture of a user.
+func deleteUserPicture(c *gin.Context) {
+	removePicture(c, "Users", "user_id", c.Param("user_id"), "User does not exist")
+}
+
+// legacyPicture is a picture stored in the database before pictures were stored in the blob store.
+type legacyPicture struct {
+	LegacyPictureID int
+	UserID          *int `db:"fk_user_id"`
+	ProductID       *int `db:"fk_product_id"`
+	Picture         []byte
+}
+
+// moveLegacyPictures moves the pictures in Legacy_Picture to the blob store. The picture of a product becomes its
+// primary image. Pictures that are not valid images are dropped, and so are pictures of users and products that have
+// been given new pictures since.
+func moveLegacyPictures(ctx context.Context) error {
+	moved := 0
+
+	for {
+		var legacy legacyPicture
+
+		query := "SELECT * FROM Legacy_Picture ORDER BY legacy_picture_id LIMIT 1"
+
+		err := pgxscan.Get(ctx, dbPool, &legacy, query)
+		if err != nil {
+			if err.Error() == ErrNoRows {
+				break
+			}
+
+			return err
+		}
+
+		// The pictures were stored base64 encoded by the API, but not by the seed data
+		data := legacy.Picture
+		if decoded, err := base64.StdEncoding.DecodeString(string(data)); err == nil {
+			data = decoded
+		}
+
+		prefix, id := "users", legacy.UserID
+		query = "UPDATE Users SET picture_key = $2 WHERE user_id = $1 AND picture_key IS NULL"
+
+		if legacy.ProductID != nil {
+			prefix, id = "products", legacy.ProductID
+			query = `INSERT INTO Product_Image (fk_product_id, image_key, position)
+				SELECT $1, $2, 0 WHERE NOT EXISTS (SELECT 1 FROM Product_Image WHERE fk_product_id = $1)`
+		}
+
+		picture, err := storeImage(ctx, prefix, data)
+		if err != nil && !errors.Is(err, errInvalidImage) && !errors.Is(err, errImageTooLarge) {
+			return err
+		}
+
+		if err != nil {
+			fmt.Printf("Dropping legacy picture %d: %v\n", legacy.LegacyPictureID, err)
+		} else {
+			result, err := dbPool.Exec(ctx, query, *id, string(picture))
+			if err != nil {
+				deleteImages(ctx, picture)
+				return err
+			}
+
+			if result.RowsAffected() == 0 {
+				deleteImages(ctx, picture)
+			} else {
+				moved++
+			}
+		}
+
+		_, err = dbPool.Exec(ctx, "DELETE FROM Legacy_Picture WHERE legacy_picture_id = $1", legacy.LegacyPictureID)
+		if err != nil {
+			return err
+		}
+	}
+
+	if moved > 0 {
+		fmt.Printf("Moved %d legacy pictures to the blob store\n", moved)
+	}
+
+	return nil
+}
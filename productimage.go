@@ -0,0 +1,267 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+
+	"github.com/georgysavva/scany/pgxscan"
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v4"
+)
+
+// Maximum number of images of a product.
+const maxProductImages = 10
+
+// attachProductImages sets the images of the products, with one query for all of them.
+func attachProductImages(ctx context.Context, products ...*Product) error {
+	productIDs := make([]int, len(products))
+	byID := make(map[int]*Product, len(products))
+
+	for i, product := range products {
+		product.Images = []*ProductImage{}
+		productIDs[i] = product.ProductID
+		byID[product.ProductID] = product
+	}
+
+	if len(products) == 0 {
+		return nil
+	}
+
+	var images []*ProductImage
+
+	query := "SELECT * FROM Product_Image WHERE fk_product_id = ANY($1) ORDER BY fk_product_id, position"
+
+	err := pgxscan.Select(ctx, dbPool, &images, query, productIDs)
+	if err != nil {
+		return err
+	}
+
+	for _, image := range images {
+		product := byID[image.ProductID]
+		product.Images = append(product.Images, image)
+	}
+
+	return nil
+}
+
+// getProductImages returns the images of a product in order.
+func getProductImages(ctx context.Context, q pgxscan.Querier, productID string) ([]*ProductImage, error) {
+	images := []*ProductImage{}
+
+	query := "SELECT * FROM Product_Image WHERE fk_product_id = $1 ORDER BY position"
+	err := pgxscan.Select(ctx, q, &images, query, productID)
+
+	return images, err
+}
+
+// deleteProductWithImages deletes a product along with its images in the blob store. It returns false if the product
+// does not exist.
+func deleteProductWithImages(ctx context.Context, productID string) (bool, error) {
+	var deleted int
+
+	var keys []string
+
+	// The select sees the images from before they are deleted along with the product
+	query := `WITH deleted AS (DELETE FROM Product WHERE product_id = $1 RETURNING product_id)
+		SELECT (SELECT count(*) FROM deleted),
+			coalesce((SELECT array_agg(image_key) FROM Product_Image WHERE fk_product_id IN (SELECT product_id FROM deleted)), '{}')`
+
+	err := dbPool.QueryRow(ctx, query, productID).Scan(&deleted, &keys)
+	if err != nil {
+		return false, err
+	}
+
+	for _, key := range keys {
+		deleteImages(ctx, Image(key))
+	}
+
+	return deleted > 0, nil
+}
+
+// lockProductImages locks the product within tx, so that concurrent changes of its images do not get the same
+// positions.
+func lockProductImages(ctx context.Context, tx pgx.Tx, productID string) error {
+	_, err := tx.Exec(ctx, "SELECT 1 FROM Product WHERE product_id = $1 FOR UPDATE", productID)
+	return err
+}
+
+// addProductImage uploads an image and adds it last among the images of a product.
+func addProductImage(c *gin.Context) {
+	productID := c.Param("product_id")
+
+	data, ok := readUploadedImage(c, "image")
+	if !ok {
+		return
+	}
+
+	image, err := storeImage(c, "products", data)
+	if err != nil {
+		if errors.Is(err, errInvalidImage) || errors.Is(err, errImageTooLarge) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		fmt.Println(err)
+		c.Status(http.StatusInternalServerError)
+
+		return
+	}
+
+	var productImage ProductImage
+
+	tooMany := false
+
+	err = dbPool.BeginFunc(c, func(tx pgx.Tx) error {
+		if err := lockProductImages(c, tx, productID); err != nil {
+			return err
+		}
+
+		var count int
+
+		query := "SELECT count(*) FROM Product_Image WHERE fk_product_id = $1"
+		if err := tx.QueryRow(c, query, productID).Scan(&count); err != nil {
+			return err
+		}
+
+		if count >= maxProductImages {
+			tooMany = true
+			return nil
+		}
+
+		query = "INSERT INTO Product_Image(fk_product_id, image_key, position) VALUES($1, $2, $3) RETURNING *"
+
+		return pgxscan.Get(c, tx, &productImage, query, productID, string(image), count)
+	})
+
+	if err != nil || tooMany {
+		deleteImages(c, image)
+	}
+
+	if err != nil {
+		fmt.Println(err)
+		c.Status(http.StatusInternalServerError)
+
+		return
+	}
+
+	if tooMany {
+		c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("A product can have at most %d images", maxProductImages)})
+		return
+	}
+
+	c.JSON(http.StatusCreated, productImage)
+}
+
+// reorderProductImages orders the images of a product as listed in the request. All images of the product have to be
+// listed, and the first becomes the primary image.
+func reorderProductImages(c *gin.Context) {
+	var body struct {
+		ImageIDs []int `json:"image_ids" binding:"required"`
+	}
+
+	if err := c.Bind(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	productID := c.Param("product_id")
+
+	var images []*ProductImage
+
+	mismatch := false
+
+	err := dbPool.BeginFunc(c, func(tx pgx.Tx) error {
+		if err := lockProductImages(c, tx, productID); err != nil {
+			return err
+		}
+
+		var current []int
+
+		query := "SELECT product_image_id FROM Product_Image WHERE fk_product_id = $1"
+		if err := pgxscan.Select(c, tx, &current, query, productID); err != nil {
+			return err
+		}
+
+		listed := append([]int{}, body.ImageIDs...)
+		sort.Ints(current)
+		sort.Ints(listed)
+
+		if fmt.Sprint(current) != fmt.Sprint(listed) {
+			mismatch = true
+			return nil
+		}
+
+		query = `UPDATE Product_Image SET position = listed.position - 1
+			FROM unnest($2::int[]) WITH ORDINALITY AS listed(product_image_id, position)
+			WHERE Product_Image.product_image_id = listed.product_image_id AND fk_product_id = $1`
+		if _, err := tx.Exec(c, query, productID, body.ImageIDs); err != nil {
+			return err
+		}
+
+		var err error
+
+		images, err = getProductImages(c, tx, productID)
+
+		return err
+	})
+
+	if err != nil {
+		fmt.Println(err)
+		c.Status(http.StatusInternalServerError)
+
+		return
+	}
+
+	if mismatch {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "image_ids must list each image of the product once"})
+		return
+	}
+
+	c.JSON(http.StatusOK, images)
+}
+
+// deleteProductImage deletes an image of a product. The images after it move up one position.
+func deleteProductImage(c *gin.Context) {
+	productID := c.Param("product_id")
+
+	var key string
+
+	err := dbPool.BeginFunc(c, func(tx pgx.Tx) error {
+		if err := lockProductImages(c, tx, productID); err != nil {
+			return err
+		}
+
+		var position int
+
+		query := "DELETE FROM Product_Image WHERE product_image_id = $1 AND fk_product_id = $2 RETURNING image_key, position"
+
+		err := tx.QueryRow(c, query, c.Param("image_id"), productID).Scan(&key, &position)
+		if err != nil {
+			return err
+		}
+
+		query = "UPDATE Product_Image SET position = position - 1 WHERE fk_product_id = $1 AND position > $2"
+		_, err = tx.Exec(c, query, productID, position)
+
+		return err
+	})
+
+	if err != nil {
+		if err.Error() == ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Image does not exist"})
+			return
+		}
+
+		fmt.Println(err)
+		c.Status(http.StatusInternalServerError)
+
+		return
+	}
+
+	deleteImages(c, Image(key))
+
+	c.Status(http.StatusNoContent)
+}
@@ -0,0 +1,283 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/georgysavva/scany/pgxscan"
+	"github.com/gin-gonic/gin"
+)
+
+// Number of products in a page by default and at most.
+const (
+	productPageDefaultLimit = 20
+	productPageMaxLimit     = 100
+)
+
+// ProductPage is a page of a product listing. NextCursor is given as the cursor query parameter to get the next
+// page, and is nil on the last page.
+type ProductPage struct {
+	Products   []*Product `json:"products"`
+	NextCursor *string    `json:"next_cursor"`
+}
+
+// productSort is a sort order of product listings.
+type productSort struct {
+	// Column sorted on, product_id is used to break ties
+	column string
+	// Whether the products are sorted in descending order
+	descending bool
+	// The value of the column for a product, stored in the cursors
+	value func(product *Product) string
+	// The type the value is cast to in queries
+	valueType string
+	// Checks that a value from a cursor is of the type
+	parseValue func(value string) error
+}
+
+func productUploadDate(product *Product) string {
+	return product.UploadDate.Time.Format("2006-01-02")
+}
+
+func productPrice(product *Product) string {
+	return strconv.Itoa(product.Price)
+}
+
+func parseDateValue(value string) error {
+	_, err := time.Parse("2006-01-02", value)
+	return err
+}
+
+func parseIntValue(value string) error {
+	_, err := strconv.ParseInt(value, 10, 32)
+	return err
+}
+
+// Sort orders by the value of the sort query parameter.
+var productSorts = map[string]productSort{
+	"newest": {
+		column: "upload_date", descending: true, value: productUploadDate, valueType: "date", parseValue: parseDateValue,
+	},
+	"oldest":     {column: "upload_date", value: productUploadDate, valueType: "date", parseValue: parseDateValue},
+	"price_asc":  {column: "price", value: productPrice, valueType: "int", parseValue: parseIntValue},
+	"price_desc": {column: "price", descending: true, value: productPrice, valueType: "int", parseValue: parseIntValue},
+}
+
+// productCursor is the position after the last product of a page. It is encoded as base64 JSON, which the clients
+// should treat as opaque.
+type productCursor struct {
+	Sort      string `json:"s"`
+	Value     string `json:"v"`
+	ProductID int    `json:"id"`
+}
+
+func (cursor productCursor) encode() string {
+	data, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeProductCursor(encoded string) (productCursor, error) {
+	var cursor productCursor
+
+	data, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err == nil {
+		err = json.Unmarshal(data, &cursor)
+	}
+
+	return cursor, err
+}
+
+// productQuery builds the WHERE clause of a product listing query.
+type productQuery struct {
+	conditions []string
+	args       []interface{}
+}
+
+// arg adds an argument to the query and returns its placeholder.
+func (q *productQuery) arg(value interface{}) string {
+	q.args = append(q.args, value)
+	return "$" + strconv.Itoa(len(q.args))
+}
+
+// where adds a condition, in which %s is replaced with the placeholder of value.
+func (q *productQuery) where(condition string, value interface{}) {
+	q.conditions = append(q.conditions, fmt.Sprintf(condition, q.arg(value)))
+}
+
+// addFilters adds the conditions given by the filter query parameters of the request.
+func (q *productQuery) addFilters(c *gin.Context) error {
+	if category := c.Query("category_id"); category != "" {
+		categoryID, err := strconv.Atoi(category)
+		if err != nil {
+			return errors.New("category_id must be an integer")
+		}
+
+		// Products in subcategories are in the category as well
+		q.where(`fk_category_id IN (
+			WITH RECURSIVE descendants AS (
+				SELECT category_id FROM Category WHERE category_id = %s
+				UNION
+				SELECT Category.category_id FROM Category JOIN descendants ON Category.fk_parent_id = descendants.category_id
+			)
+			SELECT category_id FROM descendants
+		)`, categoryID)
+	}
+
+	if community := c.Query("community_id"); community != "" {
+		communityID, err := strconv.Atoi(community)
+		if err != nil {
+			return errors.New("community_id must be an integer")
+		}
+
+		q.where("product_id IN (SELECT fk_product_id FROM Product_Community WHERE fk_community_id = %s)", communityID)
+	}
+
+	switch c.Query("type") {
+	case "":
+	case "service":
+		q.conditions = append(q.conditions, "service")
+	case "goods":
+		q.conditions = append(q.conditions, "NOT service")
+	default:
+		return errors.New("type must be service or goods")
+	}
+
+	switch c.Query("sold") {
+	case "":
+	case "true":
+		q.conditions = append(q.conditions, "state = 'sold'")
+	case "false":
+		q.conditions = append(q.conditions, "state <> 'sold'")
+	default:
+		return errors.New("sold must be true or false")
+	}
+
+	// Withdrawn products are only listed when asked for
+	switch state := c.Query("state"); state {
+	case "":
+		q.conditions = append(q.conditions, "state <> 'withdrawn'")
+	case productAvailable, productReserved, productSold, productWithdrawn:
+		q.where("state = %s", state)
+	default:
+		return errors.New("state must be available, reserved, sold or withdrawn")
+	}
+
+	for _, filter := range []struct{ param, condition string }{
+		{"min_price", "price >= %s"},
+		{"max_price", "price <= %s"},
+	} {
+		if value := c.Query(filter.param); value != "" {
+			// Prices are stored as 32-bit integers, so larger values are rejected like any other invalid value
+			price, err := strconv.ParseInt(value, 10, 32)
+			if err != nil {
+				return fmt.Errorf("%s must be an integer", filter.param)
+			}
+
+			q.where(filter.condition, price)
+		}
+	}
+
+	for _, filter := range []struct{ param, condition string }{
+		{"uploaded_from", "upload_date >= %s"},
+		{"uploaded_to", "upload_date <= %s"},
+	} {
+		if value := c.Query(filter.param); value != "" {
+			date, err := time.Parse("2006-01-02", value)
+			if err != nil {
+				return fmt.Errorf("%s must be a date in the format YYYY-MM-DD", filter.param)
+			}
+
+			q.where(filter.condition, date)
+		}
+	}
+
+	return nil
+}
+
+// listProducts responds with a page of the products matching condition and the filters in the query parameters.
+// The placeholders of condition refer to args.
+func listProducts(c *gin.Context, condition string, args ...interface{}) {
+	q := productQuery{conditions: []string{condition}, args: args}
+
+	if err := q.addFilters(c); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	sortName := c.DefaultQuery("sort", "newest")
+
+	sort, ok := productSorts[sortName]
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "sort must be newest, oldest, price_asc or price_desc"})
+		return
+	}
+
+	limit := productPageDefaultLimit
+
+	if limitParam := c.Query("limit"); limitParam != "" {
+		var err error
+
+		limit, err = strconv.Atoi(limitParam)
+		if err != nil || limit < 1 || limit > productPageMaxLimit {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("limit must be between 1 and %d", productPageMaxLimit)})
+			return
+		}
+	}
+
+	direction, comparison := "ASC", ">"
+	if sort.descending {
+		direction, comparison = "DESC", "<"
+	}
+
+	if cursorParam := c.Query("cursor"); cursorParam != "" {
+		cursor, err := decodeProductCursor(cursorParam)
+		if err == nil && cursor.Sort == sortName {
+			err = sort.parseValue(cursor.Value)
+		}
+
+		if err != nil || cursor.Sort != sortName {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid cursor"})
+			return
+		}
+
+		q.conditions = append(q.conditions, fmt.Sprintf("(%s, product_id) %s (%s::%s, %s)",
+			sort.column, comparison, q.arg(cursor.Value), sort.valueType, q.arg(cursor.ProductID)))
+	}
+
+	// One more product than the limit is fetched to know whether there is a next page
+	query := fmt.Sprintf("SELECT * FROM Product WHERE %s ORDER BY %s %s, product_id %s LIMIT %s",
+		strings.Join(q.conditions, " AND "), sort.column, direction, direction, q.arg(limit+1))
+
+	page := ProductPage{Products: []*Product{}}
+
+	err := pgxscan.Select(c, dbPool, &page.Products, query, q.args...)
+	if err != nil {
+		fmt.Println(err)
+		c.Status(http.StatusInternalServerError)
+
+		return
+	}
+
+	if len(page.Products) > limit {
+		page.Products = page.Products[:limit]
+		last := page.Products[limit-1]
+
+		nextCursor := productCursor{Sort: sortName, Value: sort.value(last), ProductID: last.ProductID}.encode()
+		page.NextCursor = &nextCursor
+	}
+
+	if err = attachProductImages(c, page.Products...); err != nil {
+		fmt.Println(err)
+		c.Status(http.StatusInternalServerError)
+
+		return
+	}
+
+	c.JSON(http.StatusOK, page)
+}
@@ -0,0 +1,371 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/georgysavva/scany/pgxscan"
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v4"
+)
+
+// States of a product.
+const (
+	productAvailable = "available"
+	productReserved  = "reserved"
+	productSold      = "sold"
+	productWithdrawn = "withdrawn"
+)
+
+// Status of the purchase request a product is reserved for.
+const requestAccepted = "accepted"
+
+// productTransitions lists the states a product can move to from each state. Sold is final.
+var productTransitions = map[string][]string{
+	productAvailable: {productReserved, productWithdrawn},
+	productReserved:  {productAvailable, productSold, productWithdrawn},
+	productWithdrawn: {productAvailable},
+}
+
+var (
+	errInvalidTransition  = errors.New("invalid product state transition")
+	errProductUnavailable = errors.New("product is not available")
+	errOwnProduct         = errors.New("can not buy own product")
+	errAlreadyRequested   = errors.New("purchase already requested")
+	errNoPurchaseRequest  = errors.New("no such purchase request")
+	errNoAcceptedRequest  = errors.New("no accepted purchase request")
+	errNoOffer            = errors.New("no such offer")
+	errPendingOffer       = errors.New("offer already pending")
+	errOfferNotPending    = errors.New("offer is not pending")
+	errOwnOffer           = errors.New("can not respond to own offer")
+)
+
+// changeProductState moves a product to state within tx and records the change, made by the user with the given ID.
+// It returns an error wrapping errInvalidTransition if the product can not move to state from its current state. A
+// product is sold to the buyer whose purchase request is accepted, and errNoAcceptedRequest is returned if there is
+// none.
+func changeProductState(ctx context.Context, tx pgx.Tx, productID string, state string, userID int) error {
+	var current string
+
+	err := tx.QueryRow(ctx, "SELECT state FROM Product WHERE product_id = $1 FOR UPDATE", productID).Scan(&current)
+	if err != nil {
+		return err
+	}
+
+	allowed := false
+
+	for _, next := range productTransitions[current] {
+		allowed = allowed || next == state
+	}
+
+	if !allowed {
+		return fmt.Errorf("%w: a %s product can not be made %s", errInvalidTransition, current, state)
+	}
+
+	// Only sold products have a buyer, which is set along with the state as the database checks that they agree
+	var buyerID *int
+
+	if state == productSold {
+		query := "SELECT fk_user_id FROM Buying_Product WHERE fk_product_id = $1 AND status = 'accepted'"
+
+		err = tx.QueryRow(ctx, query, productID).Scan(&buyerID)
+		if err != nil {
+			if err.Error() == ErrNoRows {
+				return errNoAcceptedRequest
+			}
+
+			return err
+		}
+	}
+
+	query := "UPDATE Product SET state = $2, fk_buyer_id = $3 WHERE product_id = $1"
+	if _, err = tx.Exec(ctx, query, productID, state, buyerID); err != nil {
+		return err
+	}
+
+	query = "INSERT INTO Product_State_Change(fk_product_id, from_state, to_state, fk_user_id) VALUES($1, $2, $3, $4)"
+	_, err = tx.Exec(ctx, query, productID, current, state, userID)
+
+	return err
+}
+
+// rejectPendingBids rejects the pending purchase requests and offers of a product within tx, when it is no longer
+// available.
+func rejectPendingBids(ctx context.Context, tx pgx.Tx, productID string) error {
+	query := "UPDATE Buying_Product SET status = 'rejected' WHERE fk_product_id = $1 AND status = 'pending'"
+	if _, err := tx.Exec(ctx, query, productID); err != nil {
+		return err
+	}
+
+	query = "UPDATE Offer SET status = 'rejected', responded_at = now() WHERE fk_product_id = $1 AND status = 'pending'"
+	_, err := tx.Exec(ctx, query, productID)
+
+	return err
+}
+
+// respondProductStateError responds with the error of a failed change of a product or its purchase requests.
+func respondProductStateError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, errInvalidTransition):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, errProductUnavailable):
+		c.JSON(http.StatusConflict, gin.H{"error": "Product is not available"})
+	case errors.Is(err, errOwnProduct):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "You can not buy your own product"})
+	case errors.Is(err, errAlreadyRequested):
+		c.JSON(http.StatusConflict, gin.H{"error": "You have already requested to buy this product"})
+	case errors.Is(err, errNoPurchaseRequest):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Purchase request does not exist"})
+	case errors.Is(err, errNoAcceptedRequest):
+		c.JSON(http.StatusConflict, gin.H{"error": "Product can only be sold to the buyer it is reserved for"})
+	case errors.Is(err, errNoOffer):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Offer does not exist"})
+	case errors.Is(err, errPendingOffer):
+		c.JSON(http.StatusConflict, gin.H{"error": "There is a pending offer, which has to be responded to first"})
+	case errors.Is(err, errOfferNotPending):
+		c.JSON(http.StatusConflict, gin.H{"error": "Offer has already been responded to"})
+	case errors.Is(err, errOwnOffer):
+		c.JSON(http.StatusForbidden, gin.H{"error": "You can not respond to your own offer"})
+	case err.Error() == ErrNoRows:
+		c.JSON(http.StatusNotFound, gin.H{"error": "Product does not exist"})
+	default:
+		fmt.Println(err)
+		c.Status(http.StatusInternalServerError)
+	}
+}
+
+// respondProduct responds with the product with the given ID.
+func respondProduct(c *gin.Context, productID string) {
+	var product Product
+
+	err := pgxscan.Get(c, dbPool, &product, "SELECT * FROM Product WHERE product_id = $1", productID)
+	if err == nil {
+		err = attachProductImages(c, &product)
+	}
+
+	if err != nil {
+		fmt.Println(err)
+		c.Status(http.StatusInternalServerError)
+
+		return
+	}
+
+	c.JSON(http.StatusOK, product)
+}
+
+// requestPurchase makes a request from the logged in user to buy an available product. A user whose earlier request
+// was rejected or cancelled can request again.
+func requestPurchase(c *gin.Context) {
+	productID := c.Param("product_id")
+	userID := authUserID(c)
+
+	var request PurchaseRequest
+
+	err := dbPool.BeginFunc(c, func(tx pgx.Tx) error {
+		var ownerID int
+
+		var state string
+
+		query := "SELECT fk_user_id, state FROM Product WHERE product_id = $1 FOR UPDATE"
+		if err := tx.QueryRow(c, query, productID).Scan(&ownerID, &state); err != nil {
+			return err
+		}
+
+		if ownerID == userID {
+			return errOwnProduct
+		}
+
+		if state != productAvailable {
+			return errProductUnavailable
+		}
+
+		query = `INSERT INTO Buying_Product(fk_product_id, fk_user_id) VALUES($1, $2)
+			ON CONFLICT (fk_product_id, fk_user_id) DO UPDATE SET status = 'pending', created_at = now(), price = NULL
+			WHERE Buying_Product.status IN ('rejected', 'cancelled')
+			RETURNING *`
+
+		err := pgxscan.Get(c, tx, &request, query, productID, userID)
+		if err != nil && err.Error() == ErrNoRows {
+			return errAlreadyRequested
+		}
+
+		return err
+	})
+
+	if err != nil {
+		respondProductStateError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, request)
+}
+
+// cancelPurchaseRequest cancels the purchase request of the logged in user. If the product was reserved for the user
+// it becomes available again.
+func cancelPurchaseRequest(c *gin.Context) {
+	productID := c.Param("product_id")
+	userID := authUserID(c)
+
+	err := dbPool.BeginFunc(c, func(tx pgx.Tx) error {
+		var state string
+
+		query := "SELECT state FROM Product WHERE product_id = $1 FOR UPDATE"
+		if err := tx.QueryRow(c, query, productID).Scan(&state); err != nil {
+			return err
+		}
+
+		if state == productSold {
+			return fmt.Errorf("%w: the product is already sold", errInvalidTransition)
+		}
+
+		var status string
+
+		query = `UPDATE Buying_Product SET status = 'cancelled' FROM Buying_Product old
+			WHERE Buying_Product.fk_product_id = $1 AND Buying_Product.fk_user_id = $2
+			AND old.fk_product_id = $1 AND old.fk_user_id = $2 AND old.status IN ('pending', 'accepted')
+			RETURNING old.status`
+
+		err := tx.QueryRow(c, query, productID, userID).Scan(&status)
+		if err != nil {
+			if err.Error() == ErrNoRows {
+				return errNoPurchaseRequest
+			}
+
+			return err
+		}
+
+		if status == requestAccepted {
+			return changeProductState(c, tx, productID, productAvailable, userID)
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		respondProductStateError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// getPurchaseRequests returns the purchase requests of a product, newest first.
+func getPurchaseRequests(c *gin.Context) {
+	requests := []*PurchaseRequest{}
+
+	query := "SELECT * FROM Buying_Product WHERE fk_product_id = $1 ORDER BY created_at DESC"
+
+	err := pgxscan.Select(c, dbPool, &requests, query, c.Param("product_id"))
+	if err != nil {
+		fmt.Println(err)
+		c.Status(http.StatusInternalServerError)
+
+		return
+	}
+
+	c.JSON(http.StatusOK, requests)
+}
+
+// acceptPurchaseRequest accepts the pending purchase request of a buyer, which reserves the product for the buyer and
+// rejects the other pending requests and offers.
+func acceptPurchaseRequest(c *gin.Context) {
+	productID := c.Param("product_id")
+
+	err := dbPool.BeginFunc(c, func(tx pgx.Tx) error {
+		if err := changeProductState(c, tx, productID, productReserved, authUserID(c)); err != nil {
+			return err
+		}
+
+		// The buyer pays the price of the product
+		query := `UPDATE Buying_Product SET status = 'accepted', price = (SELECT price FROM Product WHERE product_id = $1)
+			WHERE fk_product_id = $1 AND fk_user_id = $2 AND status = 'pending'`
+
+		result, err := tx.Exec(c, query, productID, c.Param("buyer_id"))
+		if err != nil {
+			return err
+		}
+
+		// Returning an error rolls back the reservation
+		if result.RowsAffected() == 0 {
+			return errNoPurchaseRequest
+		}
+
+		return rejectPendingBids(c, tx, productID)
+	})
+
+	if err != nil {
+		respondProductStateError(c, err)
+		return
+	}
+
+	respondProduct(c, productID)
+}
+
+// setProductState changes the state of a product to the state in the request. A product is reserved by accepting a
+// purchase request, and can then be sold to that buyer or made available again. An available or reserved product can
+// be withdrawn, which rejects its purchase requests and offers, and a withdrawn product made available again.
+func setProductState(c *gin.Context) {
+	var body struct {
+		State string `json:"state" binding:"required,oneof=available sold withdrawn"`
+	}
+
+	if err := c.Bind(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	productID := c.Param("product_id")
+
+	err := dbPool.BeginFunc(c, func(tx pgx.Tx) error {
+		if err := changeProductState(c, tx, productID, body.State, authUserID(c)); err != nil {
+			return err
+		}
+
+		var query string
+
+		switch body.State {
+		case productSold:
+			// The buyer is set along with the state
+			return nil
+		case productAvailable:
+			// Whoever the product was reserved for no longer is
+			query = "UPDATE Buying_Product SET status = 'cancelled' WHERE fk_product_id = $1 AND status = 'accepted'"
+		case productWithdrawn:
+			if err := rejectPendingBids(c, tx, productID); err != nil {
+				return err
+			}
+
+			query = "UPDATE Buying_Product SET status = 'rejected' WHERE fk_product_id = $1 AND status = 'accepted'"
+		}
+
+		_, err := tx.Exec(c, query, productID)
+
+		return err
+	})
+
+	if err != nil {
+		respondProductStateError(c, err)
+		return
+	}
+
+	respondProduct(c, productID)
+}
+
+// getProductStateChanges returns the state changes of a product, oldest first.
+func getProductStateChanges(c *gin.Context) {
+	changes := []*ProductStateChange{}
+
+	query := "SELECT * FROM Product_State_Change WHERE fk_product_id = $1 ORDER BY changed_at, product_state_change_id"
+
+	err := pgxscan.Select(c, dbPool, &changes, query, c.Param("product_id"))
+	if err != nil {
+		fmt.Println(err)
+		c.Status(http.StatusInternalServerError)
+
+		return
+	}
+
+	c.JSON(http.StatusOK, changes)
+}
@@ -20,6 +20,9 @@ const (
 	keyChannelSenderRecipient = "channelSenderRecipient"
 )
 
+// Number of the most recent messages of a channel kept in Redis. The messages are stored durably elsewhere.
+const recentChannelMessages = 100
+
 type Message struct {
 	UUID          string    `json:"UUID"`
 	SenderID      string    `json:"SenderID"`
@@ -89,6 +92,53 @@ func (r *Redis) GetChannelUUID(senderUUID, recipientUUID string) (string, error)
 	return channelUUID, nil
 }
 
+// SetChannelUUID makes the channel with channelUUID the channel of two users.
+func (r *Redis) SetChannelUUID(senderUUID, recipientUUID, channelUUID string) error {
+	if err := r.client.Set(r.getKeyChannelSenderRecipient(senderUUID, recipientUUID), channelUUID, 0).Err(); err != nil {
+		return err
+	}
+
+	return r.client.Set(r.getKeyChannelSenderRecipient(recipientUUID, senderUUID), channelUUID, 0).Err()
+}
+
+// DirectChannels returns the UUIDs of the channels of two users, with the IDs of the two users of each.
+func (r *Redis) DirectChannels() (map[string][2]string, error) {
+	channels := map[string][2]string{}
+
+	prefix := keyChannelSenderRecipient + "."
+
+	var cursor uint64
+
+	for {
+		keys, next, err := r.client.Scan(cursor, prefix+"*", 100).Result()
+		if err != nil {
+			return nil, err
+		}
+
+		for _, key := range keys {
+			users := strings.Split(strings.TrimPrefix(key, prefix), ".")
+			if len(users) != 2 {
+				continue
+			}
+
+			channelUUID, err := r.client.Get(key).Result()
+			if err == redis.Nil {
+				continue
+			} else if err != nil {
+				return nil, err
+			}
+
+			channels[channelUUID] = [2]string{users[0], users[1]}
+		}
+
+		if next == 0 {
+			return channels, nil
+		}
+
+		cursor = next
+	}
+}
+
 func (r *Redis) channelJoin(channelUUID, senderUUID, recipientUUID string) error {
 	key := r.getKeyChannelUsers(channelUUID)
 	if err := r.client.HSet(key, senderUUID, time.Now().String()).Err(); err != nil {
@@ -106,9 +156,10 @@ func (r *Redis) channelJoin(channelUUID, senderUUID, recipientUUID string) error
 
 func (r *Redis) addChannelPubSub(channelUUID string, pubSub *redis.PubSub) *ChannelPubSub {
 	channelPubSub := &ChannelPubSub{
-		close:  make(chan struct{}, 1),
-		closed: make(chan struct{}, 1),
-		pubSub: pubSub,
+		channelUUID: channelUUID,
+		close:       make(chan struct{}, 1),
+		closed:      make(chan struct{}, 1),
+		pubSub:      pubSub,
 	}
 
 	r.channelsPubSubSync.Lock()
@@ -175,6 +226,11 @@ func (r *Redis) ChannelMessage(message *Message) (string, error) {
 		return "", err
 	}
 
+	err = r.client.LTrim(key, -recentChannelMessages, -1).Err()
+	if err != nil {
+		return "", err
+	}
+
 	return channelUUID, nil
 }
 
@@ -198,7 +254,7 @@ func (r *Redis) ChannelLeave(senderUUID, recipientUUID string) (string, error) {
 		return "", errors.New("channel not found")
 	}
 
-	close(channel.close)
+	channel.stop()
 
 	timeout := time.NewTimer(time.Second * 3)
 	select {
@@ -209,6 +265,65 @@ func (r *Redis) ChannelLeave(senderUUID, recipientUUID string) (string, error) {
 	}
 }
 
+// ChannelUnsubscribe ends the subscription to the channel once its receiver has stopped.
+func (r *Redis) ChannelUnsubscribe(channel *ChannelPubSub) error {
+	r.channelsPubSubSync.Lock()
+	if r.channelsPubSub[channel.channelUUID] == channel {
+		delete(r.channelsPubSub, channel.channelUUID)
+	}
+	r.channelsPubSubSync.Unlock()
+
+	channel.stop()
+
+	timeout := time.NewTimer(time.Second * 3)
+	defer timeout.Stop()
+
+	select {
+	case <-channel.closed:
+	case <-timeout.C:
+		return errors.New("channel closed with timeout")
+	}
+
+	return channel.pubSub.Close()
+}
+
+// ChannelHistories returns the UUIDs of the channels with messages in Redis.
+func (r *Redis) ChannelHistories() ([]string, error) {
+	var channelUUIDs []string
+
+	prefix := r.getKeyChannelMessages("")
+
+	var cursor uint64
+
+	for {
+		keys, next, err := r.client.Scan(cursor, prefix+"*", 100).Result()
+		if err != nil {
+			return nil, err
+		}
+
+		for _, key := range keys {
+			channelUUIDs = append(channelUUIDs, strings.TrimPrefix(key, prefix))
+		}
+
+		if next == 0 {
+			return channelUUIDs, nil
+		}
+
+		cursor = next
+	}
+}
+
+// ChannelMessagesDelete drops the messages of a channel from Redis.
+func (r *Redis) ChannelMessagesDelete(channelUUID string) error {
+	return r.client.Del(r.getKeyChannelMessages(channelUUID)).Err()
+}
+
+// ChannelMessagesTrim drops all but the most recent messages of a channel from Redis.
+func (r *Redis) ChannelMessagesTrim(channelUUID string) error {
+	key := r.getKeyChannelMessages(channelUUID)
+	return r.client.LTrim(key, -recentChannelMessages, -1).Err()
+}
+
 func (r *Redis) ChannelMessagesCount(channelUUID string) (int64, error) {
 	key := r.getKeyChannelMessages(channelUUID)
 	return r.client.LLen(key).Result()
@@ -132,3 +132,53 @@ func TestRedis_ChannelJoinPublic(t *testing.T) {
 
 	wg.Wait()
 }
+
+func TestRedis_ChannelMessagesRecent(t *testing.T) {
+	channelUUID := uuid.NewString()
+
+	for i := 0; i < recentChannelMessages+5; i++ {
+		message := &Message{
+			UUID:          uuid.NewString(),
+			SenderID:      "TEST_SENDER",
+			RecipientUUID: channelUUID,
+			Message:       fmt.Sprintf("Message #%d", i+1),
+			CreatedAt:     time.Now(),
+		}
+
+		if _, err := testRedisInstance.ChannelMessage(message); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	count, err := testRedisInstance.ChannelMessagesCount(channelUUID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if count != recentChannelMessages {
+		t.Fatalf("expected %d recent messages, got %d", recentChannelMessages, count)
+	}
+
+	messages, err := testRedisInstance.ChannelMessages(channelUUID, -1, -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(messages) != 1 || messages[0].Message != fmt.Sprintf("Message #%d", recentChannelMessages+5) {
+		t.Fatalf("expected the last message to be kept, got %+v", messages)
+	}
+
+	channelUUIDs, err := testRedisInstance.ChannelHistories()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	found := false
+	for _, history := range channelUUIDs {
+		found = found || history == channelUUID
+	}
+
+	if !found {
+		t.Fatalf("expected channel %s among the channel histories", channelUUID)
+	}
+}
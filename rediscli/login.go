@@ -0,0 +1,58 @@
+package rediscli
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/go-redis/redis"
+)
+
+const (
+	keyLoginFailures = "loginFailures"
+	keyLoginLockout  = "loginLockout"
+)
+
+func (r *Redis) getKeyLoginFailures(phoneNumber string) string {
+	return fmt.Sprintf("%s.%s", keyLoginFailures, phoneNumber)
+}
+
+func (r *Redis) getKeyLoginLockout(phoneNumber string) string {
+	return fmt.Sprintf("%s.%s", keyLoginLockout, phoneNumber)
+}
+
+// LoginFailureAdd counts a failed login for the phone number and returns the number of failed logins.
+// The count is forgotten when no login has failed for the duration of window.
+func (r *Redis) LoginFailureAdd(phoneNumber string, window time.Duration) (int64, error) {
+	key := r.getKeyLoginFailures(phoneNumber)
+
+	pipe := r.client.TxPipeline()
+	failures := pipe.Incr(key)
+	pipe.Expire(key, window)
+	_, err := pipe.Exec()
+
+	return failures.Val(), err
+}
+
+// LoginFailuresClear forgets the failed logins for the phone number.
+func (r *Redis) LoginFailuresClear(phoneNumber string) error {
+	return r.client.Del(r.getKeyLoginFailures(phoneNumber)).Err()
+}
+
+// LoginLockout locks out logins for the phone number for the given duration.
+func (r *Redis) LoginLockout(phoneNumber string, duration time.Duration) error {
+	key := r.getKeyLoginLockout(phoneNumber)
+
+	return r.client.Set(key, time.Now().String(), duration).Err()
+}
+
+// LoginLockedOut returns the time left of the lockout of the phone number, or 0 if it is not locked out.
+func (r *Redis) LoginLockedOut(phoneNumber string) (time.Duration, error) {
+	key := r.getKeyLoginLockout(phoneNumber)
+
+	ttl, err := r.client.PTTL(key).Result()
+	if err == redis.Nil || ttl < 0 {
+		return 0, nil
+	}
+
+	return ttl, err
+}
@@ -0,0 +1,108 @@
+package rediscli
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/go-redis/redis"
+)
+
+const (
+	keyRateLimit = "rateLimit"
+)
+
+func (r *Redis) getKeyRateLimit(key string) string {
+	return fmt.Sprintf("%s.%s", keyRateLimit, key)
+}
+
+// rateLimitHitScript counts a hit and returns the number of hits and the milliseconds left of the window.
+// The window starts with the first hit.
+var rateLimitHitScript = redis.NewScript(`
+local hits = redis.call('INCR', KEYS[1])
+if hits == 1 then
+	redis.call('PEXPIRE', KEYS[1], ARGV[1])
+end
+return {hits, redis.call('PTTL', KEYS[1])}
+`)
+
+// RateLimitHit counts a hit for the key in a fixed window. It reports whether more than limit hits have been counted
+// in the current window, along with the time left until the window ends.
+func (r *Redis) RateLimitHit(key string, limit int64, window time.Duration) (bool, time.Duration, error) {
+	key = r.getKeyRateLimit(key)
+
+	result, err := rateLimitHitScript.Run(r.client, []string{key}, window.Milliseconds()).Result()
+	if err != nil {
+		return false, 0, err
+	}
+
+	values, _ := result.([]interface{})
+	if len(values) != 2 {
+		return false, 0, fmt.Errorf("RateLimitHit: unexpected result %v", result)
+	}
+
+	hits, _ := values[0].(int64)
+	ttl, _ := values[1].(int64)
+
+	return hits > limit, time.Duration(ttl) * time.Millisecond, nil
+}
+
+// rateLimitTakeScript takes a token from a token bucket, refilling it with one token per interval milliseconds up to
+// capacity. It returns whether a token was taken and otherwise the milliseconds until the next token is added.
+var rateLimitTakeScript = redis.NewScript(`
+local capacity = tonumber(ARGV[1])
+local interval = tonumber(ARGV[2])
+local now = tonumber(ARGV[3])
+local bucket = redis.call('HMGET', KEYS[1], 'tokens', 'updated')
+local tokens = tonumber(bucket[1])
+local updated = tonumber(bucket[2])
+if not tokens or not updated then
+	tokens = capacity
+	updated = now
+end
+local refill = math.floor((now - updated) / interval)
+if refill > 0 then
+	tokens = tokens + refill
+	updated = updated + refill * interval
+end
+if tokens >= capacity then
+	tokens = capacity
+	updated = now
+end
+local taken = 0
+local wait = 0
+if tokens > 0 then
+	tokens = tokens - 1
+	taken = 1
+else
+	wait = updated + interval - now
+end
+redis.call('HMSET', KEYS[1], 'tokens', tokens, 'updated', updated)
+redis.call('PEXPIRE', KEYS[1], capacity * interval)
+return {taken, wait}
+`)
+
+// RateLimitTake takes a token from the token bucket of the key. The bucket holds up to capacity tokens and gets a new
+// token every refillInterval. It reports whether a token was taken, and otherwise the time until one can be taken.
+func (r *Redis) RateLimitTake(key string, capacity int64, refillInterval time.Duration) (bool, time.Duration, error) {
+	key = r.getKeyRateLimit(key)
+
+	interval := refillInterval.Milliseconds()
+	if interval < 1 {
+		interval = 1
+	}
+
+	result, err := rateLimitTakeScript.Run(r.client, []string{key}, capacity, interval, time.Now().UnixMilli()).Result()
+	if err != nil {
+		return false, 0, err
+	}
+
+	values, _ := result.([]interface{})
+	if len(values) != 2 {
+		return false, 0, fmt.Errorf("RateLimitTake: unexpected result %v", result)
+	}
+
+	taken, _ := values[0].(int64)
+	wait, _ := values[1].(int64)
+
+	return taken == 1, time.Duration(wait) * time.Millisecond, nil
+}
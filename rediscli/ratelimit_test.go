@@ -0,0 +1,64 @@
+package rediscli
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestRateLimitHit(t *testing.T) {
+	key := uuid.NewString()
+
+	for i := 0; i < 3; i++ {
+		limited, _, err := testRedisInstance.RateLimitHit(key, 3, time.Minute)
+		if err != nil {
+			t.Fatal("rateLimitHit", err)
+		}
+
+		if limited {
+			t.Fatalf("expected hit %d of key [%s] not to be limited", i+1, key)
+		}
+	}
+
+	limited, retryAfter, err := testRedisInstance.RateLimitHit(key, 3, time.Minute)
+	if err != nil {
+		t.Fatal("rateLimitHit", err)
+	}
+
+	if !limited {
+		t.Fatalf("expected key [%s] to be limited", key)
+	}
+
+	if retryAfter <= 0 || retryAfter > time.Minute {
+		t.Fatalf("expected retry after to be within the window, got %v", retryAfter)
+	}
+}
+
+func TestRateLimitTake(t *testing.T) {
+	key := uuid.NewString()
+
+	for i := 0; i < 2; i++ {
+		taken, _, err := testRedisInstance.RateLimitTake(key, 2, time.Minute)
+		if err != nil {
+			t.Fatal("rateLimitTake", err)
+		}
+
+		if !taken {
+			t.Fatalf("expected token %d of key [%s] to be taken", i+1, key)
+		}
+	}
+
+	taken, retryAfter, err := testRedisInstance.RateLimitTake(key, 2, time.Minute)
+	if err != nil {
+		t.Fatal("rateLimitTake", err)
+	}
+
+	if taken {
+		t.Fatalf("expected bucket of key [%s] to be empty", key)
+	}
+
+	if retryAfter <= 0 || retryAfter > time.Minute {
+		t.Fatalf("expected retry after to be within the refill interval, got %v", retryAfter)
+	}
+}
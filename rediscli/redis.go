@@ -14,9 +14,12 @@ type Redis struct {
 }
 
 type ChannelPubSub struct {
-	close  chan struct{}
-	closed chan struct{}
-	pubSub *redis.PubSub
+	channelUUID string
+	close       chan struct{}
+	closed      chan struct{}
+	closeOnce   sync.Once
+	closedOnce  sync.Once
+	pubSub      *redis.PubSub
 }
 
 func (channel *ChannelPubSub) Channel() <-chan *redis.Message {
@@ -31,6 +34,20 @@ func (channel *ChannelPubSub) Closed() <-chan struct{} {
 	return channel.closed
 }
 
+// Done tells those waiting for the channel to close that its receiver has stopped.
+func (channel *ChannelPubSub) Done() {
+	channel.closedOnce.Do(func() {
+		close(channel.closed)
+	})
+}
+
+// stop asks the receiver of the channel to stop.
+func (channel *ChannelPubSub) stop() {
+	channel.closeOnce.Do(func() {
+		close(channel.close)
+	})
+}
+
 func NewRedis(addr, passwd string) *Redis {
 	log.Println("Initialized redis client", addr, passwd)
 
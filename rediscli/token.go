@@ -0,0 +1,68 @@
+package rediscli
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/go-redis/redis"
+)
+
+const (
+	keyRevokedToken        = "revokedToken"
+	keyUserTokensRevokedAt = "userTokensRevokedAt"
+)
+
+func (r *Redis) getKeyRevokedToken(tokenID string) string {
+	return fmt.Sprintf("%s.%s", keyRevokedToken, tokenID)
+}
+
+// TokenRevoke marks the token with the given ID as revoked. The mark is kept until expiration has passed,
+// after which the token is expired anyway. It returns false if the token was already revoked.
+func (r *Redis) TokenRevoke(tokenID string, expiration time.Duration) (bool, error) {
+	if expiration <= 0 {
+		expiration = time.Second
+	}
+
+	key := r.getKeyRevokedToken(tokenID)
+
+	return r.client.SetNX(key, time.Now().String(), expiration).Result()
+}
+
+// TokenIsRevoked reports whether the token with the given ID has been revoked.
+func (r *Redis) TokenIsRevoked(tokenID string) (bool, error) {
+	key := r.getKeyRevokedToken(tokenID)
+
+	n, err := r.client.Exists(key).Result()
+	if err != nil {
+		return false, err
+	}
+
+	return n > 0, nil
+}
+
+func (r *Redis) getKeyUserTokensRevokedAt(userID string) string {
+	return fmt.Sprintf("%s.%s", keyUserTokensRevokedAt, userID)
+}
+
+// UserTokensRevoke revokes all tokens issued to the user until now. The revocation is kept until expiration has
+// passed, after which all tokens issued before it are expired anyway.
+func (r *Redis) UserTokensRevoke(userID string, expiration time.Duration) error {
+	key := r.getKeyUserTokensRevokedAt(userID)
+
+	return r.client.Set(key, time.Now().UnixNano(), expiration).Err()
+}
+
+// UserTokensRevokedAt returns the time the tokens of the user were last revoked, or the zero Unix time if they never
+// were.
+func (r *Redis) UserTokensRevokedAt(userID string) (time.Time, error) {
+	key := r.getKeyUserTokensRevokedAt(userID)
+
+	revokedAt, err := r.client.Get(key).Int64()
+	if err == redis.Nil {
+		return time.Unix(0, 0), nil
+	} else if err != nil {
+		return time.Time{}, err
+	}
+
+	return time.Unix(0, revokedAt), nil
+}
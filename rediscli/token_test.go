@@ -0,0 +1,48 @@
+package rediscli
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestTokenRevoke(t *testing.T) {
+	tokenID := uuid.NewString()
+
+	revoked, err := testRedisInstance.TokenIsRevoked(tokenID)
+	if err != nil {
+		t.Fatal("tokenIsRevoked", err)
+	}
+
+	if revoked {
+		t.Fatalf("expected token [%s] not to be revoked", tokenID)
+	}
+
+	revoked, err = testRedisInstance.TokenRevoke(tokenID, time.Minute)
+	if err != nil {
+		t.Fatal("tokenRevoke", err)
+	}
+
+	if !revoked {
+		t.Fatalf("expected token [%s] to be newly revoked", tokenID)
+	}
+
+	revoked, err = testRedisInstance.TokenRevoke(tokenID, time.Minute)
+	if err != nil {
+		t.Fatal("tokenRevoke", err)
+	}
+
+	if revoked {
+		t.Fatalf("expected token [%s] to already be revoked", tokenID)
+	}
+
+	revoked, err = testRedisInstance.TokenIsRevoked(tokenID)
+	if err != nil {
+		t.Fatal("tokenIsRevoked", err)
+	}
+
+	if !revoked {
+		t.Fatalf("expected token [%s] to be revoked", tokenID)
+	}
+}
@@ -2,32 +2,27 @@ package rediscli
 
 import (
 	"bytes"
-	"crypto/md5" // nolint:gosec // package is not used for security
 	"encoding/json"
-	"errors"
 	"fmt"
 	"log"
 	"strconv"
 	"strings"
 	"time"
 
-	"github.com/go-redis/redis"
 	"github.com/google/uuid"
 )
 
 const (
-	keyUsers                  = "users"
-	keyUserStatus             = "userStatus"
-	keyUserChannels           = "userChannels"
-	keyUserAccessKey          = "userAccessKey"
-	keyUsersUUIDListIndex     = "usersUUIDListIndex"
-	keyUsersUsernameListIndex = "usersUsernameListIndex"
+	keyUsers              = "users"
+	keyUserStatus         = "userStatus"
+	keyUserChannels       = "userChannels"
+	keyUserAccessKey      = "userAccessKey"
+	keyUsersUUIDListIndex = "usersUUIDListIndex"
 )
 
 type User struct {
-	ID       string `json:"user_id"`
-	Name     string `json:"Username"`
-	Password string `json:"Password,omitempty"`
+	ID   string `json:"user_id"`
+	Name string `json:"Username"`
 	// AccessKey   string `json:"AccessKey,omitempty"`
 	OnLine      bool   `json:"OnLine"`
 	SessionUUID string `json:"-"`
@@ -41,28 +36,6 @@ func (r *Redis) getKeyUsersUUIDListIndex(userUUID string) string {
 	return fmt.Sprintf("%s.%s", keyUsersUUIDListIndex, userUUID)
 }
 
-func (r *Redis) getKeyUsersUsernameListIndex(username string) string {
-	return fmt.Sprintf("%s.%x", keyUsersUsernameListIndex, md5.Sum([]byte(username))) // nolint:gosec // package is not used for security
-}
-
-func (r *Redis) getUserIndexByUsername(username string) (int64, error) {
-	log.Println("getUserIndexByUsername", username)
-
-	key := r.getKeyUsersUsernameListIndex(username)
-	value, err := r.client.Get(key).Result()
-
-	if err != nil {
-		return 0, err
-	}
-
-	index, err := strconv.ParseInt(value, 10, 64)
-	if err != nil {
-		return 0, err
-	}
-
-	return index, nil
-}
-
 func (r *Redis) getUserIndexByUUID(userUUID string) (int64, error) {
 	log.Println("getUserIndexByUUID", userUUID)
 
@@ -93,38 +66,6 @@ func (r *Redis) getKeyUserAccessKey(userUUID string) string {
 	return fmt.Sprintf("%s.%s", keyUserAccessKey, userUUID)
 }
 
-func (r *Redis) UserAuthorize(username, password string) (*User, error) {
-	log.Println("UserAuthorize", fmt.Sprintf("[%s|%s]", username, password))
-
-	user, err := r.UserGet(username)
-	if errors.Is(err, redis.Nil) {
-		user, err = r.UserCreate(username, password)
-		if err != nil {
-			return nil, err
-		}
-	} else if err != nil {
-		return nil, err
-	}
-
-	log.Println("UserAuthorize", fmt.Sprintf("%+v", user))
-
-	if user.Password != password {
-		return nil, errors.New("wrong password")
-	}
-
-	// user.AccessKey, err = r.UserUpdateAccessKey(user.ID)
-	// if err != nil {
-	// 	return nil, err
-	// }
-
-	err = r.UserSetOnline(user.ID)
-	if err != nil {
-		return nil, err
-	}
-
-	return user, nil
-}
-
 func (r *Redis) addUser(user *User) error {
 	buff := bytes.NewBufferString("")
 	enc := json.NewEncoder(buff)
@@ -142,17 +83,10 @@ func (r *Redis) addUser(user *User) error {
 	}
 
 	index := elements - 1
-	keyUserUsernameIndex := r.getKeyUsersUsernameListIndex(user.Name)
 	keyUserUUIDIndex := r.getKeyUsersUUIDListIndex(user.ID)
 
-	err = r.client.Set(keyUserUsernameIndex, fmt.Sprintf("%d", index), 0).Err()
-	if err != nil {
-		return err
-	}
-
 	err = r.client.Set(keyUserUUIDIndex, fmt.Sprintf("%d", index), 0).Err()
 	if err != nil {
-		r.client.Del(keyUserUsernameIndex)
 		return err
 	}
 
@@ -179,24 +113,6 @@ func (r *Redis) getUserFromList(userIndex int64) (*User, error) {
 	return user, nil
 }
 
-func (r *Redis) getUserFromListByUsername(username string) (*User, error) {
-	log.Println("getUserFromListByUsername", username)
-
-	userIndex, err := r.getUserIndexByUsername(username)
-	if err != nil {
-		return nil, err
-	}
-
-	user, err := r.getUserFromList(userIndex)
-	if err != nil {
-		return nil, err
-	}
-
-	user.OnLine = r.UserIsOnline(user.ID)
-
-	return user, nil
-}
-
 func (r *Redis) getUserFromListByUUID(userUUID string) (*User, error) {
 	log.Println("getUserFromListByUUID", userUUID)
 
@@ -218,7 +134,7 @@ func (r *Redis) getUserFromListByUUID(userUUID string) (*User, error) {
 func (r *Redis) UserCreate(id, name string) (*User, error) {
 	log.Println("UserCreate", fmt.Sprintf("[%s|%s]", id, name))
 
-	if user, err := r.getUserFromListByUsername(name); err == nil {
+	if user, err := r.getUserFromListByUUID(id); err == nil {
 		return user, nil
 	}
 
@@ -315,3 +231,39 @@ func (r *Redis) UserSignOut(userUUID string) {
 	r.UserDeleteAccessKey(keyAccessKey)
 	r.UserSetOffline(userUUID)
 }
+
+// UsersReplace replaces all users and their index with the given users in a single transaction.
+func (r *Redis) UsersReplace(users []*User) error {
+	log.Println("UsersReplace", len(users))
+
+	oldUsers, err := r.UserAll()
+	if err != nil {
+		return fmt.Errorf("UsersReplace: %w", err)
+	}
+
+	key := r.getKeyUsers()
+
+	pipe := r.client.TxPipeline()
+	pipe.Del(key)
+
+	for _, user := range oldUsers {
+		pipe.Del(r.getKeyUsersUUIDListIndex(user.ID))
+	}
+
+	for i, user := range users {
+		buff := bytes.NewBufferString("")
+
+		if err = json.NewEncoder(buff).Encode(user); err != nil {
+			return fmt.Errorf("UsersReplace: %w", err)
+		}
+
+		pipe.RPush(key, buff.String())
+		pipe.Set(r.getKeyUsersUUIDListIndex(user.ID), fmt.Sprintf("%d", i), 0)
+	}
+
+	if _, err = pipe.Exec(); err != nil {
+		return fmt.Errorf("UsersReplace: %w", err)
+	}
+
+	return nil
+}
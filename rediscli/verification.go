@@ -0,0 +1,103 @@
+package rediscli
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"github.com/go-redis/redis"
+)
+
+const (
+	keyVerificationCode         = "verificationCode"
+	keyVerificationCodeCooldown = "verificationCodeCooldown"
+)
+
+func (r *Redis) getKeyVerificationCode(purpose, phoneNumber string) string {
+	return fmt.Sprintf("%s.%s.%s", keyVerificationCode, purpose, phoneNumber)
+}
+
+func (r *Redis) getKeyVerificationCodeCooldown(purpose, phoneNumber string) string {
+	return fmt.Sprintf("%s.%s.%s", keyVerificationCodeCooldown, purpose, phoneNumber)
+}
+
+// verificationCodeAttemptScript counts an attempt and returns the code hash and the number of attempts, without
+// recreating a code that has expired.
+var verificationCodeAttemptScript = redis.NewScript(`
+local hash = redis.call('HGET', KEYS[1], 'code')
+if not hash then
+	return nil
+end
+return {hash, redis.call('HINCRBY', KEYS[1], 'attempts', 1)}
+`)
+
+func hashVerificationCode(code string) string {
+	sum := sha256.Sum256([]byte(code))
+	return hex.EncodeToString(sum[:])
+}
+
+// VerificationCodeCooldown starts a cooldown for sending codes for the given purpose to the phone number.
+// It returns false if a cooldown is already running.
+func (r *Redis) VerificationCodeCooldown(purpose, phoneNumber string, cooldown time.Duration) (bool, error) {
+	key := r.getKeyVerificationCodeCooldown(purpose, phoneNumber)
+
+	return r.client.SetNX(key, time.Now().String(), cooldown).Result()
+}
+
+// VerificationCodeSet stores a one-time code for the given purpose and phone number, replacing any previous code.
+// Only a hash of the code is stored.
+func (r *Redis) VerificationCodeSet(purpose, phoneNumber, code string, expiration time.Duration) error {
+	key := r.getKeyVerificationCode(purpose, phoneNumber)
+
+	pipe := r.client.TxPipeline()
+	pipe.Del(key)
+	pipe.HSet(key, "code", hashVerificationCode(code))
+	pipe.HSet(key, "attempts", 0)
+	pipe.Expire(key, expiration)
+	_, err := pipe.Exec()
+
+	return err
+}
+
+// VerificationCodeCheck reports whether the code matches the stored code for the given purpose and phone number.
+// A matching code is deleted so it can only be used once, as is a code that has been tried maxAttempts times.
+func (r *Redis) VerificationCodeCheck(purpose, phoneNumber, code string, maxAttempts int64) (bool, error) {
+	key := r.getKeyVerificationCode(purpose, phoneNumber)
+
+	result, err := verificationCodeAttemptScript.Run(r.client, []string{key}).Result()
+	if err == redis.Nil {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+
+	values, _ := result.([]interface{})
+	if len(values) != 2 {
+		return false, fmt.Errorf("VerificationCodeCheck: unexpected result %v", result)
+	}
+
+	hash, _ := values[0].(string)
+	attempts, _ := values[1].(int64)
+
+	if attempts > maxAttempts {
+		return false, r.client.Del(key).Err()
+	}
+
+	if subtle.ConstantTimeCompare([]byte(hash), []byte(hashVerificationCode(code))) == 1 {
+		deleted, err := r.client.Del(key).Result()
+		if err != nil {
+			return false, err
+		}
+
+		// The code was already used by a concurrent check
+		return deleted == 1, nil
+	}
+
+	if attempts == maxAttempts {
+		return false, r.client.Del(key).Err()
+	}
+
+	return false, nil
+}
@@ -0,0 +1,258 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/georgysavva/scany/pgxscan"
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v4"
+)
+
+// changeReviews runs change in a transaction and updates the rating of the user with the given ID in the same
+// transaction, after change has changed the reviews of the user. The user is locked first, so that concurrent
+// changes of the reviews are not left out of one another's ratings.
+func changeReviews(ctx context.Context, ownerID int, change func(tx pgx.Tx) error) error {
+	return dbPool.BeginFunc(ctx, func(tx pgx.Tx) error {
+		if _, err := tx.Exec(ctx, "SELECT 1 FROM Users WHERE user_id = $1 FOR UPDATE", ownerID); err != nil {
+			return err
+		}
+
+		if err := change(tx); err != nil {
+			return err
+		}
+
+		query := "UPDATE Users SET rating = (SELECT AVG(rating) FROM Review WHERE fk_owner_id = $1) WHERE user_id = $1"
+		_, err := tx.Exec(ctx, query, ownerID)
+
+		return err
+	})
+}
+
+// getReviewSummary returns the average rating of a user along with the number of reviews with each rating.
+func getReviewSummary(c *gin.Context) {
+	user := c.Param("user_id")
+
+	if checkIfUserExist(c, user) == false {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User does not exist"})
+		return
+	}
+
+	summary := ReviewSummary{Histogram: map[int]int{1: 0, 2: 0, 3: 0, 4: 0, 5: 0}}
+
+	rows, err := dbPool.Query(c, "SELECT rating, count(*) FROM Review WHERE fk_owner_id = $1 GROUP BY rating", user)
+	if err != nil {
+		fmt.Println(err)
+		c.Status(http.StatusInternalServerError)
+
+		return
+	}
+	defer rows.Close()
+
+	sum := 0
+
+	for rows.Next() {
+		var rating, count int
+
+		if err = rows.Scan(&rating, &count); err != nil {
+			break
+		}
+
+		summary.Histogram[rating] = count
+		summary.Count += count
+		sum += rating * count
+	}
+
+	if err == nil {
+		err = rows.Err()
+	}
+
+	if err != nil {
+		fmt.Println(err)
+		c.Status(http.StatusInternalServerError)
+
+		return
+	}
+
+	if summary.Count > 0 {
+		average := float64(sum) / float64(summary.Count)
+		summary.Average = &average
+	}
+
+	c.JSON(http.StatusOK, summary)
+}
+
+// attachReviewReplies sets the replies of the reviews that have been replied to, with one query for all of them.
+func attachReviewReplies(ctx context.Context, reviews ...*Review) error {
+	reviewIDs := make([]int, len(reviews))
+	byID := make(map[int]*Review, len(reviews))
+
+	for i, review := range reviews {
+		review.Reply = nil
+		reviewIDs[i] = review.ReviewID
+		byID[review.ReviewID] = review
+	}
+
+	if len(reviews) == 0 {
+		return nil
+	}
+
+	var replies []*ReviewReply
+
+	err := pgxscan.Select(ctx, dbPool, &replies, "SELECT * FROM Review_Reply WHERE fk_review_id = ANY($1)", reviewIDs)
+	if err != nil {
+		return err
+	}
+
+	for _, reply := range replies {
+		byID[reply.ReviewID].Reply = reply
+	}
+
+	return nil
+}
+
+// getReview returns the review in the review_id parameter, which must be a review of the user in the user_id
+// parameter. It responds with an error and returns false if there is no such review.
+func getReview(c *gin.Context) (Review, bool) {
+	var review Review
+
+	query := "SELECT * FROM Review WHERE review_id = $1 AND fk_owner_id = $2"
+
+	err := pgxscan.Get(c, dbPool, &review, query, c.Param("review_id"), c.Param("user_id"))
+	if err != nil {
+		if err.Error() == ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Review does not exist"})
+			return review, false
+		}
+
+		fmt.Println(err)
+		c.Status(http.StatusInternalServerError)
+
+		return review, false
+	}
+
+	return review, true
+}
+
+// getOwnReview returns the review in the review_id parameter like getReview, if the logged in user wrote it.
+func getOwnReview(c *gin.Context) (Review, bool) {
+	review, ok := getReview(c)
+	if ok && review.ReviewerID != authUserID(c) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You can only change your own reviews"})
+		return review, false
+	}
+
+	return review, ok
+}
+
+// updateReview changes the rating and content of a review by its author, which marks it as edited.
+func updateReview(c *gin.Context) {
+	var body struct {
+		Rating  int    `json:"rating" binding:"required,min=1,max=5"`
+		Content string `json:"content"`
+	}
+
+	if err := c.Bind(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	review, ok := getOwnReview(c)
+	if !ok {
+		return
+	}
+
+	err := changeReviews(c, review.OwnerID, func(tx pgx.Tx) error {
+		query := "UPDATE Review SET rating = $2, content = $3, edited_at = now() WHERE review_id = $1 RETURNING *"
+		return pgxscan.Get(c, tx, &review, query, review.ReviewID, body.Rating, body.Content)
+	})
+
+	if err == nil {
+		err = attachReviewReplies(c, &review)
+	}
+
+	if err != nil {
+		fmt.Println(err)
+		c.Status(http.StatusInternalServerError)
+
+		return
+	}
+
+	c.JSON(http.StatusOK, review)
+}
+
+// deleteReview deletes a review by its author, along with its reply.
+func deleteReview(c *gin.Context) {
+	review, ok := getOwnReview(c)
+	if !ok {
+		return
+	}
+
+	err := changeReviews(c, review.OwnerID, func(tx pgx.Tx) error {
+		_, err := tx.Exec(c, "DELETE FROM Review WHERE review_id = $1", review.ReviewID)
+		return err
+	})
+
+	if err != nil {
+		fmt.Println(err)
+		c.Status(http.StatusInternalServerError)
+
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// setReviewReply sets the reply of the reviewed user to a review. A review has a single reply, which is marked as
+// edited when it is replaced.
+func setReviewReply(c *gin.Context) {
+	var reply ReviewReply
+
+	if err := c.Bind(&reply); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	review, ok := getReview(c)
+	if !ok {
+		return
+	}
+
+	query := `INSERT INTO Review_Reply(fk_review_id, content) VALUES($1, $2)
+		ON CONFLICT (fk_review_id) DO UPDATE SET content = EXCLUDED.content, edited_at = now()
+		RETURNING *`
+
+	err := pgxscan.Get(c, dbPool, &reply, query, review.ReviewID, reply.Content)
+	if err != nil {
+		fmt.Println(err)
+		c.Status(http.StatusInternalServerError)
+
+		return
+	}
+
+	c.JSON(http.StatusOK, reply)
+}
+
+// deleteReviewReply deletes the reply of the reviewed user to a review.
+func deleteReviewReply(c *gin.Context) {
+	review, ok := getReview(c)
+	if !ok {
+		return
+	}
+
+	result, err := dbPool.Exec(c, "DELETE FROM Review_Reply WHERE fk_review_id = $1", review.ReviewID)
+	if err != nil {
+		fmt.Println(err)
+		c.Status(http.StatusInternalServerError)
+
+		return
+	}
+
+	if result.RowsAffected() == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Reply does not exist"})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
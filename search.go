@@ -0,0 +1,110 @@
+package main
+
+import (
+	"fmt"
+	"html"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/georgysavva/scany/pgxscan"
+	"github.com/gin-gonic/gin"
+)
+
+// Number of search results returned by default and at most.
+const (
+	searchDefaultLimit = 20
+	searchMaxLimit     = 100
+)
+
+// ProductSearchResult is a product matching a search, with the matching words highlighted in its name and description.
+// The highlights are HTML, with the text escaped and the matching words in <b> tags.
+type ProductSearchResult struct {
+	Product
+	Rank                 float32 `json:"rank"`
+	NameHighlight        string  `json:"name_highlight"`
+	DescriptionHighlight string  `json:"description_highlight"`
+}
+
+// Characters marking the matching words in the highlights from the database, which are replaced with <b> tags once the
+// rest of the text has been escaped. They are in the Unicode private use area, so they are not found in the text.
+const (
+	highlightStart = "\uE000"
+	highlightStop  = "\uE001"
+)
+
+// The query is parsed with both Swedish and English stemming, and each product is ranked and highlighted with the
+// language it matches best. Withdrawn products are not searched.
+const searchProductsQuery = `
+SELECT p.*, best.rank,
+    ts_headline(best.config, p.name, best.query,
+        'HighlightAll=true, StartSel="` + highlightStart + `", StopSel="` + highlightStop + `"') AS name_highlight,
+    ts_headline(best.config, coalesce(p.description, ''), best.query,
+        'MaxFragments=2, StartSel="` + highlightStart + `", StopSel="` + highlightStop + `"') AS description_highlight
+FROM (SELECT websearch_to_tsquery('swedish', $1) AS swedish, websearch_to_tsquery('english', $1) AS english) q
+CROSS JOIN Product_Search s
+JOIN Product p ON p.product_id = s.fk_product_id
+CROSS JOIN LATERAL (
+    SELECT config, query, rank FROM (VALUES
+        ('swedish'::regconfig, q.swedish, ts_rank_cd(s.swedish, q.swedish)),
+        ('english'::regconfig, q.english, ts_rank_cd(s.english, q.english))
+    ) AS r(config, query, rank)
+    ORDER BY rank DESC
+    LIMIT 1
+) best
+WHERE (s.swedish @@ q.swedish OR s.english @@ q.english) AND p.state <> 'withdrawn'
+ORDER BY best.rank DESC, p.product_id DESC
+LIMIT $2`
+
+// searchProducts returns the products matching the q query parameter, best matches first.
+// Matching words are highlighted with <b> tags, in text that is otherwise HTML escaped.
+func searchProducts(c *gin.Context) {
+	q := strings.TrimSpace(c.Query("q"))
+	if q == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "q is required"})
+		return
+	}
+
+	limit := searchDefaultLimit
+
+	if limitParam := c.Query("limit"); limitParam != "" {
+		var err error
+
+		limit, err = strconv.Atoi(limitParam)
+		if err != nil || limit < 1 || limit > searchMaxLimit {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("limit must be between 1 and %d", searchMaxLimit)})
+			return
+		}
+	}
+
+	var results []*ProductSearchResult
+
+	err := pgxscan.Select(c, dbPool, &results, searchProductsQuery, q, limit)
+	if err != nil {
+		fmt.Println(err)
+		c.Status(http.StatusInternalServerError)
+
+		return
+	}
+
+	products := make([]*Product, len(results))
+	for i, result := range results {
+		products[i] = &result.Product
+		result.NameHighlight = highlightHTML(result.NameHighlight)
+		result.DescriptionHighlight = highlightHTML(result.DescriptionHighlight)
+	}
+
+	if err = attachProductImages(c, products...); err != nil {
+		fmt.Println(err)
+		c.Status(http.StatusInternalServerError)
+
+		return
+	}
+
+	c.JSON(http.StatusOK, results)
+}
+
+// highlightHTML escapes a highlight from the database as HTML and puts the marked words in <b> tags.
+func highlightHTML(highlight string) string {
+	return strings.NewReplacer(highlightStart, "<b>", highlightStop, "</b>").Replace(html.EscapeString(highlight))
+}
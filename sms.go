@@ -0,0 +1,52 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// SMSSender sends text messages to phone numbers.
+type SMSSender interface {
+	SendSMS(ctx context.Context, phoneNumber string, message string) error
+}
+
+// logSMSSender is a SMSSender that writes the messages to a log instead of sending them.
+// It is meant for local development and tests.
+type logSMSSender struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+// newLogSMSSender creates a SMSSender writing the messages to w.
+func newLogSMSSender(w io.Writer) *logSMSSender {
+	return &logSMSSender{w: w}
+}
+
+// SendSMS writes the message to the log.
+func (s *logSMSSender) SendSMS(ctx context.Context, phoneNumber string, message string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, err := fmt.Fprintf(s.w, "%s SMS to %s: %s\n", time.Now().Format(time.RFC3339), phoneNumber, message)
+
+	return err
+}
+
+// setupSMSSender creates the SMSSender used by the server. Messages are written to the file at logFile, or to
+// stdout if it is empty.
+func setupSMSSender(logFile string) (SMSSender, error) {
+	if logFile == "" {
+		return newLogSMSSender(os.Stdout), nil
+	}
+
+	file, err := os.OpenFile(logFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return nil, err
+	}
+
+	return newLogSMSSender(file), nil
+}
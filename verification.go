@@ -0,0 +1,153 @@
+package main
+
+import (
+	"crypto/rand"
+	"fmt"
+	"math/big"
+	"net/http"
+	"time"
+
+	"github.com/georgysavva/scany/pgxscan"
+	"github.com/gin-gonic/gin"
+)
+
+// Settings for the one-time codes sent by SMS.
+const (
+	verificationCodeLifetime    = 10 * time.Minute
+	verificationCodeMaxAttempts = 5
+	verificationCodeCooldown    = time.Minute
+)
+
+// Purposes of the one-time codes, codes for one purpose can not be used for another.
+const (
+	codePurposePhoneVerification = "phoneVerification"
+	codePurposePasswordReset     = "passwordReset"
+)
+
+// generateVerificationCode returns a random six digit code.
+func generateVerificationCode() (string, error) {
+	n, err := rand.Int(rand.Reader, big.NewInt(1000000))
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("%06d", n.Int64()), nil
+}
+
+// sendVerificationCode generates a one-time code for the given purpose and sends it to the phone number.
+// It returns false without sending a code if a code was sent to the phone number recently.
+func sendVerificationCode(c *gin.Context, purpose, phoneNumber, message string) (bool, error) {
+	allowed, err := redisCli.VerificationCodeCooldown(purpose, phoneNumber, verificationCodeCooldown)
+	if err != nil || !allowed {
+		return false, err
+	}
+
+	code, err := generateVerificationCode()
+	if err != nil {
+		return false, err
+	}
+
+	err = redisCli.VerificationCodeSet(purpose, phoneNumber, code, verificationCodeLifetime)
+	if err != nil {
+		return false, err
+	}
+
+	return true, smsSender.SendSMS(c, phoneNumber, fmt.Sprintf(message, code))
+}
+
+// requestPhoneVerification sends a verification code to the phone number of the user.
+func requestPhoneVerification(c *gin.Context) {
+	var user User
+
+	query := "SELECT phone_number, verified FROM Users WHERE user_id = $1"
+	err := pgxscan.Get(c, dbPool, &user, query, c.Param("user_id"))
+
+	if err != nil {
+		if err.Error() == ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": "User does not exist"})
+			return
+		}
+
+		fmt.Println(err)
+		c.Status(http.StatusInternalServerError)
+
+		return
+	}
+
+	if user.Verified {
+		c.JSON(http.StatusConflict, gin.H{"error": "Phone number is already verified"})
+		return
+	}
+
+	sent, err := sendVerificationCode(c, codePurposePhoneVerification, user.PhoneNumber, "Your verification code is %s")
+	if err != nil {
+		fmt.Println(err)
+		c.Status(http.StatusInternalServerError)
+
+		return
+	}
+
+	if !sent {
+		setRetryAfter(c, verificationCodeCooldown)
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": "A code was sent recently, try again later"})
+
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{"phone_number": user.PhoneNumber})
+}
+
+// confirmPhoneVerification marks the phone number of the user as verified if the given code is correct.
+func confirmPhoneVerification(c *gin.Context) {
+	var body struct {
+		Code string `json:"code" binding:"required"`
+	}
+
+	if err := c.Bind(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var user User
+
+	query := "SELECT phone_number FROM Users WHERE user_id = $1"
+	err := pgxscan.Get(c, dbPool, &user, query, c.Param("user_id"))
+
+	if err != nil {
+		if err.Error() == ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": "User does not exist"})
+			return
+		}
+
+		fmt.Println(err)
+		c.Status(http.StatusInternalServerError)
+
+		return
+	}
+
+	valid, err := redisCli.VerificationCodeCheck(codePurposePhoneVerification, user.PhoneNumber, body.Code, verificationCodeMaxAttempts)
+	if err != nil {
+		fmt.Println(err)
+		c.Status(http.StatusInternalServerError)
+
+		return
+	}
+
+	if !valid {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Incorrect or expired code"})
+		return
+	}
+
+	// Only verify the phone number the code was sent to, in case it was changed in the meantime
+	query = "UPDATE Users SET verified = true WHERE user_id = $1 AND phone_number = $2"
+	_, err = dbPool.Exec(c, query, c.Param("user_id"), user.PhoneNumber)
+
+	if err != nil {
+		fmt.Println(err)
+		c.Status(http.StatusInternalServerError)
+
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"verified": true})
+}
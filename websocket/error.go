@@ -1,5 +1,7 @@
 package websocket
 
+import "errors"
+
 type IError interface {
 	Error() (uint32, error)
 }
@@ -7,4 +9,11 @@ type IError interface {
 const (
 	errCode uint32 = iota
 	errCodeJSUnmarshal
+	errCodeUnauthorized
+)
+
+var (
+	errNotSignedIn     = errors.New("the session is not signed in")
+	errAlreadySignedIn = errors.New("the session is already signed in")
+	errSessionRevoked  = errors.New("the access token of the session has been revoked")
 )
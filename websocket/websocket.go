@@ -34,9 +34,18 @@ func Write(conn io.ReadWriter, op ws.OpCode, message *message.Message) error {
 	return nil
 }
 
-func NewConnection(conn net.Conn, r *rediscli.Redis, c *message.Controller, initErr chan error) {
+// NewConnection serves a websocket connection. The session is bound to the given user, signed in with token, or if
+// nil, to the user signing in with the first message. The session is closed once its access token is revoked, and
+// leaves the channels it joined when the user signs out.
+func NewConnection(
+	conn net.Conn, r *rediscli.Redis, c *message.Controller, user *rediscli.User, token string, initErr chan error,
+) {
 	userSessionUUID := uuid.NewString()
 
+	var userID string
+
+	var channels []*rediscli.ChannelPubSub
+
 	err := r.AddConnection(userSessionUUID)
 	if err != nil {
 		initErr <- err
@@ -46,6 +55,7 @@ func NewConnection(conn net.Conn, r *rediscli.Redis, c *message.Controller, init
 	connectionAdd(conn, userSessionUUID)
 
 	defer func() {
+		channelsUnsubscribe(r, channels)
 		conn.Close()
 
 		err = r.DelConnection(userSessionUUID)
@@ -64,6 +74,15 @@ func NewConnection(conn net.Conn, r *rediscli.Redis, c *message.Controller, init
 
 	initErr <- nil
 
+	if user != nil {
+		if errI := c.SignedIn(userSessionUUID, conn, ws.OpText, Write, user); errI != nil {
+			log.Println(errI)
+			return
+		}
+
+		userID = user.ID
+	}
+
 	for {
 		msg := &message.Message{}
 
@@ -77,19 +96,52 @@ func NewConnection(conn net.Conn, r *rediscli.Redis, c *message.Controller, init
 			var receivedErr IError
 
 			log.Println("Received message:", string(data))
+
+			switch {
+			case userID == "" && msg.Type != message.DataTypeSignIn:
+				_ = Write(conn, op, c.Error(errCodeUnauthorized, errNotSignedIn, userSessionUUID, msg))
+				continue
+			case userID != "" && msg.Type == message.DataTypeSignIn:
+				_ = Write(conn, op, c.Error(errCodeUnauthorized, errAlreadySignedIn, userSessionUUID, msg))
+				continue
+			}
+
+			if userID != "" && c.SessionRevoked(token) {
+				_ = Write(conn, op, c.Error(errCodeUnauthorized, errSessionRevoked, userSessionUUID, msg))
+				return
+			}
+
+			// The session is bound to the signed in user, so a user ID supplied by the client is ignored
+			msg.UserID = userID
+
 			switch msg.Type { //nolint:exhaustive
 			case message.DataTypeSignIn:
-				receivedErr = c.SignIn(userSessionUUID, conn, op, Write, msg)
-			case message.DataTypeSignUp:
-				receivedErr = c.SignUp(userSessionUUID, conn, op, Write, msg)
+				var signedInUser *rediscli.User
+
+				signedInUser, receivedErr = c.SignIn(userSessionUUID, conn, op, Write, msg)
+				if signedInUser != nil {
+					userID = signedInUser.ID
+					token = msg.SignIn.Token
+				}
 			case message.DataTypeSignOut:
+				// The channels are left first, so that no message of them follows the sign out
+				channelsUnsubscribe(r, channels)
+				channels = nil
+
 				receivedErr = c.SignOut(userSessionUUID, conn, op, Write, msg)
+				if receivedErr == nil {
+					userID, token = "", ""
+				}
 			case message.DataTypeUsers:
 				receivedErr = c.Users(userSessionUUID, conn, op, Write)
 			case message.DataTypeChannelJoin:
-				channelPubSub, _ := c.ChannelJoin(userSessionUUID, conn, op, Write, msg)
+				var channelPubSub *rediscli.ChannelPubSub
+
+				channelPubSub, receivedErr = c.ChannelJoin(userSessionUUID, conn, op, Write, msg)
 				if channelPubSub != nil {
-					go chatReceiver(conn, channelPubSub, r, c)
+					channels = append(channels, channelPubSub)
+
+					go chatReceiver(conn, userID, token, channelPubSub, r, c)
 				}
 			case message.DataTypeChannelMessage:
 				receivedErr = c.ChannelMessage(userSessionUUID, conn, op, Write, msg)
@@ -115,7 +167,25 @@ func NewConnection(conn net.Conn, r *rediscli.Redis, c *message.Controller, init
 	}
 }
 
+// Handler upgrades the request to a websocket connection. If the request carries an access token, either as a bearer
+// token or in the token query parameter, the session is signed in as its user.
 func Handler(writer http.ResponseWriter, request *http.Request, r *rediscli.Redis, c *message.Controller) {
+	var user *rediscli.User
+
+	token := requestToken(request)
+	if token != "" {
+		var err error
+
+		user, err = c.Authorize(token)
+		if err != nil {
+			log.Println(err)
+			writer.WriteHeader(http.StatusUnauthorized)
+			_, _ = fmt.Fprintf(writer, "%s", "invalid or expired access token")
+
+			return
+		}
+	}
+
 	conn, _, _, err := ws.UpgradeHTTP(request, writer)
 	if err != nil {
 		log.Println(err)
@@ -126,7 +196,7 @@ func Handler(writer http.ResponseWriter, request *http.Request, r *rediscli.Redi
 	}
 
 	chInitErr := make(chan error, 1)
-	go NewConnection(conn, r, c, chInitErr)
+	go NewConnection(conn, r, c, user, token, chInitErr)
 
 	if err = <-chInitErr; err != nil {
 		log.Println(err)
@@ -137,12 +207,51 @@ func Handler(writer http.ResponseWriter, request *http.Request, r *rediscli.Redi
 	}
 }
 
-func chatReceiver(conn net.Conn, channel *rediscli.ChannelPubSub, r *rediscli.Redis, c *message.Controller) {
-	defer channel.Closed()
+// requestToken returns the access token of the upgrade request.
+func requestToken(request *http.Request) string {
+	const prefix = "Bearer "
+
+	header := request.Header.Get("Authorization")
+	if len(header) > len(prefix) && strings.EqualFold(header[:len(prefix)], prefix) {
+		return strings.TrimSpace(header[len(prefix):])
+	}
+
+	return request.URL.Query().Get("token")
+}
+
+// channelsUnsubscribe ends the subscriptions to the channels, stopping their receivers.
+func channelsUnsubscribe(r *rediscli.Redis, channels []*rediscli.ChannelPubSub) {
+	for _, channel := range channels {
+		if err := r.ChannelUnsubscribe(channel); err != nil {
+			log.Println(err)
+		}
+	}
+}
+
+// chatReceiver forwards the messages published in a channel to the connection of the user who joined it, as long as
+// the user is allowed to use the channel. The connection is closed once the access token of the session is revoked.
+func chatReceiver(
+	conn net.Conn, userID, token string, channel *rediscli.ChannelPubSub, r *rediscli.Redis, c *message.Controller,
+) {
+	defer channel.Done()
 
 	for {
 		select {
-		case data := <-channel.Channel():
+		case data, ok := <-channel.Channel():
+			if !ok {
+				return
+			}
+
+			// Closing the connection ends the session, which reads from it
+			if c.SessionRevoked(token) {
+				conn.Close()
+				return
+			}
+
+			if !c.ChannelAllowed(userID, data.Channel) {
+				continue
+			}
+
 			msg := &message.DataChannelMessage{}
 			dec := json.NewDecoder(strings.NewReader(data.Payload))
 			err := dec.Decode(msg)